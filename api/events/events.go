@@ -3,9 +3,11 @@ package events
 import "time"
 
 type Entry struct {
-	Timestamp time.Time         `json:"timestamp"`
-	Service   string            `json:"service"`
-	Level     string            `json:"level"`
-	Message   string            `json:"message"`
-	Fields    map[string]string `json:"fields,omitempty"`
+	ID         int64          `json:"id,omitempty"`
+	Timestamp  time.Time      `json:"timestamp"`
+	ReceivedAt time.Time      `json:"received_at,omitzero"`
+	Service    string         `json:"service"`
+	Level      string         `json:"level"`
+	Message    string         `json:"message"`
+	Fields     map[string]any `json:"fields,omitempty"`
 }
@@ -85,6 +85,13 @@ func (s *TestServer) WithV0RoutesAndClient(client v0.LogClient) *TestServer {
 	return s
 }
 
+// Serve handles a single request, for responses that are not JSON
+func (s *TestServer) Serve(request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.service.Router.ServeHTTP(recorder, request)
+	return recorder
+}
+
 func (s *TestServer) RunRequests(t *testing.T, sampleRequests []ExampleHttpRequest, token string) {
 	t.Helper()
 
@@ -0,0 +1,94 @@
+package routertests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAggregateLogs(t *testing.T) {
+	minute := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	next := minute.Add(time.Minute)
+
+	client := &v0.MockLogClient{}
+	client.On("AggregateLogs", mock.Anything, v0.AggregateQuery{
+		Query:    v0.LogQuery{Level: "error"},
+		GroupBy:  []string{"service"},
+		Interval: time.Minute,
+	}).Return(v0.AggregateResult{Buckets: []v0.AggregateBucket{
+		{Start: &minute, Group: map[string]string{"service": "api"}, Count: 2},
+		{Start: &next, Group: map[string]string{"service": "db"}, Count: 1},
+	}}, nil)
+	client.On("AggregateLogs", mock.Anything, v0.AggregateQuery{
+		Query:    v0.LogQuery{Fields: []v0.FieldFilter{{Key: "region", Op: v0.FieldEquals, Values: []string{"eu"}}}},
+		GroupBy:  []string{"level", "fields.user"},
+		Interval: 24 * time.Hour,
+		Limit:    5000,
+	}).Return(v0.AggregateResult{Buckets: []v0.AggregateBucket{}, Truncated: true}, nil)
+	client.On("AggregateLogs", mock.Anything, v0.AggregateQuery{GroupBy: []string{"message"}}).
+		Return(v0.AggregateResult{}, status.Error(codes.InvalidArgument, `cannot group by "message", expected service, level or fields.<key>`))
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:       "GET",
+			Endpoint:     "/v0/logs/aggregate?level=error&group_by=service&interval=1m",
+			ExpectedCode: http.StatusOK,
+			ExpectedFields: map[string]interface{}{
+				"buckets": []interface{}{
+					map[string]interface{}{"start": "2024-05-31T08:00:00Z", "group": map[string]interface{}{"service": "api"}, "count": float64(2)},
+					map[string]interface{}{"start": "2024-05-31T08:01:00Z", "group": map[string]interface{}{"service": "db"}, "count": float64(1)},
+				},
+				"truncated": false,
+			},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?fields.region=eu&group_by=level,fields.user&interval=1d&limit=5000",
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"buckets": []interface{}{}, "truncated": true},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?group_by=message",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `cannot group by "message", expected service, level or fields.<key>`},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestAggregateLogsInvalidQuery(t *testing.T) {
+	testService := NewTestServer(8800).WithV0RoutesAndClient(&v0.MockLogClient{})
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?interval=500ms",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid interval "500ms", expected a duration of at least 1s such as 1m or 1d`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?limit=20000",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid limit "20000", expected a positive integer up to 10000`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?q=timeout&rank=true",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "rank does not apply to an aggregation"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/aggregate?page_token=token-1",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "page_token does not apply to an aggregation"},
+		},
+	}, "")
+}
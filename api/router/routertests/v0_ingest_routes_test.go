@@ -0,0 +1,95 @@
+package routertests
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostLogs(t *testing.T) {
+	timestamp := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	stored := events.Entry{Timestamp: timestamp, Service: "api", Level: "info", Message: "stored"}
+	invalid := events.Entry{Timestamp: timestamp, Service: "api", Level: "info"}
+
+	client := &v0.MockLogClient{}
+	client.On("ForwardLogs", mock.Anything, []events.Entry{stored}).
+		Return(v0.IngestResult{Accepted: 1}, nil)
+	client.On("ForwardLogs", mock.Anything, []events.Entry{stored, invalid}).
+		Return(v0.IngestResult{Accepted: 1, Rejected: 1, Errors: []v0.EventError{{Index: 1, Reason: "message is required"}}}, nil)
+	client.On("ForwardLogs", mock.Anything, []events.Entry{invalid}).
+		Return(v0.IngestResult{Rejected: 1, Errors: []v0.EventError{{Index: 0, Reason: "message is required"}}}, nil)
+	client.On("ForwardLogs", mock.Anything, []events.Entry{{Timestamp: timestamp, Service: "api", Level: "info", Message: "typed", Fields: map[string]any{
+		"latency_ms": 812.5, "cached": false, "request": map[string]any{"method": "GET"}, "tags": []any{"a"}, "note": nil,
+	}}}).Return(v0.IngestResult{Accepted: 1}, nil)
+	client.On("ForwardLogs", mock.Anything, []events.Entry{{Timestamp: timestamp, Service: "db", Level: "info", Message: "down"}}).
+		Return(v0.IngestResult{}, errors.New("connection refused"))
+
+	storedJSON := `{"timestamp": "2024-05-31T08:00:00Z", "service": "api", "level": "info", "message": "stored"}`
+	invalidJSON := `{"timestamp": "2024-05-31T08:00:00Z", "service": "api", "level": "info"}`
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "POST",
+			Endpoint:       "/v0/logs",
+			Payload:        "[" + storedJSON + "]",
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"accepted": 1.0, "rejected": 0.0},
+		},
+		{
+			Method:       "POST",
+			Endpoint:     "/v0/logs",
+			Payload:      "[" + storedJSON + `, {"timestamp": "yesterday", "service": "api", "level": "info", "message": "x"}, {"service": "api"}, ` + invalidJSON + `, {"fields": ["n"]}]`,
+			ExpectedCode: http.StatusMultiStatus,
+			ExpectedFields: map[string]interface{}{
+				"accepted": 1.0,
+				"rejected": 4.0,
+				"errors": []interface{}{
+					map[string]interface{}{"index": 1.0, "reason": `invalid timestamp "yesterday", expected RFC 3339`},
+					map[string]interface{}{"index": 2.0, "reason": "timestamp is required"},
+					map[string]interface{}{"index": 3.0, "reason": "message is required"},
+					map[string]interface{}{"index": 4.0, "reason": "invalid log entry, expected string values and a fields object"},
+				},
+			},
+		},
+		{
+			Method:       "POST",
+			Endpoint:     "/v0/logs",
+			Payload:      "[" + invalidJSON + "]",
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{
+				"message":  "All logs were rejected",
+				"accepted": 0.0,
+				"rejected": 1.0,
+			},
+		},
+		{
+			Method:   "POST",
+			Endpoint: "/v0/logs",
+			Payload: `[{"timestamp": "2024-05-31T08:00:00Z", "service": "api", "level": "info", "message": "typed", "fields": ` +
+				`{"latency_ms": 812.5, "cached": false, "request": {"method": "GET"}, "tags": ["a"], "note": null}}]`,
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"accepted": 1.0},
+		},
+		{
+			Method:         "POST",
+			Endpoint:       "/v0/logs",
+			Payload:        `{"not": "an array"}`,
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid JSON body"},
+		},
+		{
+			Method:         "POST",
+			Endpoint:       "/v0/logs",
+			Payload:        `[{"timestamp": "2024-05-31T08:00:00Z", "service": "db", "level": "info", "message": "down"}]`,
+			ExpectedCode:   http.StatusInternalServerError,
+			ExpectedFields: map[string]interface{}{"message": "failed to forward logs"},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,252 @@
+package routertests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetLogsTextSearch(t *testing.T) {
+	client := &v0.MockLogClient{}
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Service: "api", Text: "connection refused", Rank: true}).
+		Return(v0.LogPage{Logs: []events.Entry{{Service: "api", Level: "error", Message: "connection refused"}}}, nil)
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Text: "timeout"}).
+		Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:       "GET",
+			Endpoint:     "/v0/logs?service=api&q=connection+refused&rank=true",
+			ExpectedCode: http.StatusOK,
+			ExpectedFields: map[string]interface{}{
+				"logs": []interface{}{map[string]interface{}{
+					"timestamp": "0001-01-01T00:00:00Z",
+					"service":   "api",
+					"level":     "error",
+					"message":   "connection refused",
+				}},
+			},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?q=timeout",
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"logs": []interface{}{}},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?q=timeout&rank=maybe",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid rank value "maybe", expected true or false`},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestGetLogsQueryLanguage(t *testing.T) {
+	expr := `service:api AND level:(error OR warn) AND fields.user:"alice" AND message~"timeout"`
+	client := &v0.MockLogClient{}
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Expr: expr, Limit: 20}).
+		Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		NewBasicExampleRequest("GET", "/v0/logs?limit=20&query="+url.QueryEscape(expr), http.StatusOK),
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?query=" + url.QueryEscape("service:api AND level=error"),
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid query: syntax error at column 22: use ":" rather than "=" to match a value`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?query=" + url.QueryEscape("(service:api"),
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid query: syntax error at column 13: expected ) to close the ( at column 1, found end of query"},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestGetLogsFieldFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		expected []v0.FieldFilter
+	}{
+		{"equals", "fields.user=alice", []v0.FieldFilter{{Key: "user", Op: v0.FieldEquals, Values: []string{"alice"}}}},
+		{"in", "fields.user=alice&fields.user=bob", []v0.FieldFilter{{Key: "user", Op: v0.FieldIn, Values: []string{"alice", "bob"}}}},
+		{"prefix", "fields.path=%2Fv0%2F*", []v0.FieldFilter{{Key: "path", Op: v0.FieldPrefix, Values: []string{"/v0/"}}}},
+		{"literal asterisk", `fields.note=5%5C*`, []v0.FieldFilter{{Key: "note", Op: v0.FieldEquals, Values: []string{"5*"}}}},
+		{"exists", "fields.trace=*", []v0.FieldFilter{{Key: "trace", Op: v0.FieldExists}}},
+		{"not equals", "fields.region!=eu&fields.region!=us", []v0.FieldFilter{
+			{Key: "region", Op: v0.FieldNotEquals, Values: []string{"eu"}},
+			{Key: "region", Op: v0.FieldNotEquals, Values: []string{"us"}},
+		}},
+		{"not exists", "fields.span!=*", []v0.FieldFilter{{Key: "span", Op: v0.FieldNotExists}}},
+		{"greater", "fields.latency_ms>500", []v0.FieldFilter{{Key: "latency_ms", Op: v0.FieldGreater, Values: []string{"500"}}}},
+		{"at least", "fields.latency_ms>=500", []v0.FieldFilter{{Key: "latency_ms", Op: v0.FieldGreaterOrEqual, Values: []string{"500"}}}},
+		{"less", "fields.latency_ms%3C0.5", []v0.FieldFilter{{Key: "latency_ms", Op: v0.FieldLess, Values: []string{"0.5"}}}},
+		{"range", "fields.latency_ms>=100&fields.latency_ms<=200", []v0.FieldFilter{
+			{Key: "latency_ms", Op: v0.FieldLessOrEqual, Values: []string{"200"}},
+			{Key: "latency_ms", Op: v0.FieldGreaterOrEqual, Values: []string{"100"}},
+		}},
+		{"sorted by key", "fields.b=2&fields.a=1", []v0.FieldFilter{
+			{Key: "a", Op: v0.FieldEquals, Values: []string{"1"}},
+			{Key: "b", Op: v0.FieldEquals, Values: []string{"2"}},
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &v0.MockLogClient{}
+			client.On("FetchLogs", mock.Anything, v0.LogQuery{Fields: tc.expected}).Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+
+			testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+			testService.RunRequests(t, []ExampleHttpRequest{
+				NewBasicExampleRequest("GET", "/v0/logs?"+tc.query, http.StatusOK),
+			}, "")
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetLogsInvalidFieldFilters(t *testing.T) {
+	testService := NewTestServer(8800).WithV0RoutesAndClient(&v0.MockLogClient{})
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.=x",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid field filter "fields.": missing field name`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.user=al*&fields.user=bob",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid field filter user: wildcards cannot be combined with other values"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.user!=al*",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid field filter user!=al*: prefixes cannot be negated"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.latency_ms>fast",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid field filter latency_ms>fast: expected a number"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.latency_ms<=",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid field filter latency_ms<=: expected a number"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?fields.>5",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid field filter "fields.>5": missing field name`},
+		},
+	}, "")
+}
+
+func TestGetLogsPagination(t *testing.T) {
+	client := &v0.MockLogClient{}
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Limit: 2}).
+		Return(v0.LogPage{Logs: []events.Entry{}, NextPageToken: "token-1"}, nil)
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Limit: 2, PageToken: "token-1"}).
+		Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{PageToken: "bogus"}).
+		Return(v0.LogPage{}, status.Error(codes.InvalidArgument, "invalid page token"))
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?limit=2",
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"next_page_token": "token-1"},
+		},
+		NewBasicExampleRequest("GET", "/v0/logs?limit=2&page_token=token-1", http.StatusOK),
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?page_token=bogus",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "invalid page token"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?limit=0",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid limit "0", expected a positive integer up to 1000`},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestGetLogsTimeRangeAndOrder(t *testing.T) {
+	start := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC)
+
+	client := &v0.MockLogClient{}
+	client.On("FetchLogs", mock.Anything, v0.LogQuery{Start: &start, End: &end, Ascending: true, Limit: 50}).
+		Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+	client.On("FetchLogs", mock.Anything, mock.MatchedBy(func(query v0.LogQuery) bool {
+		return query.Start != nil && query.End == nil && !query.Ascending &&
+			time.Since(*query.Start) >= 15*time.Minute && time.Since(*query.Start) < 16*time.Minute
+	})).Return(v0.LogPage{Logs: []events.Entry{}}, nil)
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		NewBasicExampleRequest("GET", "/v0/logs?start=2024-05-31T08:00:00Z&end=2024-05-31T09:00:00Z&order=asc&limit=50", http.StatusOK),
+		NewBasicExampleRequest("GET", "/v0/logs?start=now-15m&order=desc", http.StatusOK),
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestGetLogsInvalidTimeRangeAndOrder(t *testing.T) {
+	testService := NewTestServer(8800).WithV0Routes()
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?start=yesterday",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid start: invalid time "yesterday", expected RFC 3339 or now[+-]duration`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?end=now-5x",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid end: invalid time "now-5x": invalid duration "5x"`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?start=now&end=now-1h",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "start must not be after end"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?order=sideways",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid order "sideways", expected asc or desc`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs?limit=1001",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid limit "1001", expected a positive integer up to 1000`},
+		},
+	}, "")
+}
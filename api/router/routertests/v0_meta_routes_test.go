@@ -0,0 +1,77 @@
+package routertests
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListCatalog(t *testing.T) {
+	start := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	client := &v0.MockLogClient{}
+	client.On("ListCatalog", mock.Anything, v0.CatalogQuery{Kind: v0.CatalogServices}).
+		Return(v0.CatalogResult{Values: []v0.CatalogValue{
+			{Value: "api", Count: 2, FirstSeen: start, LastSeen: start.Add(time.Minute)},
+		}}, nil)
+	client.On("ListCatalog", mock.Anything, v0.CatalogQuery{Kind: v0.CatalogLevels, Start: &start, End: &end, Limit: 1}).
+		Return(v0.CatalogResult{Values: []v0.CatalogValue{}, Truncated: true}, nil)
+	client.On("ListCatalog", mock.Anything, v0.CatalogQuery{Kind: v0.CatalogFieldKeys}).
+		Return(v0.CatalogResult{}, errors.New("connection refused"))
+
+	testService := NewTestServer(8800).WithV0RoutesAndClient(client)
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:       "GET",
+			Endpoint:     "/v0/meta/services",
+			ExpectedCode: http.StatusOK,
+			ExpectedFields: map[string]interface{}{
+				"values": []interface{}{
+					map[string]interface{}{
+						"value":      "api",
+						"count":      float64(2),
+						"first_seen": "2024-05-31T08:00:00Z",
+						"last_seen":  "2024-05-31T08:01:00Z",
+					},
+				},
+				"truncated": false,
+			},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/meta/levels?start=2024-05-31T08:00:00Z&end=2024-05-31T10:00:00Z&limit=1",
+			ExpectedCode:   http.StatusOK,
+			ExpectedFields: map[string]interface{}{"values": []interface{}{}, "truncated": true},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/meta/fields",
+			ExpectedCode:   http.StatusInternalServerError,
+			ExpectedFields: map[string]interface{}{"message": "failed to list fields"},
+		},
+	}, "")
+	client.AssertExpectations(t)
+}
+
+func TestListCatalogInvalidQuery(t *testing.T) {
+	testService := NewTestServer(8800).WithV0RoutesAndClient(&v0.MockLogClient{})
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/meta/services?limit=0",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid limit "0", expected a positive integer up to 10000`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/meta/levels?start=now&end=now-1h",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "start must not be after end"},
+		},
+	}, "")
+}
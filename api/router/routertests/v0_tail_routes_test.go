@@ -0,0 +1,160 @@
+package routertests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeTail delivers its events, then blocks for wait, then fails with err.
+// It ends early when its context is cancelled.
+type fakeTail struct {
+	ctx    context.Context
+	events []v0.TailEvent
+	wait   time.Duration
+	err    error
+}
+
+func (t *fakeTail) Recv() (v0.TailEvent, error) {
+	if len(t.events) > 0 {
+		event := t.events[0]
+		t.events = t.events[1:]
+		return event, nil
+	}
+	select {
+	case <-time.After(t.wait):
+		return v0.TailEvent{}, t.err
+	case <-t.ctx.Done():
+		return v0.TailEvent{}, t.ctx.Err()
+	}
+}
+
+// expectTail makes client serve tail for the query, binding it to the
+// handler's context
+func expectTail(client *v0.MockLogClient, query interface{}, tail *fakeTail) {
+	client.On("TailLogs", mock.Anything, query).
+		Run(func(args mock.Arguments) { tail.ctx = args.Get(0).(context.Context) }).
+		Return(tail, nil)
+}
+
+func serveTail(client v0.LogClient, request *http.Request) *httptest.ResponseRecorder {
+	return NewTestServer(8800).WithV0RoutesAndClient(client).Serve(request)
+}
+
+func TestTailLogs(t *testing.T) {
+	timestamp := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+
+	t.Run("streams logs as server-sent events", func(t *testing.T) {
+		client := &v0.MockLogClient{}
+		expectTail(client, v0.TailQuery{Query: v0.LogQuery{Service: "api"}, Replay: 5}, &fakeTail{
+			events: []v0.TailEvent{
+				{Entry: events.Entry{Timestamp: timestamp, Service: "api", Level: "info", Message: "started"}, Cursor: "e-1"},
+				{Entry: events.Entry{Timestamp: timestamp, Service: "api", Level: "error", Message: "failed"}, Cursor: "e-2"},
+			},
+			err: io.EOF,
+		})
+
+		recorder := serveTail(client, httptest.NewRequest("GET", "/v0/logs/tail?service=api&replay=5", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+		assert.Equal(t,
+			"id: e-1\ndata: {\"timestamp\":\"2024-05-31T08:00:00Z\",\"service\":\"api\",\"level\":\"info\",\"message\":\"started\"}\n\n"+
+				"id: e-2\ndata: {\"timestamp\":\"2024-05-31T08:00:00Z\",\"service\":\"api\",\"level\":\"error\",\"message\":\"failed\"}\n\n",
+			recorder.Body.String())
+		client.AssertExpectations(t)
+	})
+
+	t.Run("resumes from Last-Event-ID", func(t *testing.T) {
+		client := &v0.MockLogClient{}
+		expectTail(client, v0.TailQuery{Cursor: "e-2"}, &fakeTail{err: io.EOF})
+
+		request := httptest.NewRequest("GET", "/v0/logs/tail", nil)
+		request.Header.Set("Last-Event-ID", "e-2")
+		serveTail(client, request)
+
+		expectTail(client, v0.TailQuery{Cursor: "e-3"}, &fakeTail{err: io.EOF})
+		serveTail(client, httptest.NewRequest("GET", "/v0/logs/tail?last_event_id=e-3", nil))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("sends heartbeats while idle", func(t *testing.T) {
+		defer func(interval time.Duration) { v0.TailHeartbeatInterval = interval }(v0.TailHeartbeatInterval)
+		v0.TailHeartbeatInterval = 10 * time.Millisecond
+
+		client := &v0.MockLogClient{}
+		expectTail(client, v0.TailQuery{}, &fakeTail{wait: 100 * time.Millisecond, err: io.EOF})
+
+		recorder := serveTail(client, httptest.NewRequest("GET", "/v0/logs/tail", nil))
+		assert.Contains(t, recorder.Body.String(), ": heartbeat\n\n")
+	})
+
+	t.Run("stops when the client disconnects", func(t *testing.T) {
+		client := &v0.MockLogClient{}
+		tail := &fakeTail{wait: time.Hour}
+		expectTail(client, v0.TailQuery{}, tail)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		request := httptest.NewRequest("GET", "/v0/logs/tail", nil).WithContext(ctx)
+
+		done := make(chan struct{})
+		go func() {
+			serveTail(client, request)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("tail did not stop after the client disconnected")
+		}
+		assert.Error(t, tail.ctx.Err(), "the worker tail should be cancelled")
+	})
+
+	t.Run("reports worker errors as error events", func(t *testing.T) {
+		client := &v0.MockLogClient{}
+		expectTail(client, v0.TailQuery{}, &fakeTail{err: status.Error(codes.ResourceExhausted, "subscriber fell behind and was dropped")})
+
+		recorder := serveTail(client, httptest.NewRequest("GET", "/v0/logs/tail", nil))
+		assert.Equal(t, "event: error\ndata: {\"message\":\"subscriber fell behind and was dropped\"}\n\n", recorder.Body.String())
+	})
+}
+
+func TestTailLogsInvalidQuery(t *testing.T) {
+	testService := NewTestServer(8800).WithV0Routes()
+	testService.RunRequests(t, []ExampleHttpRequest{
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/tail?replay=-1",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid replay "-1", expected an integer from 0 to 1000`},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/tail?page_token=abc",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "page_token does not apply to a tail"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/tail?q=timeout&rank=true",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": "rank does not apply to a tail"},
+		},
+		{
+			Method:         "GET",
+			Endpoint:       "/v0/logs/tail?start=yesterday",
+			ExpectedCode:   http.StatusBadRequest,
+			ExpectedFields: map[string]interface{}{"message": `invalid start: invalid time "yesterday", expected RFC 3339 or now[+-]duration`},
+		},
+	}, "")
+}
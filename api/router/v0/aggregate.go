@@ -0,0 +1,45 @@
+package v0
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgfranco17/echoris/api/httperror"
+	"github.com/jgfranco17/echoris/api/logging"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// aggregateLogs counts matching logs grouped by the requested dimensions
+// and time buckets
+func aggregateLogs(client LogClient) HttpHandler {
+	return func(c *gin.Context) error {
+		logger := logging.FromContext(c)
+		query, err := parseAggregateQuery(c)
+		if err != nil {
+			return httperror.New(c, http.StatusBadRequest, "%s", err.Error())
+		}
+
+		logger.WithFields(logrus.Fields{
+			"service":  query.Query.Service,
+			"level":    query.Query.Level,
+			"group_by": query.GroupBy,
+			"interval": query.Interval.String(),
+		}).Info("Aggregating logs")
+
+		result, err := client.AggregateLogs(context.Background(), query)
+		if err != nil {
+			logger.WithError(err).Error("Failed to aggregate logs")
+			if status.Code(err) == codes.InvalidArgument {
+				return httperror.New(c, http.StatusBadRequest, "%s", status.Convert(err).Message())
+			}
+			return httperror.New(c, http.StatusInternalServerError, "failed to aggregate logs")
+		}
+
+		logger.WithField("buckets", len(result.Buckets)).Info("Successfully aggregated logs")
+		c.JSON(http.StatusOK, result)
+		return nil
+	}
+}
@@ -2,19 +2,121 @@ package v0
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/jgfranco17/echoris/api/events"
 	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/protos/eventfields"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// LogQuery holds the filters for fetching logs
+type LogQuery struct {
+	Service   string
+	Level     string
+	Text      string        // Full-text search over messages
+	Expr      string        // Query language expression, which must also hold
+	Rank      bool          // Order text matches by relevance instead of time
+	Fields    []FieldFilter // Conditions on fields, all of which must hold
+	Start     *time.Time    // Only logs at or after this time
+	End       *time.Time    // Only logs at or before this time
+	Ascending bool          // Return the oldest logs first instead of the newest
+	Limit     int           // Maximum number of logs per page; 0 uses the worker default
+	PageToken string        // NextPageToken of the previous page
+}
+
+// LogPage is one page of query results
+type LogPage struct {
+	Logs          []events.Entry
+	NextPageToken string // Empty on the last page
+}
+
+// TailQuery holds the filters of a live tail
+type TailQuery struct {
+	Query  LogQuery // Filters of the tail; paging and ordering do not apply
+	Replay int      // Number of recent matching logs to send before live ones
+	Cursor string   // Resume after the log with this cursor instead of replaying
+}
+
+// TailEvent is a log delivered by a live tail
+type TailEvent struct {
+	Entry  events.Entry
+	Cursor string // Resumes the tail after this log
+}
+
+// LogTail is an open live tail, which ends when its context is cancelled
+type LogTail interface {
+	// Recv blocks until the next log arrives or the tail ends
+	Recv() (TailEvent, error)
+}
+
+// AggregateQuery counts the logs matching Query, grouped by the values of
+// GroupBy and by time buckets Interval wide
+type AggregateQuery struct {
+	Query    LogQuery      // Filters of the aggregation; paging and ordering do not apply
+	GroupBy  []string      // service, level or fields.<key>
+	Interval time.Duration // Bucket width; 0 for a single bucket over the whole range
+	Limit    int           // Maximum number of buckets; 0 uses the worker default
+}
+
+// AggregateBucket is the number of logs sharing a time bucket and group
+type AggregateBucket struct {
+	Start *time.Time        `json:"start,omitempty"`
+	Group map[string]string `json:"group,omitempty"`
+	Count int64             `json:"count"`
+}
+
+// AggregateResult holds the buckets of an aggregation in order
+type AggregateResult struct {
+	Buckets   []AggregateBucket `json:"buckets"`
+	Truncated bool              `json:"truncated"` // More buckets matched than the limit
+}
+
+// CatalogKind is what a catalog lists the distinct values of
+type CatalogKind string
+
+const (
+	CatalogServices  CatalogKind = "services"
+	CatalogLevels    CatalogKind = "levels"
+	CatalogFieldKeys CatalogKind = "fields" // Top-level keys of fields
+)
+
+// CatalogQuery lists the distinct values of Kind among logs between Start
+// and End, which the worker widens to whole hours
+type CatalogQuery struct {
+	Kind  CatalogKind
+	Start *time.Time
+	End   *time.Time
+	Limit int // Maximum number of values; 0 uses the worker default
+}
+
+// CatalogValue is a distinct value with the number of logs carrying it
+// and the times of the first and last of them
+type CatalogValue struct {
+	Value     string    `json:"value"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// CatalogResult holds the values of a catalog in bytewise order
+type CatalogResult struct {
+	Values    []CatalogValue `json:"values"`
+	Truncated bool           `json:"truncated"` // More values matched than the limit
+}
+
 // LogClient defines the interface for log operations
 type LogClient interface {
-	ForwardLogs(ctx context.Context, batch []events.Entry) error
-	FetchLogs(ctx context.Context, service, level string) ([]events.Entry, error)
+	ForwardLogs(ctx context.Context, batch []events.Entry) (IngestResult, error)
+	FetchLogs(ctx context.Context, query LogQuery) (LogPage, error)
+	TailLogs(ctx context.Context, query TailQuery) (LogTail, error)
+	AggregateLogs(ctx context.Context, query AggregateQuery) (AggregateResult, error)
+	ListCatalog(ctx context.Context, query CatalogQuery) (CatalogResult, error)
 	Close() error
 }
 
@@ -40,48 +142,300 @@ func NewGRPCLogClient(address string) (*GRPCLogClient, error) {
 	}, nil
 }
 
-// ForwardLogs sends a batch of log entries to the log aggregator
-func (c *GRPCLogClient) ForwardLogs(ctx context.Context, batch []events.Entry) error {
+// ForwardLogs sends a batch of log entries to the log aggregator, which
+// rejects invalid entries individually
+func (c *GRPCLogClient) ForwardLogs(ctx context.Context, batch []events.Entry) (IngestResult, error) {
 	var pbEvents []*pb.LogEvent
-	for _, e := range batch {
-		pbEvents = append(pbEvents, &pb.LogEvent{
-			Timestamp: e.Timestamp.Format(time.RFC3339Nano),
-			Service:   e.Service,
-			Level:     e.Level,
-			Message:   e.Message,
-			Fields:    e.Fields,
-		})
+	for i, e := range batch {
+		event, err := entryToProto(e)
+		if err != nil {
+			return IngestResult{}, fmt.Errorf("failed to convert entry %d: %w", i, err)
+		}
+		pbEvents = append(pbEvents, event)
 	}
 
-	_, err := c.client.SendLogs(ctx, &pb.LogBatch{
+	resp, err := c.client.SendLogs(ctx, &pb.LogBatch{
 		Events: pbEvents,
 	})
-	return err
+	if err != nil {
+		return IngestResult{}, err
+	}
+	return ingestResult(resp.GetAccepted(), resp.GetRejected(), resp.GetErrors()), nil
 }
 
-// FetchLogs retrieves log entries from the log aggregator
-func (c *GRPCLogClient) FetchLogs(ctx context.Context, service, level string) ([]events.Entry, error) {
-	resp, err := c.client.QueryLogs(ctx, &pb.QueryRequest{
-		Service: service,
-		Level:   level,
-	})
+// EventError explains why the entry at Index was rejected
+type EventError struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// IngestResult reports which logs the log aggregator stored
+type IngestResult struct {
+	Accepted int64
+	Rejected int64
+	Errors   []EventError // Up to a limit, in index order
+}
+
+func ingestResult(accepted, rejected int64, errors []*pb.EventError) IngestResult {
+	result := IngestResult{Accepted: accepted, Rejected: rejected}
+	for _, e := range errors {
+		result.Errors = append(result.Errors, EventError{Index: int(e.GetIndex()), Reason: e.GetReason()})
+	}
+	return result
+}
+
+// LogStream ships log entries to the log aggregator over a single
+// StreamLogs call, which commits them in chunks as they arrive
+type LogStream struct {
+	stream pb.LogAggregator_StreamLogsClient
+}
+
+// OpenLogStream starts streaming log entries to the log aggregator
+func (c *GRPCLogClient) OpenLogStream(ctx context.Context) (*LogStream, error) {
+	stream, err := c.client.StreamLogs(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return &LogStream{stream: stream}, nil
+}
+
+// Send queues entry on the stream
+func (s *LogStream) Send(entry events.Entry) error {
+	event, err := entryToProto(entry)
+	if err != nil {
+		return err
+	}
+	return s.stream.Send(event)
+}
+
+// Close ends the stream and waits for the log aggregator to commit the
+// remaining entries
+func (s *LogStream) Close() (IngestResult, error) {
+	resp, err := s.stream.CloseAndRecv()
+	if err != nil {
+		return IngestResult{}, err
+	}
+	return ingestResult(resp.GetAccepted(), resp.GetRejected(), resp.GetErrors()), nil
+}
+
+// FetchLogs retrieves a page of log entries from the log aggregator
+func (c *GRPCLogClient) FetchLogs(ctx context.Context, query LogQuery) (LogPage, error) {
+	resp, err := c.client.QueryLogs(ctx, queryRequest(query))
+	if err != nil {
+		return LogPage{}, err
+	}
 
 	var logs []events.Entry
 	for _, e := range resp.Events {
-		t, _ := time.Parse(time.RFC3339Nano, e.Timestamp)
-		logs = append(logs, events.Entry{
-			Timestamp: t,
-			Service:   e.Service,
-			Level:     e.Level,
-			Message:   e.Message,
-			Fields:    e.Fields,
+		entry, err := entryFromProto(e)
+		if err != nil {
+			return LogPage{}, err
+		}
+		logs = append(logs, entry)
+	}
+
+	return LogPage{Logs: logs, NextPageToken: resp.NextPageToken}, nil
+}
+
+// TailLogs opens a live tail of log entries from the log aggregator
+func (c *GRPCLogClient) TailLogs(ctx context.Context, query TailQuery) (LogTail, error) {
+	stream, err := c.client.TailLogs(ctx, &pb.TailRequest{
+		Filter: queryRequest(query.Query),
+		Replay: int32(query.Replay),
+		Cursor: query.Cursor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grpcLogTail{stream: stream}, nil
+}
+
+// grpcLogTail adapts a TailLogs stream to LogTail
+type grpcLogTail struct {
+	stream pb.LogAggregator_TailLogsClient
+}
+
+func (t *grpcLogTail) Recv() (TailEvent, error) {
+	event, err := t.stream.Recv()
+	if err != nil {
+		return TailEvent{}, err
+	}
+	entry, err := entryFromProto(event.GetEvent())
+	if err != nil {
+		return TailEvent{}, err
+	}
+	return TailEvent{Entry: entry, Cursor: event.GetCursor()}, nil
+}
+
+// AggregateLogs counts log entries in the log aggregator by group and time
+// bucket
+func (c *GRPCLogClient) AggregateLogs(ctx context.Context, query AggregateQuery) (AggregateResult, error) {
+	req := &pb.AggregateRequest{
+		Filter:  queryRequest(query.Query),
+		GroupBy: query.GroupBy,
+		Limit:   int32(query.Limit),
+	}
+	if query.Interval > 0 {
+		req.Interval = durationpb.New(query.Interval)
+	}
+	resp, err := c.client.AggregateLogs(ctx, req)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+
+	result := AggregateResult{
+		Buckets:   make([]AggregateBucket, 0, len(resp.GetBuckets())),
+		Truncated: resp.GetTruncated(),
+	}
+	for _, b := range resp.GetBuckets() {
+		bucket := AggregateBucket{Group: b.GetGroup(), Count: b.GetCount()}
+		if b.GetStart() != nil {
+			start := b.GetStart().AsTime()
+			bucket.Start = &start
+		}
+		result.Buckets = append(result.Buckets, bucket)
+	}
+	return result, nil
+}
+
+// ListCatalog lists the distinct services, levels or field keys known to
+// the log aggregator
+func (c *GRPCLogClient) ListCatalog(ctx context.Context, query CatalogQuery) (CatalogResult, error) {
+	req := &pb.CatalogRequest{Limit: int32(query.Limit)}
+	if query.Start != nil {
+		req.Start = timestamppb.New(*query.Start)
+	}
+	if query.End != nil {
+		req.End = timestamppb.New(*query.End)
+	}
+
+	var resp *pb.CatalogResponse
+	var err error
+	switch query.Kind {
+	case CatalogServices:
+		resp, err = c.client.ListServices(ctx, req)
+	case CatalogLevels:
+		resp, err = c.client.ListLevels(ctx, req)
+	case CatalogFieldKeys:
+		resp, err = c.client.ListFieldKeys(ctx, req)
+	default:
+		return CatalogResult{}, fmt.Errorf("unknown catalog kind %q", query.Kind)
+	}
+	if err != nil {
+		return CatalogResult{}, err
+	}
+
+	result := CatalogResult{
+		Values:    make([]CatalogValue, 0, len(resp.GetValues())),
+		Truncated: resp.GetTruncated(),
+	}
+	for _, v := range resp.GetValues() {
+		result.Values = append(result.Values, CatalogValue{
+			Value:     v.GetValue(),
+			Count:     v.GetCount(),
+			FirstSeen: v.GetFirstSeen().AsTime(),
+			LastSeen:  v.GetLastSeen().AsTime(),
 		})
 	}
+	return result, nil
+}
+
+func queryRequest(query LogQuery) *pb.QueryRequest {
+	return &pb.QueryRequest{
+		Service:   query.Service,
+		Level:     query.Level,
+		Text:      query.Text,
+		Query:     query.Expr,
+		Rank:      query.Rank,
+		Fields:    fieldFiltersToProto(query.Fields),
+		Limit:     int32(query.Limit),
+		PageToken: query.PageToken,
+		StartTime: formatTime(query.Start),
+		EndTime:   formatTime(query.End),
+		Order:     sortOrder(query.Ascending),
+	}
+}
+
+// entryToProto converts an entry for sending. The legacy timestamp string
+// and fields map are filled in for workers that predate time and attributes.
+func entryToProto(e events.Entry) (*pb.LogEvent, error) {
+	event := &pb.LogEvent{
+		Timestamp: e.Timestamp.Format(time.RFC3339Nano),
+		Time:      timestamppb.New(e.Timestamp),
+		Service:   e.Service,
+		Level:     e.Level,
+		Message:   e.Message,
+	}
+	if err := eventfields.Set(event, e.Fields); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// entryFromProto converts a returned event, reading its time and fields
+// from the legacy timestamp string and fields map when the worker predates
+// time and attributes
+func entryFromProto(e *pb.LogEvent) (events.Entry, error) {
+	entry := events.Entry{
+		ID:      e.GetId(),
+		Service: e.GetService(),
+		Level:   e.GetLevel(),
+		Message: e.GetMessage(),
+		Fields:  eventfields.Get(e),
+	}
+	if e.GetReceivedAt() != nil {
+		entry.ReceivedAt = e.GetReceivedAt().AsTime()
+	}
+
+	if e.GetTime() != nil {
+		entry.Timestamp = e.GetTime().AsTime()
+		return entry, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, e.GetTimestamp())
+	if err != nil {
+		return entry, fmt.Errorf("failed to parse log timestamp: %w", err)
+	}
+	entry.Timestamp = t
+	return entry, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func sortOrder(ascending bool) pb.SortOrder {
+	if ascending {
+		return pb.SortOrder_OLDEST_FIRST
+	}
+	return pb.SortOrder_NEWEST_FIRST
+}
 
-	return logs, nil
+var fieldOpsToProto = map[FieldOp]pb.FieldFilter_Op{
+	FieldEquals:         pb.FieldFilter_EQUALS,
+	FieldNotEquals:      pb.FieldFilter_NOT_EQUALS,
+	FieldExists:         pb.FieldFilter_EXISTS,
+	FieldNotExists:      pb.FieldFilter_NOT_EXISTS,
+	FieldPrefix:         pb.FieldFilter_PREFIX,
+	FieldIn:             pb.FieldFilter_IN,
+	FieldGreater:        pb.FieldFilter_GREATER,
+	FieldGreaterOrEqual: pb.FieldFilter_GREATER_OR_EQUAL,
+	FieldLess:           pb.FieldFilter_LESS,
+	FieldLessOrEqual:    pb.FieldFilter_LESS_OR_EQUAL,
+}
+
+func fieldFiltersToProto(filters []FieldFilter) []*pb.FieldFilter {
+	var converted []*pb.FieldFilter
+	for _, f := range filters {
+		converted = append(converted, &pb.FieldFilter{
+			Key:    f.Key,
+			Op:     fieldOpsToProto[f.Op],
+			Values: f.Values,
+		})
+	}
+	return converted
 }
 
 // Close closes the gRPC connection
@@ -0,0 +1,57 @@
+package v0
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestEntryFromProto(t *testing.T) {
+	happened := time.Date(2024, 5, 31, 8, 0, 0, 5, time.UTC)
+	received := happened.Add(time.Second)
+
+	t.Run("reads time, received at and id", func(t *testing.T) {
+		entry, err := entryFromProto(&pb.LogEvent{
+			Id:         7,
+			Time:       timestamppb.New(happened),
+			Timestamp:  "2000-01-01T00:00:00Z",
+			ReceivedAt: timestamppb.New(received),
+			Service:    "api",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), entry.ID)
+		assert.True(t, happened.Equal(entry.Timestamp))
+		assert.True(t, received.Equal(entry.ReceivedAt))
+	})
+
+	t.Run("falls back to the legacy timestamp", func(t *testing.T) {
+		entry, err := entryFromProto(&pb.LogEvent{Timestamp: "2024-05-31T08:00:00.000000005Z"})
+		require.NoError(t, err)
+		assert.True(t, happened.Equal(entry.Timestamp))
+		assert.True(t, entry.ReceivedAt.IsZero())
+	})
+
+	t.Run("reports unparseable timestamps", func(t *testing.T) {
+		_, err := entryFromProto(&pb.LogEvent{Timestamp: "yesterday"})
+		assert.ErrorContains(t, err, "failed to parse log timestamp")
+	})
+}
+
+func TestEntryToProto(t *testing.T) {
+	happened := time.Date(2024, 5, 31, 8, 0, 0, 5, time.UTC)
+	event, err := entryToProto(events.Entry{Timestamp: happened, Service: "api", Level: "info", Message: "sent", Fields: map[string]any{"user": "alice"}})
+	require.NoError(t, err)
+	assert.True(t, happened.Equal(event.Time.AsTime()))
+	assert.Equal(t, "2024-05-31T08:00:00.000000005Z", event.Timestamp)
+	assert.Equal(t, map[string]any{"user": "alice"}, event.Attributes.AsMap())
+	assert.Equal(t, map[string]string{"user": "alice"}, event.Fields)
+
+	_, err = entryToProto(events.Entry{Timestamp: happened, Fields: map[string]any{"ratio": math.Inf(1)}})
+	assert.Error(t, err)
+}
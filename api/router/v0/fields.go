@@ -0,0 +1,173 @@
+package v0
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldOp is a comparison applied to one key of a log entry's fields
+type FieldOp string
+
+const (
+	FieldEquals    FieldOp = "eq"
+	FieldNotEquals FieldOp = "ne"
+	FieldExists    FieldOp = "exists"
+	FieldNotExists FieldOp = "not_exists"
+	FieldPrefix    FieldOp = "prefix"
+	FieldIn        FieldOp = "in"
+
+	// Comparisons only match number values
+	FieldGreater        FieldOp = "gt"
+	FieldGreaterOrEqual FieldOp = "gte"
+	FieldLess           FieldOp = "lt"
+	FieldLessOrEqual    FieldOp = "lte"
+)
+
+// FieldFilter is a condition on one key of a log entry's fields
+type FieldFilter struct {
+	Key    string
+	Op     FieldOp
+	Values []string
+}
+
+const (
+	fieldParamPrefix = "fields."
+	wildcard         = "*"
+)
+
+// parseFieldFilters reads the fields.* parameters of a query string:
+//
+//	fields.user=alice                  user is alice
+//	fields.user=alice&fields.user=bob  user is alice or bob
+//	fields.user=al*                    user starts with al
+//	fields.user=*                      user is present
+//	fields.region!=eu                  region is absent or not eu
+//	fields.region!=*                   region is absent
+//	fields.latency_ms>500              latency_ms is a number above 500
+//	fields.latency_ms>=500             latency_ms is a number of at least 500
+//	fields.latency_ms<500              latency_ms is a number below 500
+//	fields.latency_ms<=500             latency_ms is a number of at most 500
+//
+// A trailing \* stands for a literal asterisk. Values match strings with
+// the same text, and numbers or booleans that the text spells.
+func parseFieldFilters(query url.Values) ([]FieldFilter, error) {
+	params := make([]string, 0, len(query))
+	for param := range query {
+		if strings.HasPrefix(param, fieldParamPrefix) {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	var filters []FieldFilter
+	for _, param := range params {
+		name := strings.TrimPrefix(param, fieldParamPrefix)
+		key, negated := strings.CutSuffix(name, "!")
+		if key == "" || strings.IndexAny(name, "<>") == 0 {
+			return nil, fmt.Errorf("invalid field filter %q: missing field name", param)
+		}
+
+		var parsed []FieldFilter
+		var err error
+		switch {
+		case strings.ContainsAny(name, "<>"):
+			parsed, err = parseComparison(name, query[param])
+		case negated:
+			parsed, err = parseNegatedFieldFilter(key, query[param])
+		default:
+			parsed, err = parseFieldFilter(key, query[param])
+		}
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, parsed...)
+	}
+	return filters, nil
+}
+
+func parseFieldFilter(key string, values []string) ([]FieldFilter, error) {
+	if len(values) == 1 {
+		value, isPrefix := unescapeWildcard(values[0])
+		switch {
+		case values[0] == wildcard:
+			return []FieldFilter{{Key: key, Op: FieldExists}}, nil
+		case isPrefix:
+			return []FieldFilter{{Key: key, Op: FieldPrefix, Values: []string{value}}}, nil
+		default:
+			return []FieldFilter{{Key: key, Op: FieldEquals, Values: []string{value}}}, nil
+		}
+	}
+
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literal, isPrefix := unescapeWildcard(value)
+		if isPrefix {
+			return nil, fmt.Errorf("invalid field filter %s: wildcards cannot be combined with other values", key)
+		}
+		literals = append(literals, literal)
+	}
+	return []FieldFilter{{Key: key, Op: FieldIn, Values: literals}}, nil
+}
+
+func parseNegatedFieldFilter(key string, values []string) ([]FieldFilter, error) {
+	filters := make([]FieldFilter, 0, len(values))
+	for _, value := range values {
+		if value == wildcard {
+			filters = append(filters, FieldFilter{Key: key, Op: FieldNotExists})
+			continue
+		}
+		literal, isPrefix := unescapeWildcard(value)
+		if isPrefix {
+			return nil, fmt.Errorf("invalid field filter %s!=%s: prefixes cannot be negated", key, value)
+		}
+		filters = append(filters, FieldFilter{Key: key, Op: FieldNotEquals, Values: []string{literal}})
+	}
+	return filters, nil
+}
+
+var comparisonOps = map[string]FieldOp{
+	">":  FieldGreater,
+	">=": FieldGreaterOrEqual,
+	"<":  FieldLess,
+	"<=": FieldLessOrEqual,
+}
+
+// parseComparison reads a numeric comparison. The query string splits
+// fields.key>=500 at the equals sign and leaves fields.key>500 without a
+// value, so name holds the key, the operator and any bound after it.
+func parseComparison(name string, values []string) ([]FieldFilter, error) {
+	split := strings.IndexAny(name, "<>")
+	key, operator, bound := name[:split], name[split:split+1], name[split+1:]
+
+	filters := make([]FieldFilter, 0, len(values))
+	for _, value := range values {
+		op, number := operator, bound
+		switch {
+		case bound == "":
+			op, number = operator+"=", value
+		case value != "":
+			return nil, fmt.Errorf("invalid field filter %s=%s: expected a comparison", name, value)
+		}
+		if n, err := strconv.ParseFloat(number, 64); err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, fmt.Errorf("invalid field filter %s%s%s: expected a number", key, op, number)
+		}
+		filters = append(filters, FieldFilter{Key: key, Op: comparisonOps[op], Values: []string{number}})
+	}
+	return filters, nil
+}
+
+// unescapeWildcard strips a trailing wildcard, reporting whether the value
+// is a prefix, and turns a trailing \* into a literal asterisk
+func unescapeWildcard(value string) (string, bool) {
+	if escaped, ok := strings.CutSuffix(value, `\`+wildcard); ok {
+		return escaped + wildcard, false
+	}
+	if prefix, ok := strings.CutSuffix(value, wildcard); ok {
+		return prefix, true
+	}
+	return value, false
+}
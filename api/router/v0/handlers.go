@@ -5,10 +5,11 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jgfranco17/echoris/api/events"
 	"github.com/jgfranco17/echoris/api/httperror"
 	"github.com/jgfranco17/echoris/api/logging"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type HttpHandler func(c *gin.Context) error
@@ -16,52 +17,87 @@ type HttpHandler func(c *gin.Context) error
 func getLogs(client LogClient) HttpHandler {
 	return func(c *gin.Context) error {
 		logger := logging.FromContext(c)
-		service := c.Query("service")
-		level := c.Query("level")
+		query, err := parseLogQuery(c)
+		if err != nil {
+			return httperror.New(c, http.StatusBadRequest, "%s", err.Error())
+		}
 
 		logger.WithFields(logrus.Fields{
-			"service": service,
-			"level":   level,
+			"service": query.Service,
+			"level":   query.Level,
+			"q":       query.Text,
+			"query":   query.Expr,
+			"fields":  len(query.Fields),
+			"limit":   query.Limit,
 		}).Info("Fetching logs")
 
-		logs, err := client.FetchLogs(context.Background(), service, level)
+		page, err := client.FetchLogs(context.Background(), query)
 		if err != nil {
 			logger.WithError(err).Error("Failed to fetch logs")
+			if status.Code(err) == codes.InvalidArgument {
+				return httperror.New(c, http.StatusBadRequest, "%s", status.Convert(err).Message())
+			}
 			return httperror.New(c, http.StatusInternalServerError, "failed to fetch logs")
 		}
 
-		logger.WithField("count", len(logs)).Info("Successfully fetched logs")
+		logger.WithField("count", len(page.Logs)).Info("Successfully fetched logs")
 
-		c.JSON(http.StatusOK, gin.H{
-			"logs": logs,
-		})
+		response := gin.H{
+			"logs": page.Logs,
+		}
+		if page.NextPageToken != "" {
+			response["next_page_token"] = page.NextPageToken
+		}
+		c.JSON(http.StatusOK, response)
 		return nil
 	}
 }
 
 func postLogs(client LogClient) HttpHandler {
 	return func(c *gin.Context) error {
-		var batch []events.Entry
-		if err := c.ShouldBindJSON(&batch); err != nil {
+		body, err := c.GetRawData()
+		if err != nil {
+			return httperror.New(c, http.StatusBadRequest, "failed to read request body")
+		}
+		batch, err := decodeBatch(body)
+		if err != nil {
 			return httperror.New(c, http.StatusBadRequest, "invalid JSON body")
 		}
 
 		// Get logger from context
 		logger := logging.FromContext(c)
 
-		logger.WithField("count", len(batch)).Info("Forwarding logs")
+		logger.WithFields(logrus.Fields{
+			"count":    len(batch.entries),
+			"rejected": len(batch.errors),
+		}).Info("Forwarding logs")
 
 		// Forward to gRPC worker service
-		if err := client.ForwardLogs(context.Background(), batch); err != nil {
-			logger.WithError(err).Error("Failed to forward logs")
-			return httperror.New(c, http.StatusInternalServerError, "failed to forward logs")
+		var forwarded IngestResult
+		if len(batch.entries) > 0 {
+			forwarded, err = client.ForwardLogs(context.Background(), batch.entries)
+			if err != nil {
+				logger.WithError(err).Error("Failed to forward logs")
+				return httperror.New(c, http.StatusInternalServerError, "failed to forward logs")
+			}
 		}
+		result := batch.merge(forwarded)
 
-		logger.Info("Successfully forwarded logs")
+		logger.WithFields(logrus.Fields{
+			"accepted": result.Accepted,
+			"rejected": result.Rejected,
+		}).Info("Forwarded logs")
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Logs forwarded successfully",
-		})
+		status, message := ingestStatus(result)
+		response := gin.H{
+			"message":  message,
+			"accepted": result.Accepted,
+			"rejected": result.Rejected,
+		}
+		if len(result.Errors) > 0 {
+			response["errors"] = result.Errors
+		}
+		c.JSON(status, response)
 		return nil
 	}
 }
@@ -0,0 +1,103 @@
+package v0
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+)
+
+// postedEntry is a log entry as posted, before its timestamp is parsed
+type postedEntry struct {
+	Timestamp string         `json:"timestamp"`
+	Service   string         `json:"service"`
+	Level     string         `json:"level"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields"`
+}
+
+// postedBatch holds the posted entries that could be decoded, the index
+// of each in the request body, and why the others were rejected
+type postedBatch struct {
+	entries []events.Entry
+	indices []int
+	errors  []EventError
+}
+
+// decodeBatch decodes a JSON array of log entries, rejecting malformed
+// entries individually
+func decodeBatch(body []byte) (postedBatch, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(body, &elements); err != nil {
+		return postedBatch{}, err
+	}
+
+	var batch postedBatch
+	for i, element := range elements {
+		entry, err := decodeEntry(element)
+		if err != nil {
+			batch.errors = append(batch.errors, EventError{Index: i, Reason: err.Error()})
+			continue
+		}
+		batch.entries = append(batch.entries, entry)
+		batch.indices = append(batch.indices, i)
+	}
+	return batch, nil
+}
+
+func decodeEntry(element json.RawMessage) (events.Entry, error) {
+	var posted postedEntry
+	if err := json.Unmarshal(element, &posted); err != nil {
+		return events.Entry{}, errors.New("invalid log entry, expected string values and a fields object")
+	}
+	if posted.Timestamp == "" {
+		return events.Entry{}, errors.New("timestamp is required")
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, posted.Timestamp)
+	if err != nil {
+		return events.Entry{}, fmt.Errorf("invalid timestamp %q, expected RFC 3339", posted.Timestamp)
+	}
+	return events.Entry{
+		Timestamp: timestamp,
+		Service:   posted.Service,
+		Level:     posted.Level,
+		Message:   posted.Message,
+		Fields:    posted.Fields,
+	}, nil
+}
+
+// merge combines the entries rejected while decoding with the result of
+// forwarding the rest, reporting errors by index in the request body
+func (b postedBatch) merge(forwarded IngestResult) IngestResult {
+	result := IngestResult{
+		Accepted: forwarded.Accepted,
+		Rejected: forwarded.Rejected + int64(len(b.errors)),
+		Errors:   append([]EventError(nil), b.errors...),
+	}
+	for _, e := range forwarded.Errors {
+		if e.Index >= 0 && e.Index < len(b.indices) {
+			e.Index = b.indices[e.Index]
+		}
+		result.Errors = append(result.Errors, e)
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Index < result.Errors[j].Index
+	})
+	return result
+}
+
+// ingestStatus is 200 when every entry was stored, 207 when only some
+// were and 400 when none were
+func ingestStatus(result IngestResult) (int, string) {
+	switch {
+	case result.Rejected == 0:
+		return http.StatusOK, "Logs forwarded successfully"
+	case result.Accepted > 0:
+		return http.StatusMultiStatus, "Some logs were rejected"
+	}
+	return http.StatusBadRequest, "All logs were rejected"
+}
@@ -0,0 +1,43 @@
+package v0
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgfranco17/echoris/api/httperror"
+	"github.com/jgfranco17/echoris/api/logging"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// listCatalog lists the distinct values of kind among logs in the
+// requested window, with their counts and first and last times seen
+func listCatalog(client LogClient, kind CatalogKind) HttpHandler {
+	return func(c *gin.Context) error {
+		logger := logging.FromContext(c)
+		query, err := parseCatalogQuery(c, kind)
+		if err != nil {
+			return httperror.New(c, http.StatusBadRequest, "%s", err.Error())
+		}
+
+		logger.WithFields(logrus.Fields{
+			"kind":  kind,
+			"limit": query.Limit,
+		}).Info("Listing log catalog")
+
+		result, err := client.ListCatalog(context.Background(), query)
+		if err != nil {
+			logger.WithError(err).Error("Failed to list log catalog")
+			if status.Code(err) == codes.InvalidArgument {
+				return httperror.New(c, http.StatusBadRequest, "%s", status.Convert(err).Message())
+			}
+			return httperror.New(c, http.StatusInternalServerError, "failed to list %s", kind)
+		}
+
+		logger.WithField("values", len(result.Values)).Info("Successfully listed log catalog")
+		c.JSON(http.StatusOK, result)
+		return nil
+	}
+}
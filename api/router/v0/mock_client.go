@@ -13,18 +13,34 @@ type MockLogClient struct {
 }
 
 // ForwardLogs sends a batch of log entries (mock implementation)
-func (m *MockLogClient) ForwardLogs(ctx context.Context, batch []events.Entry) error {
+func (m *MockLogClient) ForwardLogs(ctx context.Context, batch []events.Entry) (IngestResult, error) {
 	args := m.Called(ctx, batch)
-	return args.Error(0)
+	return args.Get(0).(IngestResult), args.Error(1)
+}
+
+// FetchLogs retrieves a page of log entries (mock implementation)
+func (m *MockLogClient) FetchLogs(ctx context.Context, query LogQuery) (LogPage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(LogPage), args.Error(1)
+}
+
+// TailLogs opens a live tail of log entries (mock implementation)
+func (m *MockLogClient) TailLogs(ctx context.Context, query TailQuery) (LogTail, error) {
+	args := m.Called(ctx, query)
+	tail, _ := args.Get(0).(LogTail)
+	return tail, args.Error(1)
+}
+
+// AggregateLogs counts log entries by group and time bucket (mock implementation)
+func (m *MockLogClient) AggregateLogs(ctx context.Context, query AggregateQuery) (AggregateResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(AggregateResult), args.Error(1)
 }
 
-// FetchLogs retrieves log entries (mock implementation)
-func (m *MockLogClient) FetchLogs(ctx context.Context, service, level string) ([]events.Entry, error) {
-	args := m.Called(ctx, service, level)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]events.Entry), args.Error(1)
+// ListCatalog lists distinct values (mock implementation)
+func (m *MockLogClient) ListCatalog(ctx context.Context, query CatalogQuery) (CatalogResult, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(CatalogResult), args.Error(1)
 }
 
 // Close closes the connection (mock implementation)
@@ -20,10 +20,11 @@ func TestMockLogClient_ForwardLogs(t *testing.T) {
 			{Service: "test", Level: "debug", Message: "test2"},
 		}
 
-		mockClient.On("ForwardLogs", mock.Anything, batch).Return(nil)
+		mockClient.On("ForwardLogs", mock.Anything, batch).Return(v0.IngestResult{Accepted: 2}, nil)
 
-		err := mockClient.ForwardLogs(context.Background(), batch)
+		result, err := mockClient.ForwardLogs(context.Background(), batch)
 		assert.NoError(t, err)
+		assert.Equal(t, int64(2), result.Accepted)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -35,9 +36,9 @@ func TestMockLogClient_ForwardLogs(t *testing.T) {
 		}
 		expectedError := errors.New("failed to forward logs")
 
-		mockClient.On("ForwardLogs", mock.Anything, batch).Return(expectedError)
+		mockClient.On("ForwardLogs", mock.Anything, batch).Return(v0.IngestResult{}, expectedError)
 
-		err := mockClient.ForwardLogs(context.Background(), batch)
+		_, err := mockClient.ForwardLogs(context.Background(), batch)
 		assert.Error(t, err)
 		assert.Equal(t, expectedError, err)
 		mockClient.AssertExpectations(t)
@@ -46,10 +47,10 @@ func TestMockLogClient_ForwardLogs(t *testing.T) {
 	t.Run("forward with any batch", func(t *testing.T) {
 		mockClient := new(v0.MockLogClient)
 
-		mockClient.On("ForwardLogs", mock.Anything, mock.AnythingOfType("[]events.Entry")).Return(nil)
+		mockClient.On("ForwardLogs", mock.Anything, mock.AnythingOfType("[]events.Entry")).Return(v0.IngestResult{Accepted: 1}, nil)
 
 		batch := []events.Entry{{Service: "test", Level: "info", Message: "test"}}
-		err := mockClient.ForwardLogs(context.Background(), batch)
+		_, err := mockClient.ForwardLogs(context.Background(), batch)
 		assert.NoError(t, err)
 		mockClient.AssertExpectations(t)
 	})
@@ -64,12 +65,13 @@ func TestMockLogClient_FetchLogs(t *testing.T) {
 			{Service: "test", Level: "info", Message: "log2"},
 		}
 
-		mockClient.On("FetchLogs", mock.Anything, "test", "info").Return(expectedLogs, nil)
+		mockClient.On("FetchLogs", mock.Anything, v0.LogQuery{Service: "test", Level: "info"}).Return(v0.LogPage{Logs: expectedLogs, NextPageToken: "next"}, nil)
 
-		logs, err := mockClient.FetchLogs(context.Background(), "test", "info")
+		page, err := mockClient.FetchLogs(context.Background(), v0.LogQuery{Service: "test", Level: "info"})
 		assert.NoError(t, err)
-		assert.Equal(t, expectedLogs, logs)
-		assert.Len(t, logs, 2)
+		assert.Equal(t, expectedLogs, page.Logs)
+		assert.Len(t, page.Logs, 2)
+		assert.Equal(t, "next", page.NextPageToken)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -77,11 +79,11 @@ func TestMockLogClient_FetchLogs(t *testing.T) {
 		mockClient := new(v0.MockLogClient)
 
 		expectedError := errors.New("failed to fetch logs")
-		mockClient.On("FetchLogs", mock.Anything, "test", "error").Return(nil, expectedError)
+		mockClient.On("FetchLogs", mock.Anything, v0.LogQuery{Service: "test", Level: "error"}).Return(v0.LogPage{}, expectedError)
 
-		logs, err := mockClient.FetchLogs(context.Background(), "test", "error")
+		page, err := mockClient.FetchLogs(context.Background(), v0.LogQuery{Service: "test", Level: "error"})
 		assert.Error(t, err)
-		assert.Nil(t, logs)
+		assert.Nil(t, page.Logs)
 		assert.Equal(t, expectedError, err)
 		mockClient.AssertExpectations(t)
 	})
@@ -90,11 +92,12 @@ func TestMockLogClient_FetchLogs(t *testing.T) {
 		mockClient := new(v0.MockLogClient)
 
 		emptyLogs := []events.Entry{}
-		mockClient.On("FetchLogs", mock.Anything, "test", "debug").Return(emptyLogs, nil)
+		mockClient.On("FetchLogs", mock.Anything, v0.LogQuery{Service: "test", Level: "debug"}).Return(v0.LogPage{Logs: emptyLogs}, nil)
 
-		logs, err := mockClient.FetchLogs(context.Background(), "test", "debug")
+		page, err := mockClient.FetchLogs(context.Background(), v0.LogQuery{Service: "test", Level: "debug"})
 		assert.NoError(t, err)
-		assert.Empty(t, logs)
+		assert.Empty(t, page.Logs)
+		assert.Empty(t, page.NextPageToken)
 		mockClient.AssertExpectations(t)
 	})
 }
@@ -128,11 +131,11 @@ func TestMockLogClient_AssertCalled(t *testing.T) {
 		mockClient := new(v0.MockLogClient)
 
 		batch := []events.Entry{{Service: "test", Level: "info", Message: "test"}}
-		mockClient.On("ForwardLogs", mock.Anything, batch).Return(nil).Times(3)
+		mockClient.On("ForwardLogs", mock.Anything, batch).Return(v0.IngestResult{Accepted: 1}, nil).Times(3)
 
 		// Call it 3 times
 		for i := 0; i < 3; i++ {
-			err := mockClient.ForwardLogs(context.Background(), batch)
+			_, err := mockClient.ForwardLogs(context.Background(), batch)
 			assert.NoError(t, err)
 		}
 
@@ -0,0 +1,209 @@
+package v0
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgfranco17/echoris/service/querylang"
+)
+
+// MaxLimit caps the number of logs a single request may ask for
+const MaxLimit = 1000
+
+// MaxAggregateLimit caps the number of buckets an aggregation may ask for
+const MaxAggregateLimit = 10000
+
+// MaxCatalogLimit caps the number of values a catalog may ask for
+const MaxCatalogLimit = 10000
+
+// parseLogQuery reads the filters of GET /v0/logs from the query string
+func parseLogQuery(c *gin.Context) (LogQuery, error) {
+	query, err := parseFilters(c)
+	if err != nil {
+		return query, err
+	}
+	query.PageToken = c.Query("page_token")
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > MaxLimit {
+			return query, fmt.Errorf("invalid limit %q, expected a positive integer up to %d", limit, MaxLimit)
+		}
+		query.Limit = parsed
+	}
+
+	ascending, err := parseOrder(c.Query("order"))
+	if err != nil {
+		return query, err
+	}
+	query.Ascending = ascending
+	return query, nil
+}
+
+// parseFilters reads the parameters that select logs, shared by every
+// logs endpoint
+func parseFilters(c *gin.Context) (LogQuery, error) {
+	query := LogQuery{
+		Service: c.Query("service"),
+		Level:   c.Query("level"),
+		Text:    c.Query("q"),
+		Expr:    c.Query("query"),
+	}
+
+	// The worker parses the query too; parsing it here reports syntax
+	// errors without a round trip
+	if query.Expr != "" {
+		if _, err := querylang.Parse(query.Expr); err != nil {
+			return query, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+
+	if rank := c.Query("rank"); rank != "" {
+		parsed, err := strconv.ParseBool(rank)
+		if err != nil {
+			return query, fmt.Errorf("invalid rank value %q, expected true or false", rank)
+		}
+		query.Rank = parsed
+	}
+
+	if err := parseTimeRange(c, &query, time.Now()); err != nil {
+		return query, err
+	}
+
+	fields, err := parseFieldFilters(c.Request.URL.Query())
+	if err != nil {
+		return query, err
+	}
+	query.Fields = fields
+
+	return query, nil
+}
+
+// parseOrder reports whether order asks for the oldest logs first
+func parseOrder(order string) (bool, error) {
+	switch order {
+	case "", "desc":
+		return false, nil
+	case "asc":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid order %q, expected asc or desc", order)
+}
+
+// parseTimeRange reads the start and end parameters into query, resolving
+// relative times against now
+func parseTimeRange(c *gin.Context, query *LogQuery, now time.Time) error {
+	if start := c.Query("start"); start != "" {
+		parsed, err := parseTimeParam(start, now)
+		if err != nil {
+			return fmt.Errorf("invalid start: %w", err)
+		}
+		query.Start = &parsed
+	}
+	if end := c.Query("end"); end != "" {
+		parsed, err := parseTimeParam(end, now)
+		if err != nil {
+			return fmt.Errorf("invalid end: %w", err)
+		}
+		query.End = &parsed
+	}
+	if query.Start != nil && query.End != nil && query.Start.After(*query.End) {
+		return fmt.Errorf("start must not be after end")
+	}
+	return nil
+}
+
+// parseTailQuery reads the filters of GET /v0/logs/tail from the query
+// string and the resume position from the Last-Event-ID header
+func parseTailQuery(c *gin.Context) (TailQuery, error) {
+	query, err := parseLogQuery(c)
+	if err != nil {
+		return TailQuery{}, err
+	}
+	if query.PageToken != "" {
+		return TailQuery{}, fmt.Errorf("page_token does not apply to a tail")
+	}
+	if query.Rank {
+		return TailQuery{}, fmt.Errorf("rank does not apply to a tail")
+	}
+
+	tail := TailQuery{Query: query, Cursor: c.GetHeader("Last-Event-ID")}
+	if tail.Cursor == "" {
+		tail.Cursor = c.Query("last_event_id")
+	}
+
+	if replay := c.Query("replay"); replay != "" {
+		parsed, err := strconv.Atoi(replay)
+		if err != nil || parsed < 0 || parsed > MaxLimit {
+			return TailQuery{}, fmt.Errorf("invalid replay %q, expected an integer from 0 to %d", replay, MaxLimit)
+		}
+		tail.Replay = parsed
+	}
+	return tail, nil
+}
+
+// parseAggregateQuery reads the filters, dimensions and interval of
+// GET /v0/logs/aggregate from the query string. Dimensions are given as
+// repeated or comma-separated group_by parameters.
+func parseAggregateQuery(c *gin.Context) (AggregateQuery, error) {
+	query, err := parseFilters(c)
+	if err != nil {
+		return AggregateQuery{}, err
+	}
+	if query.Rank {
+		return AggregateQuery{}, fmt.Errorf("rank does not apply to an aggregation")
+	}
+	for _, param := range []string{"page_token", "order"} {
+		if c.Query(param) != "" {
+			return AggregateQuery{}, fmt.Errorf("%s does not apply to an aggregation", param)
+		}
+	}
+
+	aggregate := AggregateQuery{Query: query}
+	for _, param := range c.QueryArray("group_by") {
+		for _, dimension := range strings.Split(param, ",") {
+			if dimension = strings.TrimSpace(dimension); dimension != "" {
+				aggregate.GroupBy = append(aggregate.GroupBy, dimension)
+			}
+		}
+	}
+
+	if interval := c.Query("interval"); interval != "" {
+		parsed, err := parseRelativeDuration(interval)
+		if err != nil || parsed < time.Second {
+			return AggregateQuery{}, fmt.Errorf("invalid interval %q, expected a duration of at least 1s such as 1m or 1d", interval)
+		}
+		aggregate.Interval = parsed
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > MaxAggregateLimit {
+			return AggregateQuery{}, fmt.Errorf("invalid limit %q, expected a positive integer up to %d", limit, MaxAggregateLimit)
+		}
+		aggregate.Limit = parsed
+	}
+	return aggregate, nil
+}
+
+// parseCatalogQuery reads the window and limit of the GET /v0/meta
+// endpoints from the query string
+func parseCatalogQuery(c *gin.Context, kind CatalogKind) (CatalogQuery, error) {
+	var window LogQuery
+	if err := parseTimeRange(c, &window, time.Now()); err != nil {
+		return CatalogQuery{}, err
+	}
+	query := CatalogQuery{Kind: kind, Start: window.Start, End: window.End}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > MaxCatalogLimit {
+			return CatalogQuery{}, fmt.Errorf("invalid limit %q, expected a positive integer up to %d", limit, MaxCatalogLimit)
+		}
+		query.Limit = parsed
+	}
+	return query, nil
+}
@@ -10,6 +10,11 @@ import (
 func SetRoutes(route *gin.Engine, client LogClient) error {
 	v0 := route.Group("/v0")
 	v0.GET("/logs", httperror.WithErrorHandling(getLogs(client)))
+	v0.GET("/logs/tail", httperror.WithErrorHandling(tailLogs(client)))
+	v0.GET("/logs/aggregate", httperror.WithErrorHandling(aggregateLogs(client)))
+	v0.GET("/meta/services", httperror.WithErrorHandling(listCatalog(client, CatalogServices)))
+	v0.GET("/meta/levels", httperror.WithErrorHandling(listCatalog(client, CatalogLevels)))
+	v0.GET("/meta/fields", httperror.WithErrorHandling(listCatalog(client, CatalogFieldKeys)))
 	v0.POST("/logs", httperror.WithErrorHandling(postLogs(client)))
 	return nil
 }
@@ -0,0 +1,123 @@
+package v0
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jgfranco17/echoris/api/httperror"
+	"github.com/jgfranco17/echoris/api/logging"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TailHeartbeatInterval is how often an idle tail sends a comment to keep
+// proxies from closing the connection
+var TailHeartbeatInterval = 15 * time.Second
+
+// tailLogs streams matching logs as Server-Sent Events. Each log is sent
+// as a JSON message whose id resumes the tail through Last-Event-ID.
+func tailLogs(client LogClient) HttpHandler {
+	return func(c *gin.Context) error {
+		logger := logging.FromContext(c)
+		query, err := parseTailQuery(c)
+		if err != nil {
+			return httperror.New(c, http.StatusBadRequest, "%s", err.Error())
+		}
+
+		logger.WithFields(logrus.Fields{
+			"service": query.Query.Service,
+			"level":   query.Query.Level,
+			"replay":  query.Replay,
+			"resume":  query.Cursor != "",
+		}).Info("Tailing logs")
+
+		// The tail ends when the client disconnects
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		tail, err := client.TailLogs(ctx, query)
+		if err != nil {
+			logger.WithError(err).Error("Failed to tail logs")
+			return httperror.New(c, http.StatusInternalServerError, "failed to tail logs")
+		}
+
+		received := make(chan TailEvent)
+		failed := make(chan error, 1)
+		go receiveTail(ctx, tail, received, failed)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(TailHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Tail client disconnected")
+				return nil
+			case event := <-received:
+				if err := writeLogEvent(c.Writer, event); err != nil {
+					return nil
+				}
+			case err := <-failed:
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					logger.WithError(err).Error("Tail ended")
+					writeErrorEvent(c.Writer, err)
+				}
+				return nil
+			case <-heartbeat.C:
+				fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// receiveTail forwards the logs of tail until it fails or ctx is done
+func receiveTail(ctx context.Context, tail LogTail, received chan<- TailEvent, failed chan<- error) {
+	for {
+		event, err := tail.Recv()
+		if err != nil {
+			failed <- err
+			return
+		}
+		select {
+		case received <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func writeLogEvent(w io.Writer, event TailEvent) error {
+	data, err := json.Marshal(event.Entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", event.Cursor, data)
+	return err
+}
+
+// writeErrorEvent reports why the tail ended, passing through the worker's
+// message for errors the client can act on
+func writeErrorEvent(w io.Writer, err error) {
+	message := "tail failed"
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.ResourceExhausted, codes.Unavailable:
+		message = status.Convert(err).Message()
+	}
+	data, _ := json.Marshal(gin.H{"message": message})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+}
@@ -0,0 +1,55 @@
+package v0
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTimeParam parses a start or end query parameter. Values are either
+// RFC 3339 timestamps or relative to now, such as "now", "now-15m" or
+// "now-7d".
+func parseTimeParam(value string, now time.Time) (time.Time, error) {
+	if !strings.HasPrefix(value, "now") {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or now[+-]duration", value)
+		}
+		return parsed, nil
+	}
+
+	offset := strings.TrimPrefix(value, "now")
+	if offset == "" {
+		return now, nil
+	}
+	sign := offset[0]
+	if sign != '-' && sign != '+' {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or now[+-]duration", value)
+	}
+	duration, err := parseRelativeDuration(offset[1:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	if sign == '-' {
+		duration = -duration
+	}
+	return now.Add(duration), nil
+}
+
+// parseRelativeDuration parses a Go duration or a whole number of days
+// such as "7d"
+func parseRelativeDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
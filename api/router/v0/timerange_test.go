@@ -0,0 +1,42 @@
+package v0
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"2024-05-31T08:30:00Z", time.Date(2024, 5, 31, 8, 30, 0, 0, time.UTC)},
+		{"2024-05-31T08:30:00.5+02:00", time.Date(2024, 5, 31, 6, 30, 0, 500000000, time.UTC)},
+		{"now", now},
+		{"now-15m", now.Add(-15 * time.Minute)},
+		{"now+1h30m", now.Add(90 * time.Minute)},
+		{"now-7d", now.Add(-7 * 24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			parsed, err := parseTimeParam(tt.value, now)
+			require.NoError(t, err)
+			assert.True(t, tt.expected.Equal(parsed), "expected %s, got %s", tt.expected, parsed)
+		})
+	}
+}
+
+func TestParseTimeParamInvalid(t *testing.T) {
+	now := time.Now()
+	for _, value := range []string{"yesterday", "2024-05-31", "now15m", "now-", "now-abc", "now--5m", "now-1.5d"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseTimeParam(value, now)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/shipper"
+)
+
+// Options configure Run
+type Options struct {
+	Checkpoint   string        // File where checkpoints are saved
+	PollInterval time.Duration // How often files are checked for new lines
+	Ship         shipper.Options
+	OnReject     func(source string, reason string) // Called for lines that cannot be parsed
+	OnError      func(path string, err error)       // Called for files that cannot be read or checkpoints that cannot be saved
+}
+
+// Run watches the files of config in fsys and ships their new lines with
+// sender until ctx is done. A file's checkpoint only moves past lines once
+// the server answered for them, or they were queued, so that after a
+// restart lines are neither lost nor shipped twice.
+func Run(ctx context.Context, fsys fs.FS, sender shipper.Sender, config Config, opts Options) error {
+	parsers := make([]LineParser, len(config.Sources))
+	for i, source := range config.Sources {
+		parser, err := source.NewParser()
+		if err != nil {
+			return fmt.Errorf("invalid source %d: %w", i+1, err)
+		}
+		parsers[i] = parser
+	}
+
+	checkpoints, err := LoadCheckpoints(opts.Checkpoint)
+	if err != nil {
+		return err
+	}
+	progress := &progress{name: opts.Checkpoint, checkpoints: checkpoints, onError: opts.OnError}
+	watcher := NewWatcher(fsys, config.Sources, checkpoints)
+	watcher.OnError = opts.OnError
+	watcher.OnDrop = progress.drop
+	defer watcher.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	items := make(chan shipper.Item)
+	watchErr := make(chan error, 1)
+	go func() {
+		defer close(items)
+		watchErr <- watch(ctx, watcher, parsers, opts, progress, items)
+	}()
+
+	// With a queue, lines are safe once queued rather than once sent
+	shipOpts := opts.Ship
+	if shipOpts.Queue != nil {
+		shipOpts.OnQueued = func(n int) {
+			progress.shipped(n)
+			if opts.Ship.OnQueued != nil {
+				opts.Ship.OnQueued(n)
+			}
+		}
+	} else {
+		shipOpts.OnReport = func(report shipper.Report) {
+			progress.shipped(len(report.Items))
+			if opts.Ship.OnReport != nil {
+				opts.Ship.OnReport(report)
+			}
+		}
+	}
+	shipErr := shipper.Ship(ctx, sender, items, shipOpts)
+	cancel()
+	// The watcher only ends once ctx is done, which is not a failure
+	err = <-watchErr
+	if shipErr != nil && !errors.Is(shipErr, context.Canceled) {
+		return shipErr
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// watch polls the watcher, parsing lines onto items, until ctx is done
+func watch(ctx context.Context, watcher *Watcher, parsers []LineParser, opts Options, progress *progress, items chan<- shipper.Item) error {
+	emit := func(line Line) error {
+		source := fmt.Sprintf("%s:%d", line.Path, line.Number)
+		entry, err := parsers[line.Source](line.Text)
+		if err != nil {
+			if opts.OnReject != nil {
+				opts.OnReject(source, err.Error())
+			}
+			return nil
+		}
+		progress.add(line)
+		select {
+		case items <- shipper.Item{Entry: entry, Source: source}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		if err := watcher.Poll(emit); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(opts.PollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// progress tracks the lines handed to the shipper, in order, and saves
+// the checkpoints of those shipped
+type progress struct {
+	mu          sync.Mutex
+	name        string
+	checkpoints Checkpoints
+	pending     []Line
+	onError     func(path string, err error)
+}
+
+func (p *progress) add(line Line) {
+	line.Text = ""
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, line)
+}
+
+// shipped moves the checkpoints past the next n lines and saves them
+func (p *progress) shipped(n int) {
+	p.mu.Lock()
+	n = min(n, len(p.pending))
+	for _, line := range p.pending[:n] {
+		if line.File != "" {
+			p.checkpoints[line.File] = Checkpoint{Path: line.Path, Offset: line.Offset, Line: line.Number}
+		}
+	}
+	p.pending = p.pending[n:]
+	err := p.checkpoints.Save(p.name)
+	p.mu.Unlock()
+
+	if err != nil && p.onError != nil {
+		p.onError(p.name, err)
+	}
+}
+
+// drop forgets the checkpoint of a file no longer watched, including
+// that of its lines still being shipped
+func (p *progress) drop(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.checkpoints, id)
+	for i := range p.pending {
+		if p.pending[i].File == id {
+			p.pending[i].File = ""
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/jgfranco17/echoris/cli/agent"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender records the messages sent, failing the first failures sends
+type fakeSender struct {
+	mu       sync.Mutex
+	messages []string
+	failures int
+}
+
+func (s *fakeSender) ForwardLogs(ctx context.Context, batch []events.Entry) (v0.IngestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return v0.IngestResult{}, errors.New("connection refused")
+	}
+	for _, entry := range batch {
+		s.messages = append(s.messages, entry.Message)
+	}
+	return v0.IngestResult{Accepted: int64(len(batch))}, nil
+}
+
+func (s *fakeSender) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// runUntil runs the agent until sender has sent count messages
+func runUntil(t *testing.T, fsys fstest.MapFS, sender *fakeSender, opts agent.Options, count int) {
+	t.Helper()
+	config := agent.Config{Sources: []agent.Source{{Paths: []string{"logs/*.log"}, Parser: agent.ParserJSON}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- agent.Run(ctx, fsys, sender, config, opts)
+	}()
+	assert.Eventually(t, func() bool { return len(sender.sent()) >= count }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func testOptions(t *testing.T) agent.Options {
+	return agent.Options{
+		Checkpoint:   filepath.Join(t.TempDir(), "checkpoints.json"),
+		PollInterval: 5 * time.Millisecond,
+		Ship: shipper.Options{
+			BatchSize:     2,
+			FlushInterval: 5 * time.Millisecond,
+			Retry:         shipper.RetryPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		},
+	}
+}
+
+func TestRunResumesFromCheckpoints(t *testing.T) {
+	log := &fstest.MapFile{Data: []byte(`{"message": "a"}` + "\n" + `{"message": "b"}` + "\nnot json\n")}
+	fsys := fstest.MapFS{"logs/app.log": log}
+	opts := testOptions(t)
+	var rejected []string
+	opts.OnReject = func(source string, reason string) { rejected = append(rejected, source+": "+reason) }
+	sender := &fakeSender{}
+
+	runUntil(t, fsys, sender, opts, 2)
+	assert.Equal(t, []string{"a", "b"}, sender.sent())
+	assert.Equal(t, []string{"logs/app.log:3: invalid log entry, expected a JSON object"}, rejected)
+	checkpoints, err := agent.LoadCheckpoints(opts.Checkpoint)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 1)
+	for _, checkpoint := range checkpoints {
+		assert.Equal(t, agent.Checkpoint{Path: "logs/app.log", Offset: 34, Line: 2}, checkpoint)
+	}
+
+	appendTo(log, `{"message": "c"}`+"\n")
+	runUntil(t, fsys, sender, opts, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, sender.sent(), "lines shipped before the restart are not shipped again")
+}
+
+func TestRunRetriesThroughOutages(t *testing.T) {
+	fsys := fstest.MapFS{"logs/app.log": {Data: []byte(`{"message": "a"}` + "\n" + `{"message": "b"}` + "\n")}}
+	sender := &fakeSender{failures: 5}
+	runUntil(t, fsys, sender, testOptions(t), 2)
+	assert.Equal(t, []string{"a", "b"}, sender.sent())
+}
+
+func TestCheckpoints(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "checkpoints.json")
+	checkpoints, err := agent.LoadCheckpoints(name)
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints, "a missing file has no checkpoints")
+
+	checkpoints["abc"] = agent.Checkpoint{Path: "app.log", Offset: 10, Line: 2}
+	require.NoError(t, checkpoints.Save(name))
+	loaded, err := agent.LoadCheckpoints(name)
+	require.NoError(t, err)
+	assert.Equal(t, checkpoints, loaded)
+
+	matches, err := filepath.Glob(name + ".*")
+	require.NoError(t, err)
+	assert.Empty(t, matches, "no temporary file is left behind")
+}
+
+func TestRunWithQueue(t *testing.T) {
+	fsys := fstest.MapFS{"logs/app.log": {Data: []byte(`{"message": "a"}` + "\n" + `{"message": "b"}` + "\n")}}
+	opts := testOptions(t)
+	dir := t.TempDir()
+	down := &fakeSender{failures: 1000}
+
+	queue, err := shipper.OpenQueue(dir, shipper.QueueOptions{Sync: shipper.SyncAlways})
+	require.NoError(t, err)
+	opts.Ship.Queue = queue
+	config := agent.Config{Sources: []agent.Source{{Paths: []string{"logs/*.log"}, Parser: agent.ParserJSON}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- agent.Run(ctx, fsys, down, config, opts)
+	}()
+	assert.Eventually(t, func() bool { return queue.Len() == 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, queue.Close())
+	checkpoints, err := agent.LoadCheckpoints(opts.Checkpoint)
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, 1, "lines are checkpointed once queued")
+
+	queue, err = shipper.OpenQueue(dir, shipper.QueueOptions{Sync: shipper.SyncAlways})
+	require.NoError(t, err)
+	defer queue.Close()
+	opts.Ship.Queue = queue
+	up := &fakeSender{}
+	runUntil(t, fsys, up, opts, 2)
+	assert.Equal(t, []string{"a", "b"}, up.sent(), "queued lines are sent by the next run, once")
+}
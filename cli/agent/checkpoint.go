@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is how far a file was shipped
+type Checkpoint struct {
+	Path   string `json:"path"`   // Where the file was last seen, for reference
+	Offset int64  `json:"offset"` // End of the last line shipped
+	Line   int    `json:"line"`   // Number of the last line shipped
+}
+
+// Checkpoints are kept per file, keyed by the file's fingerprint so that
+// they follow files that are renamed
+type Checkpoints map[string]Checkpoint
+
+type checkpointFile struct {
+	Files Checkpoints `json:"files"`
+}
+
+// LoadCheckpoints reads the checkpoints saved at name, if any
+func LoadCheckpoints(name string) (Checkpoints, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Checkpoints{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", name, err)
+	}
+	if file.Files == nil {
+		file.Files = Checkpoints{}
+	}
+	return file.Files, nil
+}
+
+// Save writes the checkpoints to name, replacing the previous ones at once
+// so that a crash never leaves a partial file behind
+func (c Checkpoints) Save(name string) error {
+	data, err := json.MarshalIndent(checkpointFile{Files: c}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	if err := os.Rename(temp.Name(), name); err != nil {
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package agent watches log files and ships the lines appended to them,
+// following rotation and remembering how far each file was shipped
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	"github.com/jgfranco17/echoris/cli/shipper"
+)
+
+// Parser kinds of a source
+const (
+	ParserText  = "text"  // Each line is a message
+	ParserJSON  = "json"  // Each line is a JSON entry, as sent by echoris send
+	ParserRegex = "regex" // Named groups of a pattern fill in the entry
+)
+
+// Config is the agent's configuration file
+type Config struct {
+	Sources []Source `json:"sources"`
+}
+
+// Source is a set of files parsed alike
+type Source struct {
+	Paths      []string `json:"paths"`       // Globs relative to the agent root
+	Parser     string   `json:"parser"`      // text, json or regex; text if unset
+	Pattern    string   `json:"pattern"`     // Regular expression of the regex parser
+	TimeLayout string   `json:"time_layout"` // Go layout of the timestamp group; RFC 3339 if unset
+	Service    string   `json:"service"`     // Service of entries that do not set one
+	Level      string   `json:"level"`       // Level of entries that do not set one; info if unset
+}
+
+// ParseConfig decodes and checks a configuration file
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("invalid agent configuration: %w", err)
+	}
+	if len(config.Sources) == 0 {
+		return Config{}, errors.New("invalid agent configuration: no sources")
+	}
+	for i, source := range config.Sources {
+		if err := source.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid source %d: %w", i+1, err)
+		}
+	}
+	return config, nil
+}
+
+func (s Source) validate() error {
+	if len(s.Paths) == 0 {
+		return errors.New("no paths")
+	}
+	for _, pattern := range s.Paths {
+		if path.IsAbs(pattern) {
+			return fmt.Errorf("invalid path %q, expected one relative to the agent root: run the agent with --root / and use %q", pattern, strings.TrimLeft(pattern, "/"))
+		}
+		if !fs.ValidPath(pattern) || pattern == "." {
+			return fmt.Errorf("invalid path %q, expected one relative to the agent root such as logs/*.log", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path %q: %w", pattern, err)
+		}
+	}
+	_, err := s.NewParser()
+	return err
+}
+
+// LineParser makes an entry of a line
+type LineParser func(line string) (events.Entry, error)
+
+// NewParser creates the parser of the source's lines
+func (s Source) NewParser() (LineParser, error) {
+	defaults := shipper.Defaults{Service: s.Service, Level: s.Level}
+	if defaults.Level == "" {
+		defaults.Level = "info"
+	}
+
+	switch s.Parser {
+	case "", ParserText:
+		return func(line string) (events.Entry, error) {
+			return shipper.TextEntry(line, defaults), nil
+		}, nil
+	case ParserJSON:
+		return func(line string) (events.Entry, error) {
+			return shipper.DecodeEntry([]byte(line), defaults)
+		}, nil
+	case ParserRegex:
+		return regexParser(s.Pattern, s.TimeLayout, defaults)
+	}
+	return nil, fmt.Errorf("unknown parser %q, expected text, json or regex", s.Parser)
+}
+
+// regexParser fills in entries from the named groups of pattern: timestamp,
+// service, level and message, with any other group becoming a field. The
+// message is the whole line when there is no message group.
+func regexParser(pattern string, timeLayout string, defaults shipper.Defaults) (LineParser, error) {
+	if pattern == "" {
+		return nil, errors.New("the regex parser needs a pattern")
+	}
+	expr, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if timeLayout == "" {
+		timeLayout = time.RFC3339Nano
+	}
+	names := expr.SubexpNames()
+
+	return func(line string) (events.Entry, error) {
+		match := expr.FindStringSubmatch(line)
+		if match == nil {
+			return events.Entry{}, errors.New("line does not match the pattern")
+		}
+		entry := events.Entry{Message: line}
+		for i, name := range names {
+			if name == "" || i >= len(match) {
+				continue
+			}
+			value := match[i]
+			switch name {
+			case "timestamp":
+				parsed, err := time.Parse(timeLayout, value)
+				if err != nil {
+					return events.Entry{}, fmt.Errorf("invalid timestamp %q, expected the layout %s", value, timeLayout)
+				}
+				entry.Timestamp = parsed
+			case "service":
+				entry.Service = value
+			case "level":
+				entry.Level = value
+			case "message":
+				entry.Message = value
+			default:
+				if entry.Fields == nil {
+					entry.Fields = make(map[string]any)
+				}
+				entry.Fields[name] = value
+			}
+		}
+		defaults.Apply(&entry)
+		return entry, nil
+	}, nil
+}
@@ -0,0 +1,77 @@
+package agent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := agent.ParseConfig([]byte(`{"sources": [{"paths": ["logs/*.log"], "parser": "json", "service": "api"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []agent.Source{{Paths: []string{"logs/*.log"}, Parser: agent.ParserJSON, Service: "api"}}, config.Sources)
+
+	invalid := map[string]string{
+		`{"sources": []}`:   "invalid agent configuration: no sources",
+		`{"sources": [{}]}`: "invalid source 1: no paths",
+		`{"sources": [{"paths": ["/var/log/*.log"]}]}`:                           `invalid source 1: invalid path "/var/log/*.log", expected one relative to the agent root: run the agent with --root / and use "var/log/*.log"`,
+		`{"sources": [{"paths": ["../app.log"]}]}`:                               `invalid source 1: invalid path "../app.log", expected one relative to the agent root such as logs/*.log`,
+		`{"sources": [{"paths": ["logs/[.log"]}]}`:                               `invalid source 1: invalid path "logs/[.log": syntax error in pattern`,
+		`{"sources": [{"paths": ["a.log"], "parser": "xml"}]}`:                   `invalid source 1: unknown parser "xml", expected text, json or regex`,
+		`{"sources": [{"paths": ["a.log"], "parser": "regex"}]}`:                 "invalid source 1: the regex parser needs a pattern",
+		`{"sources": [{"paths": ["a.log"], "parser": "regex", "pattern": "("}]}`: "invalid source 1: invalid pattern: error parsing regexp: missing closing ): `(`",
+	}
+	for data, expected := range invalid {
+		_, err := agent.ParseConfig([]byte(data))
+		assert.EqualError(t, err, expected, data)
+	}
+}
+
+func TestParsers(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		parse, err := agent.Source{Service: "api"}.NewParser()
+		require.NoError(t, err)
+		entry, err := parse("started")
+		require.NoError(t, err)
+		assert.Equal(t, "started", entry.Message)
+		assert.Equal(t, "api", entry.Service)
+		assert.Equal(t, "info", entry.Level)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		parse, err := agent.Source{Parser: agent.ParserJSON, Service: "api", Level: "debug"}.NewParser()
+		require.NoError(t, err)
+		entry, err := parse(`{"level": "error", "message": "failed", "user": "alice"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "error", entry.Level)
+		assert.Equal(t, "api", entry.Service)
+		assert.Equal(t, map[string]any{"user": "alice"}, entry.Fields)
+		_, err = parse("not json")
+		assert.EqualError(t, err, "invalid log entry, expected a JSON object")
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		parse, err := agent.Source{
+			Parser:     agent.ParserRegex,
+			Pattern:    `^(?P<timestamp>\S+ \S+) \[(?P<level>\w+)\] (?P<method>\w+) (?P<message>.*)$`,
+			TimeLayout: "2006-01-02 15:04:05",
+			Service:    "nginx",
+		}.NewParser()
+		require.NoError(t, err)
+		entry, err := parse("2024-05-31 08:00:00 [warn] GET /health took 2s")
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC), entry.Timestamp)
+		assert.Equal(t, "warn", entry.Level)
+		assert.Equal(t, "nginx", entry.Service)
+		assert.Equal(t, "/health took 2s", entry.Message)
+		assert.Equal(t, map[string]any{"method": "GET"}, entry.Fields)
+
+		_, err = parse("garbage")
+		assert.EqualError(t, err, "line does not match the pattern")
+		_, err = parse("yesterday noon [warn] GET /")
+		assert.EqualError(t, err, `invalid timestamp "yesterday noon", expected the layout 2006-01-02 15:04:05`)
+	})
+}
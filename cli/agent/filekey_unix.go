@@ -0,0 +1,19 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// fileKey returns the device and inode of a file, which tell apart files
+// with the same first line, or an empty key when fsys does not expose them
+func fileKey(info fs.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d", stat.Dev, stat.Ino)
+}
@@ -0,0 +1,436 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	fingerprintSize = 1024    // Most of the start of a file that identifies it
+	maxLineSize     = 1 << 20 // Longer lines are split
+	readSize        = 64 * 1024
+)
+
+var errNotSeekable = errors.New("file does not support random access")
+
+// Line is a complete line appended to a watched file
+type Line struct {
+	Source int // Index of the source whose paths matched the file
+	Path   string
+	Number int
+	Text   string
+	File   string // Identity of the file
+	Offset int64  // End of the line in the file
+}
+
+// seekableFile is a file read from an offset, as those of os.DirFS are
+type seekableFile interface {
+	fs.File
+	io.ReaderAt
+	io.Seeker
+}
+
+// watchedFile is a file being read. It is kept open, so that what is
+// appended to it just before it is renamed is still read.
+type watchedFile struct {
+	id      string
+	head    string // Fingerprint of the first line
+	key     string // Device and inode, where available
+	path    string
+	source  int
+	file    seekableFile
+	offset  int64 // End of the last complete line read
+	line    int
+	partial []byte // Start of a line still being written
+}
+
+// match is a file found by a scan
+type match struct {
+	id     string
+	head   string
+	key    string
+	path   string
+	source int
+	size   int64
+}
+
+// Watcher finds the files matching the paths of sources and reads the
+// lines appended to them. Files are told apart by a fingerprint of their
+// first line and, where available, their device and inode: a file renamed
+// by rotation is followed to its new path, while one truncated in place or
+// replaced is read again from its start.
+type Watcher struct {
+	fsys    fs.FS
+	sources []Source
+	resume  Checkpoints
+	files   map[string]*watchedFile
+	failing map[string]bool
+
+	// OnError is called the first time a file cannot be read. It is
+	// skipped until it can.
+	OnError func(path string, err error)
+	// OnDrop is called with the identity of a file no longer watched, as
+	// it was removed or renamed to a path no source matches
+	OnDrop func(id string)
+}
+
+// NewWatcher creates a watcher of the files of sources in fsys. Files
+// with a checkpoint in resume are read from it, others from their start.
+func NewWatcher(fsys fs.FS, sources []Source, resume Checkpoints) *Watcher {
+	return &Watcher{
+		fsys:    fsys,
+		sources: sources,
+		resume:  maps.Clone(resume),
+		files:   make(map[string]*watchedFile),
+		failing: make(map[string]bool),
+	}
+}
+
+// Poll passes every line appended since the last poll to emit, stopping
+// at the first error emit returns
+func (w *Watcher) Poll(emit func(Line) error) error {
+	matches := w.scan()
+	found := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		found[m.id] = true
+	}
+
+	if err := w.finishGone(matches, found, emit); err != nil {
+		return err
+	}
+
+	// Checkpoints of files that were not found will not be needed, unless
+	// the files could not be read
+	for _, id := range slices.Sorted(maps.Keys(w.resume)) {
+		if !found[id] && !w.failing[w.resume[id].Path] {
+			delete(w.resume, id)
+			w.drop(id)
+		}
+	}
+
+	atPath := make(map[string]string, len(matches))
+	for _, m := range matches {
+		atPath[m.path] = m.id
+	}
+	for _, m := range matches {
+		if w.copying(m, atPath) {
+			continue
+		}
+		f, err := w.watch(m)
+		if err != nil {
+			w.fail(m.path, err)
+			continue
+		}
+		if f == nil {
+			continue
+		}
+		if err := w.read(f, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishGone reads the files gone from the paths to their end and drops
+// them. Those truncated meanwhile are read on from their copy, if one was
+// made.
+func (w *Watcher) finishGone(matches []match, found map[string]bool, emit func(Line) error) error {
+	for _, id := range slices.Sorted(maps.Keys(w.files)) {
+		if found[id] {
+			continue
+		}
+		f := w.files[id]
+		if current, err := fingerprint(f.file); err == nil && current == f.head {
+			if err := w.read(f, emit); err != nil {
+				return err
+			}
+		} else if m, ok := w.copyOf(f, matches); ok {
+			delete(w.files, id)
+			f.id, f.key = m.id, m.key
+			w.files[m.id] = f
+			w.drop(id)
+			continue
+		}
+		f.file.Close()
+		delete(w.files, id)
+		w.drop(id)
+	}
+	return nil
+}
+
+// Close closes the files being watched
+func (w *Watcher) Close() error {
+	for _, f := range w.files {
+		f.file.Close()
+	}
+	clear(w.files)
+	return nil
+}
+
+// scan identifies the files matching the paths of sources, in the order
+// of the sources. A file matched by several paths belongs to the first.
+func (w *Watcher) scan() []match {
+	var matches []match
+	seen := make(map[string]bool)
+	byID := make(map[string]int)
+	for i, source := range w.sources {
+		for _, pattern := range source.Paths {
+			paths, _ := fs.Glob(w.fsys, pattern) // Patterns are checked by ParseConfig
+			for _, name := range paths {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+
+				head, key, size, err := identify(w.fsys, name)
+				if err != nil {
+					if !errors.Is(err, fs.ErrNotExist) {
+						w.fail(name, err)
+					}
+					continue
+				}
+				if head == "" {
+					continue
+				}
+				id := fileID(head, key)
+				m := match{id: id, head: head, key: key, path: name, source: i, size: size}
+				if j, ok := byID[id]; ok {
+					// Copies, such as one being made by copytruncate, are read
+					// where the file was read so far
+					if f := w.files[id]; f != nil && f.path == name {
+						m.source = matches[j].source
+						matches[j] = m
+					}
+					continue
+				}
+				byID[id] = len(matches)
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches
+}
+
+// copying reports whether a match is a copy being made of a watched file
+// that is still at its path, as by copytruncate. Copies are told apart
+// from their file by inode only, and are not read.
+func (w *Watcher) copying(m match, atPath map[string]string) bool {
+	if w.files[m.id] != nil {
+		return false
+	}
+	for _, f := range w.files {
+		if f.head == m.head && f.path != m.path && atPath[f.path] == f.id {
+			return true
+		}
+	}
+	return false
+}
+
+// copyOf finds the copy of a file truncated since it was last read: a file
+// with its first line at another path, holding all that was read
+func (w *Watcher) copyOf(f *watchedFile, matches []match) (match, bool) {
+	for _, m := range matches {
+		if m.head == f.head && m.path != f.path && m.size >= f.offset && w.files[m.id] == nil {
+			return m, true
+		}
+	}
+	return match{}, false
+}
+
+// watch returns the watched file of a match, opening it when it is new or
+// was moved. It returns nil when the file changed since it was identified.
+func (w *Watcher) watch(m match) (*watchedFile, error) {
+	f, ok := w.files[m.id]
+	if ok && f.path == m.path {
+		return f, nil
+	}
+	if ok {
+		// Renamed, or copied before being truncated: read on from the new path,
+		// once it holds all that was read
+		if m.size < f.offset {
+			return f, nil
+		}
+		file, err := open(w.fsys, m.path, m.head, m.key, f.offset)
+		if err != nil || file == nil {
+			return nil, err
+		}
+		f.file.Close()
+		f.file, f.path, f.partial = file, m.path, nil
+		return f, nil
+	}
+
+	var offset int64
+	var line int
+	if checkpoint, ok := w.resume[m.id]; ok && checkpoint.Offset <= m.size {
+		offset, line = checkpoint.Offset, checkpoint.Line
+	}
+	file, err := open(w.fsys, m.path, m.head, m.key, offset)
+	if err != nil || file == nil {
+		return nil, err
+	}
+	delete(w.resume, m.id)
+	f = &watchedFile{id: m.id, head: m.head, key: m.key, path: m.path, source: m.source, file: file, offset: offset, line: line}
+	w.files[m.id] = f
+	return f, nil
+}
+
+// read emits the complete lines appended to a file since it was last read
+func (w *Watcher) read(f *watchedFile, emit func(Line) error) error {
+	info, err := f.file.Stat()
+	if err != nil {
+		w.fail(f.path, err)
+		return nil
+	}
+	if info.Size() < f.offset+int64(len(f.partial)) {
+		// Truncated in place, and written again from the same first line
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			w.fail(f.path, err)
+			return nil
+		}
+		f.offset, f.line, f.partial = 0, 0, nil
+	}
+
+	buf := make([]byte, readSize)
+	for {
+		n, err := f.file.Read(buf)
+		f.partial = append(f.partial, buf[:n]...)
+		if err := w.emitLines(f, emit); err != nil {
+			return err
+		}
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			delete(w.failing, f.path)
+			return nil
+		}
+		if err != nil {
+			w.fail(f.path, fmt.Errorf("failed to read file: %w", err))
+			return nil
+		}
+	}
+}
+
+// emitLines emits the complete lines buffered for a file, skipping blank
+// ones
+func (w *Watcher) emitLines(f *watchedFile, emit func(Line) error) error {
+	for {
+		end := bytes.IndexByte(f.partial, '\n') + 1
+		if end == 0 {
+			if len(f.partial) < maxLineSize {
+				return nil
+			}
+			end = len(f.partial)
+		}
+		text := strings.TrimRight(string(f.partial[:end]), "\r\n")
+		f.partial = f.partial[end:]
+		f.offset += int64(end)
+		f.line++
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		line := Line{Source: f.source, Path: f.path, Number: f.line, Text: text, File: f.id, Offset: f.offset}
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Watcher) fail(path string, err error) {
+	if w.failing[path] {
+		return
+	}
+	w.failing[path] = true
+	if w.OnError != nil {
+		w.OnError(path, err)
+	}
+}
+
+func (w *Watcher) drop(id string) {
+	if w.OnDrop != nil {
+		w.OnDrop(id)
+	}
+}
+
+// fileID returns the identity of a file with the given fingerprint and key
+func fileID(head, key string) string {
+	if key == "" {
+		return head
+	}
+	return head + "-" + key
+}
+
+// identify returns the fingerprint, key and size of a file, with an empty
+// fingerprint for directories and files without a complete first line
+func identify(fsys fs.FS, name string) (string, string, int64, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", 0, err
+	}
+	if info.IsDir() {
+		return "", "", 0, nil
+	}
+	seekable, ok := file.(seekableFile)
+	if !ok {
+		return "", "", 0, errNotSeekable
+	}
+	head, err := fingerprint(seekable)
+	return head, fileKey(info), info.Size(), err
+}
+
+// open opens a file at offset, returning nil when its fingerprint or key
+// changed, as it was replaced
+func open(fsys fs.FS, name string, head, key string, offset int64) (seekableFile, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	seekable, ok := file.(seekableFile)
+	if !ok {
+		file.Close()
+		return nil, errNotSeekable
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if current, err := fingerprint(seekable); err != nil || current != head || fileKey(info) != key {
+		file.Close()
+		return nil, err
+	}
+	if _, err := seekable.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+	return seekable, nil
+}
+
+// fingerprint hashes the first line of a file, or its first
+// fingerprintSize bytes when that line is longer. It is empty until
+// either is written.
+func fingerprint(file io.ReaderAt) (string, error) {
+	head := make([]byte, fingerprintSize)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	head = head[:n]
+	if end := bytes.IndexByte(head, '\n'); end >= 0 {
+		head = head[:end+1]
+	} else if n < fingerprintSize {
+		return "", nil
+	}
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,139 @@
+package agent_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jgfranco17/echoris/cli/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poll returns the lines a poll emits, as path:text
+func poll(t *testing.T, watcher *agent.Watcher) []string {
+	t.Helper()
+	var lines []string
+	require.NoError(t, watcher.Poll(func(line agent.Line) error {
+		lines = append(lines, line.Path+":"+line.Text)
+		return nil
+	}))
+	return lines
+}
+
+func appendTo(file *fstest.MapFile, text string) {
+	file.Data = append(file.Data, text...)
+}
+
+func newWatcher(fsys fstest.MapFS, paths ...string) *agent.Watcher {
+	return agent.NewWatcher(fsys, []agent.Source{{Paths: paths}}, nil)
+}
+
+func TestWatcherReadsAppendedLines(t *testing.T) {
+	log := &fstest.MapFile{Data: []byte("a\n\nb\nc")}
+	fsys := fstest.MapFS{"logs/app.log": log, "logs/other.txt": {Data: []byte("x\n")}}
+	watcher := newWatcher(fsys, "logs/*.log")
+	defer watcher.Close()
+
+	assert.Equal(t, []string{"logs/app.log:a", "logs/app.log:b"}, poll(t, watcher))
+	assert.Empty(t, poll(t, watcher))
+	appendTo(log, "ontinued\r\nd\n")
+	assert.Equal(t, []string{"logs/app.log:continued", "logs/app.log:d"}, poll(t, watcher))
+}
+
+func TestWatcherWaitsForAFirstLine(t *testing.T) {
+	log := &fstest.MapFile{Data: []byte("starting")}
+	watcher := newWatcher(fstest.MapFS{"app.log": log}, "app.log")
+	defer watcher.Close()
+
+	assert.Empty(t, poll(t, watcher))
+	appendTo(log, "\n")
+	assert.Equal(t, []string{"app.log:starting"}, poll(t, watcher))
+}
+
+func TestWatcherFollowsRotation(t *testing.T) {
+	t.Run("by rename", func(t *testing.T) {
+		log := &fstest.MapFile{Data: []byte("a\n")}
+		fsys := fstest.MapFS{"app.log": log}
+		watcher := newWatcher(fsys, "app.log")
+		defer watcher.Close()
+		var dropped []string
+		watcher.OnDrop = func(id string) { dropped = append(dropped, id) }
+		require.Equal(t, []string{"app.log:a"}, poll(t, watcher))
+
+		appendTo(log, "b\n")
+		delete(fsys, "app.log")
+		fsys["app.log.1"] = log
+		fsys["app.log"] = &fstest.MapFile{Data: []byte("c\n")}
+		assert.Equal(t, []string{"app.log:b", "app.log:c"}, poll(t, watcher), "the rotated file is read to its end")
+		assert.Len(t, dropped, 1)
+	})
+
+	t.Run("by rename to a watched path", func(t *testing.T) {
+		log := &fstest.MapFile{Data: []byte("a\n")}
+		fsys := fstest.MapFS{"app.log": log}
+		watcher := newWatcher(fsys, "app.log*")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:a"}, poll(t, watcher))
+
+		appendTo(log, "b\n")
+		delete(fsys, "app.log")
+		fsys["app.log.1"] = log
+		fsys["app.log"] = &fstest.MapFile{Data: []byte("c\n")}
+		assert.Equal(t, []string{"app.log:c", "app.log.1:b"}, poll(t, watcher))
+		assert.Empty(t, poll(t, watcher))
+	})
+
+	t.Run("by copytruncate", func(t *testing.T) {
+		log := &fstest.MapFile{Data: []byte("a\n")}
+		fsys := fstest.MapFS{"app.log": log}
+		watcher := newWatcher(fsys, "app.log*")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:a"}, poll(t, watcher))
+
+		appendTo(log, "b\n")
+		fsys["app.log.1"] = &fstest.MapFile{Data: append([]byte(nil), log.Data...)}
+		assert.Equal(t, []string{"app.log:b"}, poll(t, watcher), "a copy in progress is not read twice")
+
+		log.Data = []byte("c\n")
+		assert.Equal(t, []string{"app.log:c"}, poll(t, watcher))
+		assert.Empty(t, poll(t, watcher))
+	})
+
+	t.Run("by truncation in place", func(t *testing.T) {
+		log := &fstest.MapFile{Data: []byte("header\na\n")}
+		watcher := newWatcher(fstest.MapFS{"app.log": log}, "app.log")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:header", "app.log:a"}, poll(t, watcher))
+
+		log.Data = []byte("header\n")
+		assert.Equal(t, []string{"app.log:header"}, poll(t, watcher))
+	})
+}
+
+func TestWatcherResumes(t *testing.T) {
+	fsys := fstest.MapFS{"app.log": {Data: []byte("a\nb\n")}}
+	first := newWatcher(fsys, "app.log")
+	var lines []agent.Line
+	require.NoError(t, first.Poll(func(line agent.Line) error {
+		lines = append(lines, line)
+		return nil
+	}))
+	first.Close()
+	require.Len(t, lines, 2)
+	assert.Equal(t, int64(2), lines[0].Offset)
+
+	resume := agent.Checkpoints{
+		lines[0].File: {Path: "app.log", Offset: lines[0].Offset, Line: lines[0].Number},
+		"gone":        {Path: "app.log.1", Offset: 10},
+	}
+	watcher := agent.NewWatcher(fsys, []agent.Source{{Paths: []string{"app.log"}}}, resume)
+	defer watcher.Close()
+	var dropped []string
+	watcher.OnDrop = func(id string) { dropped = append(dropped, id) }
+	require.NoError(t, watcher.Poll(func(line agent.Line) error {
+		assert.Equal(t, "b", line.Text)
+		assert.Equal(t, 2, line.Number)
+		return nil
+	}))
+	assert.Equal(t, []string{"gone"}, dropped, "checkpoints of missing files are dropped")
+}
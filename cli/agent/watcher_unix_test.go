@@ -0,0 +1,81 @@
+//go:build unix
+
+package agent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jgfranco17/echoris/cli/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes a file of dir, replacing what it held
+func writeFile(t *testing.T, dir, name, text string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644))
+}
+
+// appendFile appends text to a file of dir
+func appendFile(t *testing.T, dir, name, text string) {
+	t.Helper()
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(text)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func watchDir(dir string, paths ...string) *agent.Watcher {
+	return agent.NewWatcher(os.DirFS(dir), []agent.Source{{Paths: paths}}, nil)
+}
+
+func TestWatcherTellsApartFilesWithTheSameFirstLine(t *testing.T) {
+	t.Run("by rename", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "app.log", "header\na\n")
+		watcher := watchDir(dir, "app.log")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:header", "app.log:a"}, poll(t, watcher))
+
+		appendFile(t, dir, "app.log", "b\n")
+		require.NoError(t, os.Rename(filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.1")))
+		writeFile(t, dir, "app.log", "header\nc\n")
+		assert.Equal(t, []string{"app.log:b", "app.log:header", "app.log:c"}, poll(t, watcher))
+		assert.Empty(t, poll(t, watcher))
+	})
+
+	t.Run("by rename to a watched path", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "app.log", "header\na\n")
+		watcher := watchDir(dir, "app.log*")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:header", "app.log:a"}, poll(t, watcher))
+
+		appendFile(t, dir, "app.log", "b\n")
+		require.NoError(t, os.Rename(filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.1")))
+		writeFile(t, dir, "app.log", "header\nc\n")
+		assert.Equal(t, []string{"app.log:header", "app.log:c", "app.log.1:b"}, poll(t, watcher))
+		assert.Empty(t, poll(t, watcher))
+	})
+
+	t.Run("by copytruncate", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "app.log", "a\n")
+		watcher := watchDir(dir, "app.log*")
+		defer watcher.Close()
+		require.Equal(t, []string{"app.log:a"}, poll(t, watcher))
+
+		appendFile(t, dir, "app.log", "b\n")
+		writeFile(t, dir, "app.log.1", "a\nb\n")
+		assert.Equal(t, []string{"app.log:b"}, poll(t, watcher), "a copy in progress is not read twice")
+
+		appendFile(t, dir, "app.log", "c\n")
+		writeFile(t, dir, "app.log.1", "a\nb\nc\n")
+		writeFile(t, dir, "app.log", "d\n")
+		assert.Equal(t, []string{"app.log:d", "app.log.1:c"}, poll(t, watcher), "lines written before the truncation are read from the copy")
+		assert.Empty(t, poll(t, watcher))
+	})
+}
@@ -0,0 +1,200 @@
+// Package client calls the Echoris REST API for the CLI
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+)
+
+// DefaultServer is the API address used when none is configured
+const DefaultServer = "http://localhost:8000"
+
+// Client calls the v0 endpoints of an Echoris API server
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	stream  *http.Client // Without a timeout, for tails
+}
+
+// New creates a client for the API at baseURL. Requests other than tails
+// give up after timeout; zero waits indefinitely.
+func New(baseURL string, timeout time.Duration) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q, expected one such as %s", baseURL, DefaultServer)
+	}
+	return &Client{baseURL: parsed, http: &http.Client{Timeout: timeout}, stream: &http.Client{}}, nil
+}
+
+// StatusError is an error response from the API
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server responded with %d %s", e.Code, http.StatusText(e.Code))
+	}
+	return fmt.Sprintf("server responded with %d: %s", e.Code, e.Message)
+}
+
+// ingestResponse is the body of POST /v0/logs
+type ingestResponse struct {
+	Message  string          `json:"message"`
+	Accepted int64           `json:"accepted"`
+	Rejected int64           `json:"rejected"`
+	Errors   []v0.EventError `json:"errors"`
+}
+
+// ForwardLogs posts a batch of entries to /v0/logs. Entries the server
+// rejects are reported in the result rather than as an error.
+func (c *Client) ForwardLogs(ctx context.Context, batch []events.Entry) (v0.IngestResult, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return v0.IngestResult{}, fmt.Errorf("failed to encode logs: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/v0/logs", ""), bytes.NewReader(body))
+	if err != nil {
+		return v0.IngestResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return v0.IngestResult{}, fmt.Errorf("failed to send logs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded ingestResponse
+	if err := decodeResponse(resp, &decoded); err != nil {
+		// A 400 that counts rejections means every entry was rejected
+		if resp.StatusCode != http.StatusBadRequest || decoded.Rejected == 0 {
+			return v0.IngestResult{}, err
+		}
+	}
+	return v0.IngestResult{Accepted: decoded.Accepted, Rejected: decoded.Rejected, Errors: decoded.Errors}, nil
+}
+
+// Query selects the logs returned by QueryLogs
+type Query struct {
+	Service   string
+	Level     string
+	Text      string   // Full-text search over messages
+	Expr      string   // Query language expression
+	Fields    []string // Field filters such as user=alice, region!=eu or latency_ms>500
+	Start     string   // RFC 3339 or relative to now, such as now-15m
+	End       string
+	Ascending bool
+	Limit     int // Logs per page; 0 uses the server default
+	PageToken string
+}
+
+// Page is one page of QueryLogs results
+type Page struct {
+	Logs          []events.Entry `json:"logs"`
+	NextPageToken string         `json:"next_page_token"` // Empty on the last page
+}
+
+// values encodes the query as the query string of GET /v0/logs
+func (q Query) values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("service", q.Service)
+	set("level", q.Level)
+	set("q", q.Text)
+	set("query", q.Expr)
+	set("start", q.Start)
+	set("end", q.End)
+	set("page_token", q.PageToken)
+	if q.Ascending {
+		values.Set("order", "asc")
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// rawQuery encodes the query string, writing field filters as the API
+// reads them: fields.latency_ms>500 is a parameter without a value
+func (q Query) rawQuery() string {
+	parts := []string{q.values().Encode()}
+	for _, filter := range q.Fields {
+		name, value, hasValue := strings.Cut(filter, "=")
+		part := url.QueryEscape("fields." + name)
+		if hasValue {
+			part += "=" + url.QueryEscape(value)
+		}
+		parts = append(parts, part)
+	}
+	if parts[0] == "" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "&")
+}
+
+// QueryLogs fetches one page of logs from GET /v0/logs
+func (c *Client) QueryLogs(ctx context.Context, query Query) (Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/v0/logs", query.rawQuery()), nil)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var page Page
+	if err := decodeResponse(resp, &page); err != nil {
+		return Page{}, err
+	}
+	return page, nil
+}
+
+// endpoint returns the URL of path with an encoded query string
+func (c *Client) endpoint(path string, rawQuery string) string {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = rawQuery
+	return u.String()
+}
+
+// decodeResponse decodes a JSON body into out, returning a StatusError
+// for responses other than 200 and 207. Error bodies are decoded into out
+// as well, since some carry results.
+func decodeResponse(resp *http.Response, out any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		var errorBody struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &errorBody)
+		_ = json.Unmarshal(body, out)
+		return &StatusError{Code: resp.StatusCode, Message: errorBody.Message}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// respond serves a fixed status and JSON body, recording the last request body
+func respond(t *testing.T, code int, body string, received *[]map[string]any) *client.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0/logs", r.URL.Path)
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if received != nil {
+			require.NoError(t, json.Unmarshal(data, received))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL, time.Second)
+	require.NoError(t, err)
+	return c
+}
+
+func TestForwardLogs(t *testing.T) {
+	batch := []events.Entry{
+		{Timestamp: time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC), Service: "api", Level: "info", Message: "a"},
+		{Timestamp: time.Date(2024, 5, 31, 8, 0, 1, 0, time.UTC), Message: "b"},
+	}
+
+	t.Run("partially accepted", func(t *testing.T) {
+		var received []map[string]any
+		c := respond(t, http.StatusMultiStatus, `{"message":"Some logs were rejected","accepted":1,"rejected":1,"errors":[{"index":1,"reason":"service is required"}]}`, &received)
+		result, err := c.ForwardLogs(context.Background(), batch)
+		require.NoError(t, err)
+		assert.Equal(t, v0.IngestResult{Accepted: 1, Rejected: 1, Errors: []v0.EventError{{Index: 1, Reason: "service is required"}}}, result)
+		require.Len(t, received, 2)
+		assert.Equal(t, "2024-05-31T08:00:00Z", received[0]["timestamp"])
+		assert.Equal(t, "api", received[0]["service"])
+	})
+
+	t.Run("all rejected", func(t *testing.T) {
+		c := respond(t, http.StatusBadRequest, `{"message":"All logs were rejected","accepted":0,"rejected":2}`, nil)
+		result, err := c.ForwardLogs(context.Background(), batch)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Rejected)
+	})
+
+	t.Run("error responses", func(t *testing.T) {
+		cases := map[int]string{
+			http.StatusBadRequest:          "invalid JSON body",
+			http.StatusInternalServerError: "failed to forward logs",
+		}
+		for code, message := range cases {
+			c := respond(t, code, `{"message":"`+message+`"}`, nil)
+			_, err := c.ForwardLogs(context.Background(), batch)
+			var statusErr *client.StatusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, code, statusErr.Code)
+			assert.Equal(t, message, statusErr.Message)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	_, err := client.New("localhost:8000", time.Second)
+	assert.EqualError(t, err, `invalid server URL "localhost:8000", expected one such as http://localhost:8000`)
+}
+
+func TestQueryLogs(t *testing.T) {
+	var rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0/logs", r.URL.Path)
+		rawQuery = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"logs":[{"timestamp":"2024-05-31T08:00:00Z","service":"api","level":"info","message":"a"}],"next_page_token":"t2"}`))
+	}))
+	defer server.Close()
+	c, err := client.New(server.URL+"/", time.Second)
+	require.NoError(t, err)
+
+	page, err := c.QueryLogs(context.Background(), client.Query{
+		Service:   "api",
+		Expr:      `message~"timeout"`,
+		Fields:    []string{"user=al*", "region!=eu", "latency_ms>500", "size<=10"},
+		Start:     "now-15m",
+		Ascending: true,
+		Limit:     50,
+		PageToken: "t1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "t2", page.NextPageToken)
+	require.Len(t, page.Logs, 1)
+	assert.Equal(t, "a", page.Logs[0].Message)
+
+	// The API reads fields.latency_ms>500 as a parameter without a value
+	values, err := url.ParseQuery(rawQuery)
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"service":               {"api"},
+		"query":                 {`message~"timeout"`},
+		"start":                 {"now-15m"},
+		"order":                 {"asc"},
+		"limit":                 {"50"},
+		"page_token":            {"t1"},
+		"fields.user":           {"al*"},
+		"fields.region!":        {"eu"},
+		"fields.latency_ms>500": {""},
+		"fields.size<":          {"10"},
+	}, values)
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+)
+
+// TailEvent is a log delivered by a tail
+type TailEvent struct {
+	Entry  events.Entry
+	Cursor string // Resumes the tail after this log
+}
+
+// TailError is the reason the server gave for ending a tail
+type TailError struct {
+	Message string
+}
+
+func (e *TailError) Error() string {
+	return "tail ended by server: " + e.Message
+}
+
+// Tail reads the Server-Sent Events of GET /v0/logs/tail
+type Tail struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// TailLogs opens a tail of the logs matching query, replaying up to replay
+// recent logs first, or resuming after cursor when it is set. Paging and
+// ordering of the query do not apply.
+func (c *Client) TailLogs(ctx context.Context, query Query, replay int, cursor string) (*Tail, error) {
+	query.Limit, query.PageToken, query.Ascending = 0, "", false
+	rawQuery := query.rawQuery()
+	if replay > 0 {
+		rawQuery = strings.TrimPrefix(rawQuery+"&replay="+strconv.Itoa(replay), "&")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/v0/logs/tail", rawQuery), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if cursor != "" {
+		req.Header.Set("Last-Event-ID", cursor)
+	}
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to tail logs: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var ignored struct{}
+		return nil, decodeResponse(resp, &ignored)
+	}
+	return &Tail{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// Next returns the next log, a *TailError when the server ends the tail,
+// or io.EOF when the connection closes
+func (t *Tail) Next() (TailEvent, error) {
+	var event, id string
+	var data []string
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			return TailEvent{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "id":
+				id = value
+			case "data":
+				data = append(data, value)
+			}
+			continue
+		}
+
+		// A blank line dispatches the event, if any data was sent
+		if len(data) == 0 {
+			event, id = "", ""
+			continue
+		}
+		return decodeTailEvent(event, id, strings.Join(data, "\n"))
+	}
+}
+
+func decodeTailEvent(event, id, data string) (TailEvent, error) {
+	if event == "error" {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal([]byte(data), &body)
+		return TailEvent{}, &TailError{Message: body.Message}
+	}
+	var entry events.Entry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return TailEvent{}, fmt.Errorf("failed to decode log: %w", err)
+	}
+	return TailEvent{Entry: entry, Cursor: id}, nil
+}
+
+// Close ends the tail
+func (t *Tail) Close() error {
+	return t.body.Close()
+}
+
+// FollowOptions configure FollowLogs
+type FollowOptions struct {
+	Replay     int           // Recent logs to print before live ones
+	Backoff    time.Duration // First wait before reconnecting, doubled up to MaxBackoff
+	MaxBackoff time.Duration
+	OnRetry    func(err error, wait time.Duration) // Called before every reconnection
+}
+
+// FollowLogs tails the logs matching query, passing each to handle, until
+// ctx is done or handle fails. Lost connections are reopened after a
+// backoff, resuming after the last log handled so none is repeated.
+// Requests the server rejects are not retried.
+func (c *Client) FollowLogs(ctx context.Context, query Query, opts FollowOptions, handle func(TailEvent) error) error {
+	cursor := ""
+	wait := opts.Backoff
+	for {
+		tail, err := c.TailLogs(ctx, query, opts.Replay, cursor)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code < http.StatusInternalServerError {
+			return err
+		}
+		if err == nil {
+			err = follow(tail, handle, &cursor, func() { wait = opts.Backoff })
+			tail.Close()
+			var handleErr *handlerError
+			if errors.As(err, &handleErr) {
+				return handleErr.err
+			}
+			if errors.Is(err, io.EOF) {
+				err = errors.New("connection closed by server")
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if opts.OnRetry != nil {
+			opts.OnRetry(err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil
+		}
+		wait = min(wait*2, opts.MaxBackoff)
+	}
+}
+
+// handlerError marks a failure of the function handling logs, which ends
+// a follow rather than causing a reconnection
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// follow handles the logs of one connection, recording the cursor of each
+// and calling received after every one
+func follow(tail *Tail, handle func(TailEvent) error, cursor *string, received func()) error {
+	for {
+		event, err := tail.Next()
+		if err != nil {
+			return err
+		}
+		if err := handle(event); err != nil {
+			return &handlerError{err: err}
+		}
+		if event.Cursor != "" {
+			*cursor = event.Cursor
+		}
+		received()
+	}
+}
@@ -0,0 +1,129 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sseEvent(cursor, message string) string {
+	return fmt.Sprintf("id: %s\ndata: {\"timestamp\":\"2024-05-31T08:00:00Z\",\"service\":\"api\",\"level\":\"info\",\"message\":%q}\n\n", cursor, message)
+}
+
+func TestTailLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0/logs/tail", r.URL.Path)
+		assert.Equal(t, "service=api&replay=5", r.URL.RawQuery)
+		assert.Equal(t, "c0", r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseEvent("c1", "a"))
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprint(w, sseEvent("c2", "b"))
+		fmt.Fprint(w, "event: error\ndata: {\"message\":\"tail fell behind\"}\n\n")
+	}))
+	defer server.Close()
+	c, err := client.New(server.URL, time.Second)
+	require.NoError(t, err)
+
+	tail, err := c.TailLogs(context.Background(), client.Query{Service: "api", Limit: 10}, 5, "c0")
+	require.NoError(t, err)
+	defer tail.Close()
+
+	for _, expected := range []client.TailEvent{{Cursor: "c1"}, {Cursor: "c2"}} {
+		event, err := tail.Next()
+		require.NoError(t, err)
+		assert.Equal(t, expected.Cursor, event.Cursor)
+		assert.Equal(t, "api", event.Entry.Service)
+	}
+	_, err = tail.Next()
+	var tailErr *client.TailError
+	require.ErrorAs(t, err, &tailErr)
+	assert.Equal(t, "tail fell behind", tailErr.Message)
+	_, err = tail.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFollowLogs(t *testing.T) {
+	t.Run("reconnects after the last log", func(t *testing.T) {
+		var mu sync.Mutex
+		var resumedFrom []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			resumedFrom = append(resumedFrom, r.Header.Get("Last-Event-ID"))
+			connection := len(resumedFrom)
+			mu.Unlock()
+			switch connection {
+			case 1:
+				fmt.Fprint(w, sseEvent("c1", "a"))
+			case 2:
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				fmt.Fprint(w, sseEvent("c2", "b"))
+			}
+		}))
+		defer server.Close()
+		c, err := client.New(server.URL, time.Second)
+		require.NoError(t, err)
+
+		var messages []string
+		var retries int
+		done := errors.New("done")
+		err = c.FollowLogs(context.Background(), client.Query{}, client.FollowOptions{
+			Replay:     3,
+			Backoff:    time.Millisecond,
+			MaxBackoff: time.Millisecond,
+			OnRetry:    func(err error, wait time.Duration) { retries++ },
+		}, func(event client.TailEvent) error {
+			messages = append(messages, event.Entry.Message)
+			if len(messages) == 2 {
+				return done
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, done)
+		assert.Equal(t, []string{"a", "b"}, messages)
+		assert.Equal(t, 2, retries)
+		assert.Equal(t, []string{"", "c1", "c1"}, resumedFrom)
+	})
+
+	t.Run("stops on rejected requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message":"invalid query"}`)
+		}))
+		defer server.Close()
+		c, err := client.New(server.URL, time.Second)
+		require.NoError(t, err)
+
+		err = c.FollowLogs(context.Background(), client.Query{}, client.FollowOptions{Backoff: time.Millisecond}, func(client.TailEvent) error { return nil })
+		var statusErr *client.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, "invalid query", statusErr.Message)
+	})
+
+	t.Run("stops cleanly when cancelled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		c, err := client.New(server.URL, time.Second)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = c.FollowLogs(ctx, client.Query{}, client.FollowOptions{Backoff: time.Second}, func(client.TailEvent) error { return nil })
+		assert.NoError(t, err)
+	})
+}
@@ -34,10 +34,14 @@ func main() {
 	command := core.NewCommandRegistry(metadata.Name, metadata.Description, metadata.Version)
 	commandsList := []*cobra.Command{
 		core.GetSendCommand(),
+		core.GetQueryCommand(),
+		core.GetTailCommand(),
+		core.GetAgentCommand(),
 	}
 	command.RegisterCommands(commandsList)
 
 	if err := command.Execute(); err != nil {
 		log.Error(err.Error())
+		os.Exit(1)
 	}
 }
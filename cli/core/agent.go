@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/agent"
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/jgfranco17/echoris/internal/fileutils"
+	"github.com/jgfranco17/echoris/internal/outputs"
+	"github.com/spf13/cobra"
+)
+
+// agentOptions hold the flags of the agent command
+type agentOptions struct {
+	server        string
+	config        string
+	root          string
+	checkpoint    string
+	pollInterval  time.Duration
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	retry         shipper.RetryPolicy
+	quiet         bool
+	queue         queueOptions
+}
+
+func runAgent(cmd *cobra.Command, opts agentOptions) error {
+	if opts.batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", opts.batchSize)
+	}
+	if opts.pollInterval <= 0 {
+		return fmt.Errorf("poll interval must be positive, got %s", opts.pollInterval)
+	}
+	if err := opts.retry.Validate(); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(opts.config)
+	if err != nil {
+		return fmt.Errorf("failed to read agent configuration: %w", err)
+	}
+	config, err := agent.ParseConfig(data)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	fsys := fileutils.RootDirFromContext(ctx)
+	if opts.root != "" {
+		if info, err := os.Stat(opts.root); err != nil {
+			return fmt.Errorf("invalid root: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("invalid root: %s is not a directory", opts.root)
+		}
+		fsys = os.DirFS(opts.root)
+	}
+	apiClient, err := client.New(opts.server, opts.timeout)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.ErrOrStderr()
+	progress := &sendProgress{out: out, quiet: opts.quiet}
+	queue, err := openQueue(opts.queue, progress)
+	if err != nil {
+		return err
+	}
+	if queue != nil {
+		defer queue.Close()
+	}
+	if !opts.quiet {
+		outputs.PrintColoredMessageTo(out, "cyan", "Watching %d sources, checkpoints in %s", len(config.Sources), opts.checkpoint)
+	}
+	err = agent.Run(ctx, fsys, apiClient, config, agent.Options{
+		Checkpoint:   opts.checkpoint,
+		PollInterval: opts.pollInterval,
+		Ship: shipper.Options{
+			BatchSize:     opts.batchSize,
+			FlushInterval: opts.flushInterval,
+			Retry:         opts.retry,
+			OnReport:      progress.report,
+			OnRetry:       progress.retry,
+			Queue:         queue,
+		},
+		OnReject: progress.reject,
+		OnError: func(path string, err error) {
+			outputs.PrintColoredMessageTo(out, "yellow", "%s: %v", path, err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !opts.quiet {
+		outputs.PrintColoredMessageTo(out, "green", "Agent stopped after sending %d entries", progress.accepted)
+	}
+	return nil
+}
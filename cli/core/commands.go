@@ -1,23 +1,185 @@
 package core
 
 import (
-	"fmt"
+	"time"
 
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/render"
+	"github.com/jgfranco17/echoris/cli/shipper"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func GetSendCommand() *cobra.Command {
+	opts := sendOptions{retry: shipper.DefaultRetryPolicy}
 	cmd := &cobra.Command{
-		Use:   "send",
+		Use:   "send [file...]",
 		Short: "Forward logs to the server",
-		Long:  "Forward logs to the server.",
-		Args:  cobra.NoArgs,
+		Long: `Forward logs to the server.
+
+Entries are read from the given files, or from stdin when there are none or
+the file is "-". Input is a JSON array of entries, NDJSON or plain text with
+one message per line; the format is detected unless --format is set. Entries
+missing a service, level or timestamp get them from the flags and the current
+time.
+
+With --queue, entries are written to a queue on disk before being sent, and
+kept there while the server cannot be reached. Entries a run could not send
+are sent first by the next run using the same queue.`,
+		Example: `  echoris send app.ndjson
+  tail -f app.log | echoris send --service api --format text --queue ~/.echoris/queue`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("Sending logs to the server...")
-			return nil
+			return runSend(cmd, args, opts)
 		},
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.server, "server", client.DefaultServer, "URL of the Echoris API")
+	flags.StringVar(&opts.grpcAddress, "grpc", "", "Send straight to the worker at this gRPC address instead of the API")
+	flags.StringVarP(&opts.format, "format", "f", string(shipper.FormatAuto), "Input format: auto, json, ndjson or text")
+	flags.StringVarP(&opts.service, "service", "s", "", "Service of entries that do not set one")
+	flags.StringVarP(&opts.level, "level", "l", "info", "Level of entries that do not set one")
+	flags.IntVar(&opts.batchSize, "batch-size", 500, "Maximum entries per request")
+	flags.DurationVar(&opts.flushInterval, "flush-interval", time.Second, "Longest a partial batch waits for more input")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of each request")
+	flags.IntVar(&opts.retry.Attempts, "attempts", opts.retry.Attempts, "Attempts per batch before giving up")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Only report rejected entries and errors")
+	addQueueFlags(flags, &opts.queue)
 	return cmd
 }
+
+func GetQueryCommand() *cobra.Command {
+	var opts queryOptions
+	cmd := &cobra.Command{
+		Use:   "query",
+		Short: "Search logs stored on the server",
+		Long: `Search logs stored on the server.
+
+Filters combine: every one given must hold. Times are RFC 3339 or relative to
+now, such as now-15m, and --since 15m is short for --start now-15m. Field
+filters take the form of the API's fields parameters: user=alice, user=al*,
+region!=eu, user=* or latency_ms>500.
+
+Results are printed newest first as a table colored by level, or as JSON,
+NDJSON or a Go template executed for each log. Only the first page is fetched
+unless --all is set.`,
+		Example: `  echoris query --service api --level error --since 1h
+  echoris query --query 'service:api AND message~"timeout"' -o ndjson
+  echoris query --field 'latency_ms>500' --all --template '{{.Timestamp}} {{.Message}}'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, opts)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.server, "server", client.DefaultServer, "URL of the Echoris API")
+	addFilterFlags(flags, &opts.query)
+	flags.StringVar(&opts.since, "since", "", "Only logs from this long ago, such as 15m or 7d")
+	flags.StringVar(&opts.query.Start, "start", "", "Only logs at or after this time")
+	flags.StringVar(&opts.query.End, "end", "", "Only logs at or before this time")
+	flags.BoolVar(&opts.query.Ascending, "reverse", false, "Print the oldest logs first")
+	flags.IntVarP(&opts.query.Limit, "limit", "n", 100, "Logs per page")
+	flags.BoolVar(&opts.all, "all", false, "Fetch every page of results")
+	flags.StringVarP(&opts.output, "output", "o", string(render.FormatTable), "Output format: table, json or ndjson")
+	flags.StringVar(&opts.template, "template", "", "Go template executed for each log, such as '{{.Service}}: {{.Message}}'")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of each request")
+	return cmd
+}
+
+func GetTailCommand() *cobra.Command {
+	var opts tailOptions
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Print the latest logs and follow new ones",
+		Long: `Print the latest logs, oldest first, and with --follow keep printing logs as
+the server stores them.
+
+Filters are those of the query command. Words searched for with --search or
+message~ in --query, and any given with --highlight, are highlighted in the
+table output. A lost connection is reopened with backoff and resumes after
+the last log printed. Interrupt with Ctrl-C to stop.`,
+		Example: `  echoris tail -f --service api --level error
+  echoris tail -f -n 50 --query 'message~"timeout"' --highlight retry`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTail(cmd, opts)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.server, "server", client.DefaultServer, "URL of the Echoris API")
+	addFilterFlags(flags, &opts.query)
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "Keep printing new logs until interrupted")
+	flags.IntVarP(&opts.lines, "lines", "n", 10, "Number of recent logs to print first")
+	flags.StringArrayVar(&opts.highlight, "highlight", nil, "Highlight this word in messages (repeatable)")
+	flags.StringVarP(&opts.output, "output", "o", string(render.FormatTable), "Output format: table or ndjson")
+	flags.StringVar(&opts.template, "template", "", "Go template executed for each log")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of requests other than the tail itself")
+	return cmd
+}
+
+func GetAgentCommand() *cobra.Command {
+	opts := agentOptions{retry: shipper.RetryPolicy{Backoff: time.Second, MaxBackoff: 30 * time.Second}}
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Watch log files and ship new lines to the server",
+		Long: `Watch log files and ship the lines appended to them to the server, until
+interrupted.
+
+The configuration file lists sources: globs of paths relative to the agent root,
+and the parser of their lines, text, json or regex. The root is the working
+directory unless --root is set; run with --root / to watch absolute paths, such
+as var/log/*.log for /var/log/*.log. Rotation by
+rename or copytruncate is followed; include rotated names, such as app.log.1,
+in the paths so that lines written while the agent was stopped are not lost.
+How far each file was shipped is saved in the checkpoint file after every
+batch, so that a restarted agent carries on where it stopped.`,
+		Example: `  echoris agent --config agent.json
+  echoris agent --config agent.json --checkpoint /var/lib/echoris/checkpoints.json
+  echoris agent --config agent.json --root /var/log`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgent(cmd, opts)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.server, "server", client.DefaultServer, "URL of the Echoris API")
+	flags.StringVarP(&opts.config, "config", "c", "", "Configuration file of the agent")
+	flags.StringVar(&opts.root, "root", "", "Directory the paths of the configuration are relative to (default the working directory)")
+	flags.StringVar(&opts.checkpoint, "checkpoint", ".echoris-checkpoints.json", "File where how far each file was shipped is saved")
+	flags.DurationVar(&opts.pollInterval, "poll-interval", time.Second, "How often files are checked for new lines")
+	flags.IntVar(&opts.batchSize, "batch-size", 500, "Maximum entries per request")
+	flags.DurationVar(&opts.flushInterval, "flush-interval", time.Second, "Longest a partial batch waits for more lines")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of each request")
+	flags.IntVar(&opts.retry.Attempts, "attempts", 0, "Attempts per batch before giving up, or 0 to retry until the server is back")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Only report rejected lines and errors")
+	addQueueFlags(flags, &opts.queue)
+	_ = cmd.MarkFlagRequired("config")
+	return cmd
+}
+
+// addQueueFlags adds the flags of the disk queue, shared by send and agent
+func addQueueFlags(flags *pflag.FlagSet, opts *queueOptions) {
+	flags.StringVar(&opts.dir, "queue", "", "Directory of a queue on disk keeping entries until they are sent")
+	flags.Int64Var(&opts.maxSize, "queue-max-size", shipper.DefaultQueueOptions.MaxSize>>20, "Most MiB kept in the queue, dropping the oldest entries past it")
+	flags.StringVar(&opts.sync, "queue-sync", string(shipper.SyncInterval), "When the queue is synced to disk: always, interval (every second) or never")
+}
+
+// addFilterFlags adds the flags that select logs, shared by query and tail
+func addFilterFlags(flags *pflag.FlagSet, query *client.Query) {
+	flags.StringVarP(&query.Service, "service", "s", "", "Only logs of this service")
+	flags.StringVarP(&query.Level, "level", "l", "", "Only logs of this level")
+	flags.StringVarP(&query.Text, "search", "q", "", "Only logs whose message contains these words")
+	flags.StringVar(&query.Expr, "query", "", "Only logs matching a query language expression")
+	flags.StringArrayVarP(&query.Fields, "field", "F", nil, "Only logs whose fields match, such as user=alice (repeatable)")
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/render"
+	"github.com/jgfranco17/echoris/internal/outputs"
+	"github.com/spf13/cobra"
+)
+
+// queryOptions hold the flags of the query command
+type queryOptions struct {
+	server   string
+	query    client.Query
+	since    string
+	all      bool
+	output   string
+	template string
+	timeout  time.Duration
+}
+
+func runQuery(cmd *cobra.Command, opts queryOptions) error {
+	query, err := opts.resolve()
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := client.New(opts.server, opts.timeout)
+	if err != nil {
+		return err
+	}
+	renderer, err := render.New(cmd.OutOrStdout(), render.Options{Format: render.Format(opts.output), Template: opts.template})
+	if err != nil {
+		return err
+	}
+
+	count, err := renderPages(cmd, apiClient, renderer, query, opts.all)
+	if err != nil {
+		return err
+	}
+	if err := renderer.Close(); err != nil {
+		return err
+	}
+	if count == 0 && opts.template == "" && opts.output == string(render.FormatTable) {
+		outputs.PrintColoredMessageTo(cmd.ErrOrStderr(), "yellow", "No logs found")
+	}
+	return nil
+}
+
+// resolve validates the flags and returns the query they describe
+func (opts queryOptions) resolve() (client.Query, error) {
+	query := opts.query
+	if opts.since != "" {
+		if query.Start != "" {
+			return query, fmt.Errorf("--since and --start cannot be combined")
+		}
+		query.Start = "now-" + opts.since
+	}
+	if query.Limit <= 0 {
+		return query, fmt.Errorf("limit must be positive, got %d", query.Limit)
+	}
+	return query, nil
+}
+
+// renderPages renders the first page of logs matching query, or every page
+// when all is set, and returns the number of logs rendered
+func renderPages(cmd *cobra.Command, apiClient *client.Client, renderer render.Renderer, query client.Query, all bool) (int, error) {
+	count := 0
+	for {
+		page, err := apiClient.QueryLogs(cmd.Context(), query)
+		if err != nil {
+			return count, err
+		}
+		for _, entry := range page.Logs {
+			if err := renderer.Render(entry); err != nil {
+				return count, err
+			}
+		}
+		count += len(page.Logs)
+
+		if page.NextPageToken == "" {
+			return count, nil
+		}
+		if !all {
+			outputs.PrintColoredMessageTo(cmd.ErrOrStderr(), "yellow", "Showing the first %d logs; more match, run with --all to fetch them", count)
+			return count, nil
+		}
+		query.PageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/jgfranco17/echoris/internal/outputs"
+	"github.com/spf13/cobra"
+)
+
+// sendOptions hold the flags of the send command
+type sendOptions struct {
+	server        string
+	grpcAddress   string
+	format        string
+	service       string
+	level         string
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	retry         shipper.RetryPolicy
+	quiet         bool
+	queue         queueOptions
+}
+
+// queueOptions hold the flags of the disk queue
+type queueOptions struct {
+	dir     string
+	maxSize int64 // MiB
+	sync    string
+}
+
+func runSend(cmd *cobra.Command, args []string, opts sendOptions) error {
+	format, err := shipper.ParseFormat(opts.format)
+	if err != nil {
+		return err
+	}
+	if opts.batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", opts.batchSize)
+	}
+	if opts.retry.Attempts <= 0 {
+		return fmt.Errorf("attempts must be positive, got %d", opts.retry.Attempts)
+	}
+	if err := opts.retry.Validate(); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	sender, closeSender, err := newSender(opts)
+	if err != nil {
+		return err
+	}
+	defer closeSender()
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	progress := &sendProgress{out: cmd.ErrOrStderr(), quiet: opts.quiet}
+	queue, err := openQueue(opts.queue, progress)
+	if err != nil {
+		return err
+	}
+	if queue != nil {
+		defer queue.Close()
+	}
+	defaults := shipper.Defaults{Service: opts.service, Level: opts.level}
+	items := make(chan shipper.Item)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(items)
+		readErr <- readInputs(ctx, cmd.InOrStdin(), args, format, defaults, items, progress)
+	}()
+
+	shipErr := shipper.Ship(ctx, sender, items, shipper.Options{
+		BatchSize:     opts.batchSize,
+		FlushInterval: opts.flushInterval,
+		Retry:         opts.retry,
+		OnReport:      progress.report,
+		OnRetry:       progress.retry,
+		Queue:         queue,
+	})
+	if shipErr != nil {
+		// The reader may be blocked on input, so it is not waited for
+		return shipErr
+	}
+	// Ship only returns without an error once every input has been read
+	if err := <-readErr; err != nil {
+		return err
+	}
+	return progress.finish()
+}
+
+// newSender connects to the API, or to the worker when a gRPC address is
+// given, returning a function that closes the connection
+func newSender(opts sendOptions) (shipper.Sender, func(), error) {
+	if opts.grpcAddress != "" {
+		grpcClient, err := v0.NewGRPCLogClient(opts.grpcAddress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to worker: %w", err)
+		}
+		return timeoutSender{grpcClient, opts.timeout}, func() { grpcClient.Close() }, nil
+	}
+	apiClient, err := client.New(opts.server, opts.timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return apiClient, func() {}, nil
+}
+
+// openQueue opens the disk queue, if one is configured, reporting the
+// entries earlier runs left in it
+func openQueue(opts queueOptions, progress *sendProgress) (*shipper.Queue, error) {
+	if opts.dir == "" {
+		return nil, nil
+	}
+	policy, err := shipper.ParseSyncPolicy(opts.sync)
+	if err != nil {
+		return nil, err
+	}
+	if opts.maxSize < 0 {
+		return nil, fmt.Errorf("queue size must not be negative, got %d", opts.maxSize)
+	}
+	queueOpts := shipper.DefaultQueueOptions
+	queueOpts.MaxSize = opts.maxSize << 20
+	queueOpts.Sync = policy
+	if queueOpts.MaxSize > 0 {
+		// Whole segments are evicted, so they are kept small next to the queue
+		queueOpts.SegmentSize = min(queueOpts.SegmentSize, max(queueOpts.MaxSize/8, 64<<10))
+	}
+	queueOpts.OnEvict = progress.evict
+
+	queue, err := shipper.OpenQueue(opts.dir, queueOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue: %w", err)
+	}
+	if queued := queue.Len(); queued > 0 && !progress.quiet {
+		outputs.PrintColoredMessageTo(progress.out, "cyan", "Sending %d entries queued by an earlier run first", queued)
+	}
+	return queue, nil
+}
+
+// timeoutSender bounds every send of a sender without its own timeout
+type timeoutSender struct {
+	shipper.Sender
+	timeout time.Duration
+}
+
+func (s timeoutSender) ForwardLogs(ctx context.Context, batch []events.Entry) (v0.IngestResult, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.Sender.ForwardLogs(ctx, batch)
+}
+
+// readInputs decodes each input in turn onto items. Entries that cannot
+// be decoded are reported and skipped.
+func readInputs(ctx context.Context, stdin io.Reader, inputs []string, format shipper.Format, defaults shipper.Defaults, items chan<- shipper.Item, progress *sendProgress) error {
+	for _, input := range inputs {
+		if err := readInput(ctx, stdin, input, format, defaults, items, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readInput(ctx context.Context, stdin io.Reader, input string, format shipper.Format, defaults shipper.Defaults, items chan<- shipper.Item, progress *sendProgress) error {
+	name, r := "stdin", stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open input: %w", err)
+		}
+		defer file.Close()
+		name, r = input, file
+	}
+
+	decoder := shipper.NewDecoder(r, format, defaults)
+	for {
+		record, err := decoder.Next()
+		var entryErr *shipper.EntryError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.As(err, &entryErr):
+			progress.reject(fmt.Sprintf("%s:%d", name, entryErr.Position), entryErr.Err.Error())
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		item := shipper.Item{Entry: record.Entry, Source: fmt.Sprintf("%s:%d", name, record.Position)}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendProgress counts and reports the outcome of a send
+type sendProgress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	quiet    bool
+	sent     int64
+	accepted int64
+	rejected int64
+}
+
+// reject reports an entry that could not be decoded
+func (p *sendProgress) reject(source string, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rejected++
+	outputs.PrintColoredMessageTo(p.out, "red", "%s: rejected: %s", source, reason)
+}
+
+// report reports a batch sent, listing the entries the server rejected
+func (p *sendProgress) report(report shipper.Report) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range report.Result.Errors {
+		if e.Index >= 0 && e.Index < len(report.Items) {
+			outputs.PrintColoredMessageTo(p.out, "red", "%s: rejected: %s", report.Items[e.Index].Source, e.Reason)
+		}
+	}
+	p.sent += int64(len(report.Items))
+	p.accepted += report.Result.Accepted
+	p.rejected += report.Result.Rejected
+	if !p.quiet {
+		outputs.PrintColoredMessageTo(p.out, "cyan", "Sent %d entries (%d in total)", len(report.Items), p.sent)
+	}
+}
+
+// evict reports entries dropped from a full queue
+func (p *sendProgress) evict(dropped int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	outputs.PrintColoredMessageTo(p.out, "red", "Queue full, dropped the %d oldest entries", dropped)
+}
+
+func (p *sendProgress) retry(err error, attempt int, wait time.Duration) {
+	if p.quiet {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	outputs.PrintColoredMessageTo(p.out, "yellow", "Attempt %d failed, retrying in %s: %v", attempt, wait, err)
+}
+
+// finish prints a summary, failing when any entry was rejected
+func (p *sendProgress) finish() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rejected > 0 {
+		return fmt.Errorf("%d entries were rejected, %d accepted", p.rejected, p.accepted)
+	}
+	if !p.quiet {
+		outputs.PrintColoredMessageTo(p.out, "green", "Sent %d entries", p.accepted)
+	}
+	return nil
+}
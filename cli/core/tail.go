@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/render"
+	"github.com/jgfranco17/echoris/internal/outputs"
+	"github.com/jgfranco17/echoris/service/querylang"
+	"github.com/spf13/cobra"
+)
+
+// tailOptions hold the flags of the tail command
+type tailOptions struct {
+	server    string
+	query     client.Query
+	follow    bool
+	lines     int
+	highlight []string
+	output    string
+	template  string
+	timeout   time.Duration
+}
+
+func runTail(cmd *cobra.Command, opts tailOptions) error {
+	if opts.lines < 0 {
+		return fmt.Errorf("lines must not be negative, got %d", opts.lines)
+	}
+	if opts.output == string(render.FormatJSON) {
+		return fmt.Errorf("tail cannot print a JSON array, use ndjson instead")
+	}
+
+	apiClient, err := client.New(opts.server, opts.timeout)
+	if err != nil {
+		return err
+	}
+	renderer, err := render.New(cmd.OutOrStdout(), render.Options{
+		Format:    render.Format(opts.output),
+		Template:  opts.template,
+		Highlight: highlightTerms(opts.query, opts.highlight),
+	})
+	if err != nil {
+		return err
+	}
+	defer renderer.Close()
+
+	if !opts.follow {
+		return printLatest(cmd, apiClient, opts, renderer)
+	}
+	return apiClient.FollowLogs(cmd.Context(), opts.query, client.FollowOptions{
+		Replay:     opts.lines,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+		OnRetry: func(err error, wait time.Duration) {
+			outputs.PrintColoredMessageTo(cmd.ErrOrStderr(), "yellow", "Tail interrupted (%v), reconnecting in %s", err, wait)
+		},
+	}, func(event client.TailEvent) error {
+		return renderer.Render(event.Entry)
+	})
+}
+
+// printLatest prints the most recent matching logs, oldest first
+func printLatest(cmd *cobra.Command, apiClient *client.Client, opts tailOptions, renderer render.Renderer) error {
+	if opts.lines == 0 {
+		return nil
+	}
+	query := opts.query
+	query.Limit = opts.lines
+	page, err := apiClient.QueryLogs(cmd.Context(), query)
+	if err != nil {
+		return err
+	}
+	for _, entry := range slices.Backward(page.Logs) {
+		if err := renderer.Render(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// highlightTerms returns the words to highlight: those searched for with
+// the text search or message~ terms of the query, and extra
+func highlightTerms(query client.Query, extra []string) []string {
+	terms := append(strings.Fields(query.Text), extra...)
+	if query.Expr != "" {
+		// The server reports queries that do not parse
+		if expr, err := querylang.Parse(query.Expr); err == nil {
+			terms = append(terms, messageTerms(expr)...)
+		}
+	}
+	return terms
+}
+
+// messageTerms collects the words of the message~ terms of expr, other
+// than negated ones
+func messageTerms(expr querylang.Expr) []string {
+	switch e := expr.(type) {
+	case *querylang.And:
+		return append(messageTerms(e.Left), messageTerms(e.Right)...)
+	case *querylang.Or:
+		return append(messageTerms(e.Left), messageTerms(e.Right)...)
+	case *querylang.Term:
+		if e.Key == querylang.KeyMessage && e.Op == querylang.OpMatches {
+			return strings.Fields(e.Value)
+		}
+	}
+	return nil
+}
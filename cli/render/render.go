@@ -0,0 +1,236 @@
+// Package render writes log entries to the terminal as a table, JSON,
+// NDJSON or a Go template
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	"github.com/jgfranco17/echoris/internal/outputs"
+)
+
+// Format is how entries are written
+type Format string
+
+const (
+	FormatTable  Format = "table"  // Aligned columns, colored by level
+	FormatJSON   Format = "json"   // A single indented JSON array
+	FormatNDJSON Format = "ndjson" // One JSON entry per line
+)
+
+// timeLayout has a fixed width so that table columns line up
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Options configure a renderer
+type Options struct {
+	Format    Format
+	Template  string   // Executed once per entry, instead of the format, when set
+	Highlight []string // Words highlighted in the messages of a table, ignoring case
+}
+
+// Renderer writes entries as they arrive
+type Renderer interface {
+	Render(entry events.Entry) error
+	// Close completes the output, such as the end of a JSON array
+	Close() error
+}
+
+// New creates a renderer writing to w
+func New(w io.Writer, opts Options) (Renderer, error) {
+	if opts.Template != "" {
+		return newTemplateRenderer(w, opts.Template)
+	}
+	switch opts.Format {
+	case FormatTable:
+		return &tableRenderer{w: w, highlight: highlighter(opts.Highlight)}, nil
+	case FormatJSON:
+		return &jsonRenderer{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonRenderer{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected table, json or ndjson", opts.Format)
+}
+
+// tableRenderer writes a row per entry in the color of its level
+type tableRenderer struct {
+	w         io.Writer
+	highlight *regexp.Regexp // Nil when nothing is highlighted
+	started   bool
+}
+
+func (r *tableRenderer) Render(entry events.Entry) error {
+	if !r.started {
+		fmt.Fprintf(r.w, "%-*s  %-5s  %-12s  %s\n", len(timeLayout), "TIMESTAMP", "LEVEL", "SERVICE", "MESSAGE")
+		outputs.PrintTerminalWideLineTo(r.w, "-")
+		r.started = true
+	}
+
+	textColor := outputs.LevelColor(entry.Level)
+	if r.highlight == nil {
+		outputs.PrintColoredMessageTo(r.w, textColor, "%s", Row(entry))
+		return nil
+	}
+	head, message, fields := rowParts(entry)
+	_, err := fmt.Fprintln(r.w, colorize(textColor, head)+highlightMatches(textColor, message, r.highlight)+colorize(textColor, fields))
+	return err
+}
+
+func (r *tableRenderer) Close() error {
+	return nil
+}
+
+// Row formats an entry as a table row: its time, level, service, message
+// and fields in key order
+func Row(entry events.Entry) string {
+	head, message, fields := rowParts(entry)
+	return head + message + fields
+}
+
+func rowParts(entry events.Entry) (head, message, fields string) {
+	head = fmt.Sprintf("%s  %-5s  %-12s  ", entry.Timestamp.UTC().Format(timeLayout), strings.ToUpper(entry.Level), entry.Service)
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(entry.Fields)) {
+		fmt.Fprintf(&b, " %s=%s", key, fieldText(entry.Fields[key]))
+	}
+	return head, entry.Message, b.String()
+}
+
+// highlighter matches any of words, ignoring case, preferring the longest
+func highlighter(words []string) *regexp.Regexp {
+	var patterns []string
+	for _, word := range words {
+		if word != "" {
+			patterns = append(patterns, regexp.QuoteMeta(word))
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	slices.SortFunc(patterns, func(a, b string) int { return len(b) - len(a) })
+	return regexp.MustCompile("(?i)" + strings.Join(patterns, "|"))
+}
+
+// highlightMatches colors text, highlighting what pattern matches
+func highlightMatches(textColor string, text string, pattern *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(colorize(textColor, text[last:match[0]]))
+		b.WriteString(outputs.Highlight(text[match[0]:match[1]]))
+		last = match[1]
+	}
+	b.WriteString(colorize(textColor, text[last:]))
+	return b.String()
+}
+
+// colorize colors text, leaving empty text without escape codes
+func colorize(textColor string, text string) string {
+	if text == "" {
+		return ""
+	}
+	return outputs.Colorize(textColor, text)
+}
+
+// fieldText writes strings as they are and other values as JSON
+func fieldText(value any) string {
+	if text, ok := value.(string); ok {
+		if strings.ContainsAny(text, " \"") {
+			return fmt.Sprintf("%q", text)
+		}
+		return text
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// jsonRenderer writes every entry into one array, closed by Close
+type jsonRenderer struct {
+	w     io.Writer
+	count int
+}
+
+func (r *jsonRenderer) Render(entry events.Entry) error {
+	encoded, err := json.MarshalIndent(entry, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode log: %w", err)
+	}
+	separator := ",\n  "
+	if r.count == 0 {
+		separator = "[\n  "
+	}
+	r.count++
+	_, err = fmt.Fprintf(r.w, "%s%s", separator, encoded)
+	return err
+}
+
+func (r *jsonRenderer) Close() error {
+	if r.count == 0 {
+		_, err := fmt.Fprintln(r.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(r.w, "\n]")
+	return err
+}
+
+type ndjsonRenderer struct {
+	encoder *json.Encoder
+}
+
+func (r *ndjsonRenderer) Render(entry events.Entry) error {
+	return r.encoder.Encode(entry)
+}
+
+func (r *ndjsonRenderer) Close() error {
+	return nil
+}
+
+// templateRenderer executes a template per entry, ending each with a
+// newline
+type templateRenderer struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func newTemplateRenderer(w io.Writer, text string) (*templateRenderer, error) {
+	tmpl, err := template.New("entry").Funcs(template.FuncMap{
+		"json": func(value any) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+		"time": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &templateRenderer{w: w, tmpl: tmpl}, nil
+}
+
+func (r *templateRenderer) Render(entry events.Entry) error {
+	var out strings.Builder
+	if err := r.tmpl.Execute(&out, entry); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	_, err := io.WriteString(r.w, out.String())
+	return err
+}
+
+func (r *templateRenderer) Close() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jgfranco17/echoris/api/events"
+	"github.com/jgfranco17/echoris/cli/render"
+	"github.com/jgfranco17/echoris/internal/outputs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var entries = []events.Entry{
+	{
+		Timestamp: time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC),
+		Service:   "api",
+		Level:     "error",
+		Message:   "request failed",
+		Fields:    map[string]any{"user": "alice", "status": 500.0, "path": "/v0/logs?q=a b"},
+	},
+	{
+		Timestamp: time.Date(2024, 5, 31, 8, 0, 1, 500000000, time.UTC),
+		Service:   "db",
+		Level:     "info",
+		Message:   "ok",
+	},
+}
+
+func renderAll(t *testing.T, format render.Format, tmpl string, input []events.Entry) string {
+	t.Helper()
+	var out bytes.Buffer
+	renderer, err := render.New(&out, render.Options{Format: format, Template: tmpl})
+	require.NoError(t, err)
+	for _, entry := range input {
+		require.NoError(t, renderer.Render(entry))
+	}
+	require.NoError(t, renderer.Close())
+	return out.String()
+}
+
+func TestRow(t *testing.T) {
+	assert.Equal(t,
+		`2024-05-31T08:00:00.000Z  ERROR  api           request failed path="/v0/logs?q=a b" status=500 user=alice`,
+		render.Row(entries[0]))
+}
+
+func TestTable(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(renderAll(t, render.FormatTable, "", entries), "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "TIMESTAMP"))
+	assert.Contains(t, lines[1], "-----")
+	assert.Contains(t, lines[3], "2024-05-31T08:00:01.500Z  INFO   db            ok")
+}
+
+func TestJSON(t *testing.T) {
+	assert.Equal(t, "[]\n", renderAll(t, render.FormatJSON, "", nil))
+
+	out := renderAll(t, render.FormatJSON, "", entries)
+	assert.True(t, strings.HasPrefix(out, "[\n  {\n    \"timestamp\": \"2024-05-31T08:00:00Z\""))
+	assert.True(t, strings.HasSuffix(out, "}\n]\n"))
+	assert.Contains(t, out, "},\n  {")
+}
+
+func TestNDJSON(t *testing.T) {
+	out := renderAll(t, render.FormatNDJSON, "", entries)
+	assert.Equal(t, `{"timestamp":"2024-05-31T08:00:01.5Z","service":"db","level":"info","message":"ok"}`,
+		strings.Split(out, "\n")[1])
+}
+
+func TestTemplate(t *testing.T) {
+	out := renderAll(t, render.FormatTable, `{{upper .Level}} {{.Service}}: {{.Message}} {{json .Fields.status}}`, entries)
+	assert.Equal(t, "ERROR api: request failed 500\nINFO db: ok null\n", out)
+
+	_, err := render.New(&bytes.Buffer{}, render.Options{Format: render.FormatTable, Template: "{{.Message"})
+	assert.ErrorContains(t, err, "invalid template")
+	_, err = render.New(&bytes.Buffer{}, render.Options{Format: "yaml"})
+	assert.EqualError(t, err, `unknown output format "yaml", expected table, json or ndjson`)
+}
+
+func TestTableHighlight(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	var out bytes.Buffer
+	renderer, err := render.New(&out, render.Options{Format: render.FormatTable, Highlight: []string{"fail", "request failed", ""}})
+	require.NoError(t, err)
+	require.NoError(t, renderer.Render(events.Entry{Level: "error", Message: "Request failed, will fail again"}))
+
+	row := strings.Split(out.String(), "\n")[2]
+	assert.Contains(t, row, outputs.Highlight("Request failed"), "longest word preferred, ignoring case")
+	assert.Contains(t, row, outputs.Colorize("red", ", will ")+outputs.Highlight("fail")+outputs.Colorize("red", " again"))
+}
@@ -0,0 +1,299 @@
+// Package shipper reads log entries from files and streams and sends them
+// to Echoris in batches
+package shipper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jgfranco17/echoris/api/events"
+)
+
+// Format is the encoding of the entries a Decoder reads
+type Format string
+
+const (
+	FormatAuto   Format = "auto"   // JSON when the input starts with [ or {, text otherwise
+	FormatJSON   Format = "json"   // A JSON array of entries, or entries one after another
+	FormatNDJSON Format = "ndjson" // One JSON entry per line
+	FormatText   Format = "text"   // One message per line
+)
+
+// ParseFormat checks that value names a format
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case FormatAuto, FormatJSON, FormatNDJSON, FormatText:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected auto, json, ndjson or text", value)
+}
+
+// Defaults fill in what decoded entries leave out
+type Defaults struct {
+	Service string
+	Level   string
+	Now     func() time.Time // Timestamp of entries without one; time.Now if nil
+}
+
+// Apply fills in the parts of entry that are missing
+func (d Defaults) Apply(entry *events.Entry) {
+	if entry.Service == "" {
+		entry.Service = d.Service
+	}
+	if entry.Level == "" {
+		entry.Level = d.Level
+	}
+	if entry.Timestamp.IsZero() {
+		if d.Now != nil {
+			entry.Timestamp = d.Now()
+		} else {
+			entry.Timestamp = time.Now()
+		}
+	}
+}
+
+// Record is a decoded entry and where it was in the input: its line for
+// line-based formats, or its position among the entries of JSON input
+type Record struct {
+	Entry    events.Entry
+	Position int
+}
+
+// EntryError reports an entry that could not be decoded. Decoding
+// continues with the next entry.
+type EntryError struct {
+	Position int
+	Err      error
+}
+
+func (e *EntryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads entries from a stream
+type Decoder struct {
+	reader   *bufio.Reader
+	format   Format
+	defaults Defaults
+	json     *json.Decoder
+	position int
+	started  bool
+}
+
+// NewDecoder creates a decoder reading entries of format from r
+func NewDecoder(r io.Reader, format Format, defaults Defaults) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r), format: format, defaults: defaults}
+}
+
+// Next returns the next entry, an *EntryError for an entry that could not
+// be decoded, or io.EOF at the end of the input
+func (d *Decoder) Next() (Record, error) {
+	if !d.started {
+		if err := d.start(); err != nil {
+			return Record{}, err
+		}
+		d.started = true
+	}
+	if d.format == FormatJSON {
+		return d.nextJSON()
+	}
+
+	for {
+		line, err := d.reader.ReadString('\n')
+		if line == "" && err != nil {
+			return Record{}, err
+		}
+		if err != nil && err != io.EOF {
+			return Record{}, fmt.Errorf("failed to read input: %w", err)
+		}
+		d.position++
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return d.decodeLine(line)
+	}
+}
+
+// start resolves the automatic format from the first character of the
+// input and opens JSON input
+func (d *Decoder) start() error {
+	if d.format == FormatAuto {
+		first, err := d.firstRune()
+		if err != nil {
+			return err
+		}
+		switch first {
+		case '[':
+			d.format = FormatJSON
+		case '{':
+			d.format = FormatNDJSON
+		default:
+			d.format = FormatText
+		}
+	}
+	if d.format != FormatJSON {
+		return nil
+	}
+
+	d.json = json.NewDecoder(d.reader)
+	first, err := d.firstRune()
+	if err != nil {
+		return err
+	}
+	if first == '[' {
+		if _, err := d.json.Token(); err != nil {
+			return fmt.Errorf("invalid JSON input: %w", err)
+		}
+	}
+	d.position = 0 // Positions of JSON input count entries, not lines
+	return nil
+}
+
+// firstRune peeks at the first character of the input that is not a space
+func (d *Decoder) firstRune() (rune, error) {
+	for {
+		r, _, err := d.reader.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if unicode.IsSpace(r) {
+			if r == '\n' {
+				d.position++
+			}
+			continue
+		}
+		return r, d.reader.UnreadRune()
+	}
+}
+
+// nextJSON decodes the next element of JSON input. Malformed JSON stops
+// decoding, as the decoder cannot tell where the next entry starts.
+func (d *Decoder) nextJSON() (Record, error) {
+	if !d.json.More() {
+		if _, err := d.json.Token(); err != nil && err != io.EOF {
+			return Record{}, fmt.Errorf("invalid JSON input: %w", err)
+		}
+		return Record{}, io.EOF
+	}
+	var raw json.RawMessage
+	if err := d.json.Decode(&raw); err != nil {
+		return Record{}, fmt.Errorf("invalid JSON input: %w", err)
+	}
+	d.position++
+	entry, err := DecodeEntry(raw, d.defaults)
+	if err != nil {
+		return Record{}, &EntryError{Position: d.position, Err: err}
+	}
+	return Record{Entry: entry, Position: d.position}, nil
+}
+
+// decodeLine decodes one line of NDJSON or text input
+func (d *Decoder) decodeLine(line string) (Record, error) {
+	if d.format == FormatText {
+		return Record{Entry: TextEntry(line, d.defaults), Position: d.position}, nil
+	}
+	entry, err := DecodeEntry([]byte(line), d.defaults)
+	if err != nil {
+		return Record{}, &EntryError{Position: d.position, Err: err}
+	}
+	return Record{Entry: entry, Position: d.position}, nil
+}
+
+// TextEntry makes an entry of a plain text line
+func TextEntry(line string, defaults Defaults) events.Entry {
+	entry := events.Entry{Message: line}
+	defaults.Apply(&entry)
+	return entry
+}
+
+// DecodeEntry decodes a JSON entry with the keys of POST /v0/logs. Other
+// top-level keys are added to the entry's fields.
+func DecodeEntry(data []byte, defaults Defaults) (events.Entry, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return events.Entry{}, errors.New("invalid log entry, expected a JSON object")
+	}
+
+	var entry events.Entry
+	for key, value := range object {
+		var err error
+		switch key {
+		case "timestamp":
+			err = decodeTimestamp(value, &entry.Timestamp)
+		case "service":
+			err = decodeString(key, value, &entry.Service)
+		case "level":
+			err = decodeString(key, value, &entry.Level)
+		case "message":
+			err = decodeString(key, value, &entry.Message)
+		case "fields":
+			continue
+		default:
+			err = decodeField(key, value, &entry)
+		}
+		if err != nil {
+			return events.Entry{}, err
+		}
+	}
+
+	// Explicit fields take precedence over top-level keys of the same name
+	if value, ok := object["fields"]; ok {
+		var fields map[string]any
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return events.Entry{}, errors.New("invalid fields, expected a JSON object")
+		}
+		for key, field := range fields {
+			setField(&entry, key, field)
+		}
+	}
+
+	defaults.Apply(&entry)
+	return entry, nil
+}
+
+func decodeTimestamp(value json.RawMessage, out *time.Time) error {
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return errors.New("invalid timestamp, expected an RFC 3339 string")
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q, expected RFC 3339", text)
+	}
+	*out = parsed
+	return nil
+}
+
+func decodeString(key string, value json.RawMessage, out *string) error {
+	if err := json.Unmarshal(value, out); err != nil {
+		return fmt.Errorf("invalid %s, expected a string", key)
+	}
+	return nil
+}
+
+func decodeField(key string, value json.RawMessage, entry *events.Entry) error {
+	var field any
+	if err := json.Unmarshal(value, &field); err != nil {
+		return fmt.Errorf("invalid value of %s: %w", key, err)
+	}
+	setField(entry, key, field)
+	return nil
+}
+
+func setField(entry *events.Entry, key string, value any) {
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]any)
+	}
+	entry.Fields[key] = value
+}
@@ -0,0 +1,151 @@
+package shipper_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+
+var defaults = shipper.Defaults{Service: "api", Level: "info", Now: func() time.Time { return now }}
+
+// decodeAll reads every record of input, collecting the positions of
+// entries that could not be decoded
+func decodeAll(t *testing.T, input string, format shipper.Format) ([]shipper.Record, []int) {
+	t.Helper()
+	decoder := shipper.NewDecoder(strings.NewReader(input), format, defaults)
+	var records []shipper.Record
+	var rejected []int
+	for {
+		record, err := decoder.Next()
+		var entryErr *shipper.EntryError
+		switch {
+		case errors.Is(err, io.EOF):
+			return records, rejected
+		case errors.As(err, &entryErr):
+			rejected = append(rejected, entryErr.Position)
+			continue
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestDecoderFormats(t *testing.T) {
+	ndjson := `{"timestamp":"2024-05-31T07:00:00Z","service":"db","level":"error","message":"a","fields":{"user":"alice"}}
+
+{"message":"b","request_id":"r1"}
+not json
+{"message":"c","timestamp":12}
+`
+	t.Run("ndjson", func(t *testing.T) {
+		records, rejected := decodeAll(t, ndjson, shipper.FormatNDJSON)
+		require.Len(t, records, 2)
+		assert.Equal(t, shipper.Record{Position: 1, Entry: events.Entry{
+			Timestamp: now.Add(-time.Hour),
+			Service:   "db",
+			Level:     "error",
+			Message:   "a",
+			Fields:    map[string]any{"user": "alice"},
+		}}, records[0])
+		assert.Equal(t, shipper.Record{Position: 3, Entry: events.Entry{
+			Timestamp: now,
+			Service:   "api",
+			Level:     "info",
+			Message:   "b",
+			Fields:    map[string]any{"request_id": "r1"},
+		}}, records[1])
+		assert.Equal(t, []int{4, 5}, rejected)
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		records, rejected := decodeAll(t, `[{"message":"a"}, "b", {"message":"c","level":"warn"}]`, shipper.FormatJSON)
+		require.Len(t, records, 2)
+		assert.Equal(t, 1, records[0].Position)
+		assert.Equal(t, "c", records[1].Entry.Message)
+		assert.Equal(t, "warn", records[1].Entry.Level)
+		assert.Equal(t, 3, records[1].Position)
+		assert.Equal(t, []int{2}, rejected)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		records, _ := decodeAll(t, "first line\r\n\n{not an entry}\n", shipper.FormatText)
+		require.Len(t, records, 2)
+		assert.Equal(t, events.Entry{Timestamp: now, Service: "api", Level: "info", Message: "first line"}, records[0].Entry)
+		assert.Equal(t, "{not an entry}", records[1].Entry.Message)
+		assert.Equal(t, 3, records[1].Position)
+	})
+
+	t.Run("detected", func(t *testing.T) {
+		cases := map[string]string{
+			"\n\n" + ndjson:           "a",
+			` [{"message":"x"}]`:      "x",
+			"plain text\nmore text\n": "plain text",
+		}
+		for input, message := range cases {
+			records, _ := decodeAll(t, input, shipper.FormatAuto)
+			require.NotEmpty(t, records, input)
+			assert.Equal(t, message, records[0].Entry.Message, input)
+		}
+		records, _ := decodeAll(t, "\n\n"+ndjson, shipper.FormatAuto)
+		assert.Equal(t, 3, records[0].Position, "lines skipped while detecting are counted")
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		for _, format := range []shipper.Format{shipper.FormatAuto, shipper.FormatJSON, shipper.FormatNDJSON, shipper.FormatText} {
+			records, rejected := decodeAll(t, "  \n", format)
+			assert.Empty(t, records, format)
+			assert.Empty(t, rejected, format)
+		}
+	})
+}
+
+func TestDecoderMalformedJSON(t *testing.T) {
+	decoder := shipper.NewDecoder(strings.NewReader(`[{"message":"a"}, {"message":`), shipper.FormatJSON, defaults)
+	_, err := decoder.Next()
+	require.NoError(t, err)
+	_, err = decoder.Next()
+	var entryErr *shipper.EntryError
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &entryErr), "malformed JSON stops decoding")
+}
+
+func TestDecodeEntry(t *testing.T) {
+	t.Run("fields take precedence over top-level keys", func(t *testing.T) {
+		entry, err := shipper.DecodeEntry([]byte(`{"user":"top","fields":{"user":"nested"},"status":500}`), defaults)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"user": "nested", "status": 500.0}, entry.Fields)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		entries := map[string]string{
+			`[]`:                       "invalid log entry, expected a JSON object",
+			`{"level":3}`:              "invalid level, expected a string",
+			`{"timestamp":"today"}`:    `invalid timestamp "today", expected RFC 3339`,
+			`{"fields":["a"]}`:         "invalid fields, expected a JSON object",
+			`{"message":{"a":"b"}}`:    "invalid message, expected a string",
+			`{"timestamp":1717142400}`: "invalid timestamp, expected an RFC 3339 string",
+		}
+		for input, message := range entries {
+			_, err := shipper.DecodeEntry([]byte(input), defaults)
+			assert.EqualError(t, err, message, input)
+		}
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := shipper.ParseFormat("ndjson")
+	require.NoError(t, err)
+	assert.Equal(t, shipper.FormatNDJSON, format)
+
+	_, err = shipper.ParseFormat("csv")
+	assert.EqualError(t, err, `unknown format "csv", expected auto, json, ndjson or text`)
+}
@@ -0,0 +1,634 @@
+package shipper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+)
+
+// SyncPolicy is when a queue flushes what is written to it to disk
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // After every write, so that nothing is lost if the machine crashes
+	SyncInterval SyncPolicy = "interval" // At most once per SyncEvery, losing no more than that on a crash of the machine
+	SyncNever    SyncPolicy = "never"    // Left to the operating system, so that only crashes of the process are survived
+)
+
+// ParseSyncPolicy checks that value names a sync policy
+func ParseSyncPolicy(value string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(value); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown sync policy %q, expected always, interval or never", value)
+}
+
+// QueueOptions configure a queue
+type QueueOptions struct {
+	SegmentSize int64 // Size past which a new segment file is started
+	MaxSize     int64 // Most bytes kept, evicting the oldest segments past it; unlimited when zero
+	Sync        SyncPolicy
+	SyncEvery   time.Duration
+	OnEvict     func(dropped int) // Called with the number of items evicted unsent
+}
+
+// DefaultQueueOptions keep up to 256 MiB in 8 MiB segments, synced every
+// second
+var DefaultQueueOptions = QueueOptions{
+	SegmentSize: 8 << 20,
+	MaxSize:     256 << 20,
+	Sync:        SyncInterval,
+	SyncEvery:   time.Second,
+}
+
+const (
+	segmentExt    = ".wal"
+	cursorName    = "cursor.json"
+	headerSize    = 8        // Length and checksum of a record
+	maxRecordSize = 64 << 20 // Larger lengths can only come from corruption
+)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// position is where an item starts in the queue
+type position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+	Index   int    `json:"index"` // Items before Offset in the segment
+}
+
+func (p position) before(other position) bool {
+	return p.Segment < other.Segment || (p.Segment == other.Segment && p.Offset < other.Offset)
+}
+
+// segment is a file of records, each an item encoded as JSON after its
+// length and CRC-32 checksum
+type segment struct {
+	id    uint64
+	size  int64
+	count int
+}
+
+// record is how an item is written to a queue
+type record struct {
+	Entry  events.Entry `json:"entry"`
+	Source string       `json:"source,omitempty"`
+}
+
+// Queue is a write-ahead queue of items on disk, so that what is sent
+// while the server is unreachable is kept until it is back, even across
+// restarts. Items are read in the order they were appended, and stay in
+// the queue until acknowledged. A directory must be used by a single
+// queue at a time.
+type Queue struct {
+	mu        sync.Mutex
+	dir       string
+	opts      QueueOptions
+	segments  []*segment // Oldest first; items are appended to the last
+	file      *os.File
+	writer    *bufio.Writer
+	size      int64
+	read      position // First item not acknowledged
+	peeked    position // End of the items last peeked
+	lastSync  time.Time
+	syncTimer *time.Timer
+}
+
+// OpenQueue opens the queue kept in dir, creating it if needed. Records
+// left incomplete by a crash are discarded.
+func OpenQueue(dir string, opts QueueOptions) (*Queue, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultQueueOptions.SegmentSize
+	}
+	if opts.Sync == "" {
+		opts.Sync = DefaultQueueOptions.Sync
+	}
+	if _, err := ParseSyncPolicy(string(opts.Sync)); err != nil {
+		return nil, err
+	}
+	if opts.SyncEvery <= 0 {
+		opts.SyncEvery = DefaultQueueOptions.SyncEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &Queue{dir: dir, opts: opts, lastSync: time.Now()}
+	read, err := q.loadCursor()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.loadSegments(read); err != nil {
+		return nil, err
+	}
+
+	first := q.segments[0]
+	switch {
+	case read.Segment < first.id:
+		read = position{Segment: first.id}
+	case read.Offset > first.size:
+		read = position{Segment: first.id, Offset: first.size, Index: first.count}
+	}
+	q.read, q.peeked = read, read
+
+	if err := q.openActive(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// loadSegments scans the segments of the queue's directory, removing those
+// acknowledged before read. An empty queue starts a segment after read.
+func (q *Queue) loadSegments(read position) error {
+	ids, err := listSegments(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id < read.Segment {
+			// Acknowledged before the queue was last closed
+			if err := os.Remove(q.segmentPath(id)); err != nil {
+				return fmt.Errorf("failed to remove queue segment: %w", err)
+			}
+			continue
+		}
+		seg, err := scanSegment(q.segmentPath(id))
+		if err != nil {
+			return err
+		}
+		seg.id = id
+		q.segments = append(q.segments, seg)
+		q.size += seg.size
+	}
+	if len(q.segments) == 0 {
+		q.segments = []*segment{{id: read.Segment + 1}}
+	}
+	return nil
+}
+
+// Append writes items to the end of the queue, evicting the oldest
+// segments if the queue would grow past its maximum size
+func (q *Queue) Append(items []Item) error {
+	q.mu.Lock()
+	dropped, err := q.append(items)
+	q.mu.Unlock()
+	if dropped > 0 && q.opts.OnEvict != nil {
+		q.opts.OnEvict(dropped)
+	}
+	return err
+}
+
+func (q *Queue) append(items []Item) (int, error) {
+	if q.file == nil {
+		return 0, errors.New("queue is closed")
+	}
+	dropped := 0
+	for _, item := range items {
+		data, err := encodeRecord(item)
+		if err != nil {
+			return dropped, err
+		}
+		size := int64(len(data))
+		if q.opts.MaxSize > 0 && size > q.opts.MaxSize {
+			return dropped, fmt.Errorf("entry of %d bytes is larger than the queue", size)
+		}
+		evicted, err := q.makeRoom(size)
+		dropped += evicted
+		if err != nil {
+			return dropped, err
+		}
+		if active := q.active(); active.size > 0 && active.size+size > q.opts.SegmentSize {
+			if err := q.roll(); err != nil {
+				return dropped, err
+			}
+		}
+
+		if _, err := q.writer.Write(data); err != nil {
+			return dropped, fmt.Errorf("failed to write to queue: %w", err)
+		}
+		active := q.active()
+		active.size += size
+		active.count++
+		q.size += size
+	}
+	if err := q.writer.Flush(); err != nil {
+		return dropped, fmt.Errorf("failed to write to queue: %w", err)
+	}
+	return dropped, q.syncWrites()
+}
+
+// makeRoom evicts the oldest segments until size more bytes fit under the
+// maximum size, returning the number of items evicted
+func (q *Queue) makeRoom(size int64) (int, error) {
+	dropped := 0
+	for q.opts.MaxSize > 0 && q.size+size > q.opts.MaxSize {
+		if len(q.segments) == 1 {
+			if err := q.roll(); err != nil {
+				return dropped, err
+			}
+		}
+		evicted, err := q.evictOldest()
+		dropped += evicted
+		if err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// Peek returns up to n of the oldest items, without removing them
+func (q *Queue) Peek(n int) ([]Item, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var items []Item
+	pos := q.read
+	for _, seg := range q.segments {
+		if len(items) >= n {
+			break
+		}
+		if seg.id < pos.Segment {
+			continue
+		}
+		if seg.id > pos.Segment {
+			pos = position{Segment: seg.id}
+		}
+		if pos.Offset >= seg.size {
+			continue
+		}
+		read, err := q.readSegment(seg, &pos, n-len(items))
+		items = append(items, read...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	q.peeked = pos
+	return items, nil
+}
+
+// readSegment reads up to n items of a segment from pos, moving pos past
+// them
+func (q *Queue) readSegment(seg *segment, pos *position, n int) ([]Item, error) {
+	file, err := os.Open(q.segmentPath(seg.id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(pos.Offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	reader := bufio.NewReader(io.LimitReader(file, seg.size-pos.Offset))
+	var items []Item
+	for len(items) < n && pos.Offset < seg.size {
+		data, size, err := readRecord(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queue: %w", err)
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to decode queued entry: %w", err)
+		}
+		items = append(items, Item{Entry: rec.Entry, Source: rec.Source})
+		pos.Offset += size
+		pos.Index++
+	}
+	return items, nil
+}
+
+// Ack removes the items returned by the last Peek, unless they were
+// evicted meanwhile
+func (q *Queue) Ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.read.before(q.peeked) {
+		return nil
+	}
+	q.read = q.peeked
+
+	// Segments read to their end are removed, except the one written to
+	for len(q.segments) > 1 {
+		first := q.segments[0]
+		if first.id > q.read.Segment || (first.id == q.read.Segment && q.read.Offset < first.size) {
+			break
+		}
+		if err := q.removeOldest(); err != nil {
+			return err
+		}
+		if q.read.Segment <= first.id {
+			q.read = position{Segment: q.segments[0].id}
+		}
+	}
+	return q.saveCursor()
+}
+
+// Len returns the number of items not acknowledged
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := 0
+	for _, seg := range q.segments {
+		switch {
+		case seg.id == q.read.Segment:
+			count += seg.count - q.read.Index
+		case seg.id > q.read.Segment:
+			count += seg.count
+		}
+	}
+	return count
+}
+
+// Close syncs and closes the queue
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	if q.syncTimer != nil {
+		q.syncTimer.Stop()
+		q.syncTimer = nil
+	}
+	err := q.closeActive()
+	q.file = nil
+	return err
+}
+
+func (q *Queue) active() *segment {
+	return q.segments[len(q.segments)-1]
+}
+
+func (q *Queue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+// openActive opens the last segment for appending
+func (q *Queue) openActive() error {
+	file, err := os.OpenFile(q.segmentPath(q.active().id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open queue segment: %w", err)
+	}
+	q.file, q.writer = file, bufio.NewWriter(file)
+	return nil
+}
+
+func (q *Queue) closeActive() error {
+	if err := q.writer.Flush(); err != nil {
+		q.file.Close()
+		return fmt.Errorf("failed to write to queue: %w", err)
+	}
+	if q.opts.Sync != SyncNever {
+		if err := q.file.Sync(); err != nil {
+			q.file.Close()
+			return fmt.Errorf("failed to sync queue: %w", err)
+		}
+	}
+	if err := q.file.Close(); err != nil {
+		return fmt.Errorf("failed to close queue segment: %w", err)
+	}
+	return nil
+}
+
+// roll starts a new segment
+func (q *Queue) roll() error {
+	if err := q.closeActive(); err != nil {
+		return err
+	}
+	q.segments = append(q.segments, &segment{id: q.active().id + 1})
+	if err := q.openActive(); err != nil {
+		return err
+	}
+	if q.opts.Sync != SyncNever {
+		return syncDir(q.dir)
+	}
+	return nil
+}
+
+// evictOldest removes the oldest segment, returning the number of items
+// in it that were not acknowledged
+func (q *Queue) evictOldest() (int, error) {
+	first := q.segments[0]
+	dropped := 0
+	switch {
+	case first.id == q.read.Segment:
+		dropped = first.count - q.read.Index
+	case first.id > q.read.Segment:
+		dropped = first.count
+	}
+	if err := q.removeOldest(); err != nil {
+		return 0, err
+	}
+	if q.read.Segment <= first.id {
+		q.read = position{Segment: q.segments[0].id}
+	}
+	return dropped, nil
+}
+
+func (q *Queue) removeOldest() error {
+	first := q.segments[0]
+	if err := os.Remove(q.segmentPath(first.id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove queue segment: %w", err)
+	}
+	q.segments = q.segments[1:]
+	q.size -= first.size
+	return nil
+}
+
+// syncWrites syncs what was appended as the sync policy requires
+func (q *Queue) syncWrites() error {
+	switch q.opts.Sync {
+	case SyncAlways:
+		if err := q.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync queue: %w", err)
+		}
+	case SyncInterval:
+		wait := q.opts.SyncEvery - time.Since(q.lastSync)
+		if wait <= 0 {
+			q.lastSync = time.Now()
+			if err := q.file.Sync(); err != nil {
+				return fmt.Errorf("failed to sync queue: %w", err)
+			}
+		} else if q.syncTimer == nil {
+			q.syncTimer = time.AfterFunc(wait, q.syncLater)
+		}
+	}
+	return nil
+}
+
+// syncLater syncs writes made since the last sync of an interval
+func (q *Queue) syncLater() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.syncTimer = nil
+	if q.file != nil {
+		q.lastSync = time.Now()
+		_ = q.file.Sync() // A failure shows on the next write
+	}
+}
+
+func (q *Queue) loadCursor() (position, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, cursorName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return position{}, nil
+	}
+	if err != nil {
+		return position{}, fmt.Errorf("failed to read queue cursor: %w", err)
+	}
+	var pos position
+	if err := json.Unmarshal(data, &pos); err != nil {
+		return position{}, fmt.Errorf("invalid queue cursor: %w", err)
+	}
+	return pos, nil
+}
+
+// saveCursor records the read position, replacing the previous one at once
+func (q *Queue) saveCursor() error {
+	data, err := json.Marshal(q.read)
+	if err != nil {
+		return fmt.Errorf("failed to encode queue cursor: %w", err)
+	}
+	name := filepath.Join(q.dir, cursorName)
+	temp := name + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save queue cursor: %w", err)
+	}
+	if q.opts.Sync == SyncAlways {
+		if err := syncFile(temp); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(temp, name); err != nil {
+		return fmt.Errorf("failed to save queue cursor: %w", err)
+	}
+	return nil
+}
+
+// listSegments returns the ids of the segments in dir, in order
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue segments: %w", err)
+	}
+	var ids []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// scanSegment counts the records of a segment, truncating any incomplete
+// or corrupt record and what follows it
+func scanSegment(name string) (*segment, error) {
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue segment: %w", err)
+	}
+	defer file.Close()
+
+	seg := &segment{}
+	reader := bufio.NewReader(file)
+	for {
+		_, size, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return seg, nil
+		}
+		if errors.Is(err, errCorruptRecord) {
+			if err := file.Truncate(seg.size); err != nil {
+				return nil, fmt.Errorf("failed to repair queue segment: %w", err)
+			}
+			return seg, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queue segment: %w", err)
+		}
+		seg.size += size
+		seg.count++
+	}
+}
+
+func encodeRecord(item Item) ([]byte, error) {
+	payload, err := json.Marshal(record{Entry: item.Entry, Source: item.Source})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry: %w", err)
+	}
+	data := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(payload))
+	return append(data, payload...), nil
+}
+
+// readRecord returns the payload of the next record and the size of the
+// whole record, io.EOF at the end of the segment, or errCorruptRecord
+func readRecord(r io.Reader) ([]byte, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorruptRecord
+		}
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return nil, 0, errCorruptRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorruptRecord
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errCorruptRecord
+	}
+	return payload, int64(headerSize) + int64(length), nil
+}
+
+func syncFile(name string) error {
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	defer file.Close()
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	return nil
+}
+
+// syncDir syncs a directory, so that files created in it survive a crash
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync queue directory: %w", err)
+	}
+	defer file.Close()
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync queue directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package shipper_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numbered(from, to int) []shipper.Item {
+	var items []shipper.Item
+	for i := from; i <= to; i++ {
+		items = append(items, shipper.Item{Entry: events.Entry{Message: strconv.Itoa(i)}, Source: fmt.Sprintf("in:%d", i)})
+	}
+	return items
+}
+
+func messages(items []shipper.Item) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Entry.Message)
+	}
+	return out
+}
+
+func openQueue(t *testing.T, dir string, opts shipper.QueueOptions) *shipper.Queue {
+	t.Helper()
+	queue, err := shipper.OpenQueue(dir, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { queue.Close() })
+	return queue
+}
+
+var smallSegments = shipper.QueueOptions{SegmentSize: 200, Sync: shipper.SyncAlways}
+
+func TestQueueKeepsOrderAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	queue := openQueue(t, dir, smallSegments)
+	require.NoError(t, queue.Append(numbered(1, 10)))
+	assert.Equal(t, 10, queue.Len())
+
+	batch, err := queue.Peek(4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, messages(batch))
+	assert.Equal(t, "in:1", batch[0].Source)
+	require.NoError(t, queue.Ack())
+	require.NoError(t, queue.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1, "items are spread over segments")
+
+	queue = openQueue(t, dir, smallSegments)
+	assert.Equal(t, 6, queue.Len())
+	require.NoError(t, queue.Append(numbered(11, 12)))
+	batch, err = queue.Peek(100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5", "6", "7", "8", "9", "10", "11", "12"}, messages(batch))
+	require.NoError(t, queue.Ack())
+	assert.Equal(t, 0, queue.Len())
+
+	segments, err = filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1, "segments read to their end are removed")
+}
+
+func TestQueueDiscardsTornRecords(t *testing.T) {
+	dir := t.TempDir()
+	queue := openQueue(t, dir, shipper.QueueOptions{Sync: shipper.SyncNever})
+	require.NoError(t, queue.Append(numbered(1, 3)))
+	require.NoError(t, queue.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	file, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{0, 0, 0, 40, 1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	queue = openQueue(t, dir, shipper.QueueOptions{Sync: shipper.SyncNever})
+	require.NoError(t, queue.Append(numbered(4, 4)))
+	batch, err := queue.Peek(10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, messages(batch))
+}
+
+func TestQueueEvictsOldestSegments(t *testing.T) {
+	var dropped int
+	opts := smallSegments
+	opts.MaxSize = 600
+	opts.OnEvict = func(n int) { dropped += n }
+	queue := openQueue(t, t.TempDir(), opts)
+
+	require.NoError(t, queue.Append(numbered(1, 30)))
+	batch, err := queue.Peek(100)
+	require.NoError(t, err)
+	assert.Equal(t, 30, dropped+len(batch), "every item is either kept or reported dropped")
+	assert.Greater(t, dropped, 0)
+	assert.Equal(t, "30", batch[len(batch)-1].Entry.Message, "the newest items are kept")
+	assert.Equal(t, strconv.Itoa(dropped+1), batch[0].Entry.Message, "the oldest items are dropped")
+
+	_, err = shipper.OpenQueue(t.TempDir(), shipper.QueueOptions{Sync: "sometimes"})
+	assert.EqualError(t, err, `unknown sync policy "sometimes", expected always, interval or never`)
+}
+
+// standIn is an API that stores the messages posted to it while up, and
+// answers 503 while down
+type standIn struct {
+	mu       sync.Mutex
+	down     bool
+	received []string
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"message":"worker unavailable"}`)
+		return
+	}
+	var batch []events.Entry
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, entry := range batch {
+		s.received = append(s.received, entry.Message)
+	}
+	fmt.Fprintf(w, `{"message":"ok","accepted":%d,"rejected":0}`, len(batch))
+}
+
+func (s *standIn) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *standIn) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+func newStandIn(t *testing.T) (*standIn, *client.Client) {
+	t.Helper()
+	api := &standIn{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	c, err := client.New(server.URL, time.Second)
+	require.NoError(t, err)
+	return api, c
+}
+
+func queuedOptions(queue *shipper.Queue) shipper.Options {
+	return shipper.Options{
+		BatchSize:     4,
+		FlushInterval: time.Millisecond,
+		Retry:         fastRetry,
+		Queue:         queue,
+	}
+}
+
+func expected(from, to int) []string {
+	return messages(numbered(from, to))
+}
+
+func TestShipQueuedThroughOutage(t *testing.T) {
+	api, c := newStandIn(t)
+	api.setDown(true)
+	queue := openQueue(t, t.TempDir(), smallSegments)
+
+	in := make(chan shipper.Item)
+	done := make(chan error)
+	var mu sync.Mutex
+	queued := 0
+	var attempts []int
+	opts := queuedOptions(queue)
+	opts.OnQueued = func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		queued += n
+	}
+	opts.OnRetry = func(err error, attempt int, wait time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, attempt)
+	}
+	go func() {
+		done <- shipper.Ship(context.Background(), c, in, opts)
+	}()
+
+	for _, item := range numbered(1, 10) {
+		in <- item
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return queued == 10
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, api.messages(), "nothing is delivered while the server is down")
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(attempts) > fastRetry.Attempts
+	}, time.Second, time.Millisecond)
+
+	api.setDown(false)
+	for _, item := range numbered(11, 12) {
+		in <- item
+	}
+	close(in)
+	require.NoError(t, <-done)
+	assert.Equal(t, expected(1, 12), api.messages(), "queued items are delivered in order once the server is back")
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt, "attempts are counted across retries of the batch")
+	}
+	assert.Equal(t, 0, queue.Len())
+}
+
+func TestShipQueuedReplaysAfterRestart(t *testing.T) {
+	api, c := newStandIn(t)
+	api.setDown(true)
+	dir := t.TempDir()
+
+	queue, err := shipper.OpenQueue(dir, smallSegments)
+	require.NoError(t, err)
+	in := make(chan shipper.Item, 10)
+	for _, item := range numbered(1, 10) {
+		in <- item
+	}
+	close(in)
+	err = shipper.Ship(context.Background(), c, in, queuedOptions(queue))
+	assert.ErrorContains(t, err, "worker unavailable, 10 entries remain queued")
+	require.NoError(t, queue.Close())
+
+	api.setDown(false)
+	queue = openQueue(t, dir, smallSegments)
+	in = make(chan shipper.Item, 1)
+	in <- numbered(11, 11)[0]
+	close(in)
+	require.NoError(t, shipper.Ship(context.Background(), c, in, queuedOptions(queue)))
+	assert.Equal(t, expected(1, 11), api.messages(), "items of the earlier run are sent first")
+	assert.Equal(t, 0, queue.Len())
+}
+
+func TestShipQueuedDropsOldestWhenFull(t *testing.T) {
+	api, c := newStandIn(t)
+	api.setDown(true)
+	var dropped int
+	opts := smallSegments
+	opts.MaxSize = 600
+	opts.OnEvict = func(n int) { dropped += n }
+	queue := openQueue(t, t.TempDir(), opts)
+
+	in := make(chan shipper.Item, 40)
+	for _, item := range numbered(1, 40) {
+		in <- item
+	}
+	close(in)
+	err := shipper.Ship(context.Background(), c, in, queuedOptions(queue))
+	require.Error(t, err)
+	assert.Greater(t, dropped, 0)
+
+	api.setDown(false)
+	require.NoError(t, shipper.Ship(context.Background(), c, closed(), queuedOptions(queue)))
+	assert.Equal(t, expected(41-len(api.messages()), 40), api.messages(), "the newest items survive, in order")
+	assert.Equal(t, 40, dropped+len(api.messages()))
+}
+
+func closed() <-chan shipper.Item {
+	in := make(chan shipper.Item)
+	close(in)
+	return in
+}
@@ -0,0 +1,368 @@
+package shipper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/jgfranco17/echoris/cli/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sender delivers a batch of entries to Echoris, through the API or
+// straight to a worker
+type Sender interface {
+	ForwardLogs(ctx context.Context, batch []events.Entry) (v0.IngestResult, error)
+}
+
+// Item is an entry to ship and where it came from, for reporting its
+// rejection
+type Item struct {
+	Entry  events.Entry
+	Source string
+}
+
+// Report is the outcome of shipping one batch
+type Report struct {
+	Items  []Item
+	Result v0.IngestResult // Errors index into Items
+}
+
+// RetryPolicy controls how failed batches are retried. Waits double from
+// Backoff up to MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int // Attempts per batch, including the first; unlimited when zero
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries a batch for about half a minute
+var DefaultRetryPolicy = RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 15 * time.Second}
+
+// Validate checks that failed batches are waited on before they are retried
+func (p RetryPolicy) Validate() error {
+	if p.Backoff <= 0 || p.MaxBackoff <= 0 {
+		return fmt.Errorf("retry backoff must be positive, got %s up to %s", p.Backoff, p.MaxBackoff)
+	}
+	return nil
+}
+
+// delay returns the wait before the given retry, counting from 1
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// Retryable reports whether a failed send may succeed if tried again.
+// Rejected requests and cancellation are final; server and connection
+// errors are not.
+func Retryable(err error) bool {
+	var statusErr *client.StatusError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.Code >= http.StatusInternalServerError || statusErr.Code == http.StatusTooManyRequests
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// Options configure Ship
+type Options struct {
+	BatchSize     int           // Entries per batch
+	FlushInterval time.Duration // Longest a partial batch waits for more entries
+	Retry         RetryPolicy
+	OnReport      func(Report)                                     // Called after every batch sent
+	OnRetry       func(err error, attempt int, wait time.Duration) // Called before every retry
+	// Queue, when set, receives every item before it is sent, so that none
+	// is lost while the server is unreachable. Items left in it by earlier
+	// runs are sent first.
+	Queue    *Queue
+	OnQueued func(n int) // Called once the next n items are in the queue
+}
+
+// Ship sends the items received from in to sender in batches until in is
+// closed. A batch is sent when it is full or has waited FlushInterval.
+// It stops at the first batch that cannot be sent, unless items are
+// queued.
+func Ship(ctx context.Context, sender Sender, in <-chan Item, opts Options) error {
+	if opts.Queue != nil {
+		return shipQueued(ctx, sender, in, opts)
+	}
+	batch := make([]Item, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := sendBatch(ctx, sender, batch, opts, 0); err != nil {
+			return err
+		}
+		batch = make([]Item, 0, opts.BatchSize)
+		return nil
+	}
+
+	timer := time.NewTimer(opts.FlushInterval)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case item, ok := <-in:
+			if !ok {
+				return flush()
+			}
+			if len(batch) == 0 && opts.FlushInterval > 0 {
+				timer.Reset(opts.FlushInterval)
+			}
+			batch = append(batch, item)
+			if len(batch) >= opts.BatchSize {
+				timer.Stop()
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendBatch sends a batch, retrying as the policy allows. Attempts are
+// reported counting on from the tried attempts already made at the batch.
+func sendBatch(ctx context.Context, sender Sender, batch []Item, opts Options, tried int) error {
+	entries := make([]events.Entry, len(batch))
+	for i, item := range batch {
+		entries[i] = item.Entry
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := sender.ForwardLogs(ctx, entries)
+		if err == nil {
+			if opts.OnReport != nil {
+				opts.OnReport(Report{Items: batch, Result: result})
+			}
+			return nil
+		}
+		if (opts.Retry.Attempts > 0 && attempt >= opts.Retry.Attempts) || !Retryable(err) {
+			return fmt.Errorf("failed to send %d entries: %w", len(batch), err)
+		}
+
+		wait := opts.Retry.delay(attempt)
+		if opts.OnRetry != nil {
+			opts.OnRetry(err, tried+attempt, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// shipQueued writes the items received from in to the queue while sending
+// what the queue holds, in order. While in is open, batches that fail for
+// want of a server are kept at the head of the queue and tried again
+// later; once it is closed, Ship stops at the first batch that cannot be
+// sent, leaving it and those after it queued for the next run.
+func shipQueued(ctx context.Context, sender Sender, in <-chan Item, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s := &queuedShipment{
+		queue:    opts.Queue,
+		opts:     opts,
+		cancel:   cancel,
+		written:  make(chan struct{}, 1),
+		writeErr: make(chan error, 1),
+		open:     true,
+		timer:    time.NewTimer(opts.FlushInterval),
+	}
+	s.timer.Stop()
+	defer s.timer.Stop()
+	go func() {
+		s.writeErr <- writeQueue(ctx, s.queue, in, opts, s.written)
+	}()
+
+	tried := 0 // Attempts made at the batch at the head of the queue
+	for {
+		if err := s.pollWriter(); err != nil {
+			return s.stop(err)
+		}
+		batch, err := s.queue.Peek(opts.BatchSize)
+		if err != nil {
+			return s.stop(err)
+		}
+		ready, err := s.waitForBatch(ctx, len(batch))
+		if err != nil {
+			return s.stop(err)
+		}
+		if !ready {
+			continue
+		}
+		if len(batch) == 0 {
+			return s.stop(nil)
+		}
+
+		if err := sendBatch(ctx, sender, batch, opts, tried); err != nil {
+			if !s.open || !Retryable(err) {
+				return s.stop(fmt.Errorf("%w, %d entries remain queued", err, s.queue.Len()))
+			}
+			tried += opts.Retry.Attempts
+			if err := s.retryLater(ctx, err, tried); err != nil {
+				return s.stop(err)
+			}
+			continue
+		}
+		tried = 0
+		if err := s.queue.Ack(); err != nil {
+			return s.stop(err)
+		}
+	}
+}
+
+// queuedShipment is the state of shipQueued: whether the writer of the
+// queue still runs, and the timer of a partial batch
+type queuedShipment struct {
+	queue    *Queue
+	opts     Options
+	cancel   context.CancelFunc
+	written  chan struct{}
+	writeErr chan error
+	open     bool // Whether the writer still runs, as in is open
+	timer    *time.Timer
+	waiting  bool // Whether the timer runs for a partial batch
+}
+
+// stop cancels the writer and waits for it to return, then returns err
+func (s *queuedShipment) stop(err error) error {
+	s.cancel()
+	if s.open {
+		<-s.writeErr
+	}
+	return err
+}
+
+// pollWriter notes whether the writer returned, without waiting for it,
+// and returns its error
+func (s *queuedShipment) pollWriter() error {
+	if !s.open {
+		return nil
+	}
+	select {
+	case err := <-s.writeErr:
+		s.open = false
+		return err
+	default:
+		return nil
+	}
+}
+
+// waitForBatch waits for more items while the writer runs and a batch of
+// n items is partial, up to FlushInterval once it holds any. It reports
+// whether the batch is to be sent as it is rather than peeked again.
+func (s *queuedShipment) waitForBatch(ctx context.Context, n int) (bool, error) {
+	if !s.open || n >= s.opts.BatchSize || (n > 0 && s.opts.FlushInterval <= 0) {
+		if s.waiting {
+			s.timer.Stop()
+			s.waiting = false
+		}
+		return true, nil
+	}
+	if n > 0 && !s.waiting {
+		s.timer.Reset(s.opts.FlushInterval)
+		s.waiting = true
+	}
+	var flush <-chan time.Time
+	if s.waiting {
+		flush = s.timer.C
+	}
+	select {
+	case <-s.written:
+		return false, nil
+	case err := <-s.writeErr:
+		s.open = false
+		return false, err
+	case <-flush:
+		s.waiting = false
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// retryLater waits before the batch at the head of the queue is tried
+// again, after tried attempts at it
+func (s *queuedShipment) retryLater(ctx context.Context, err error, tried int) error {
+	if s.opts.OnRetry != nil {
+		s.opts.OnRetry(err, tried, s.opts.Retry.MaxBackoff)
+	}
+	select {
+	case <-time.After(s.opts.Retry.MaxBackoff):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeQueue appends the items received from in to the queue, writing
+// those ready together and signalling written after each append
+func writeQueue(ctx context.Context, queue *Queue, in <-chan Item, opts Options, written chan<- struct{}) error {
+	for {
+		var items []Item
+		select {
+		case item, ok := <-in:
+			if !ok {
+				return nil
+			}
+			items = append(items, item)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		closed := false
+	ready:
+		for len(items) < max(opts.BatchSize, 1) {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					closed = true
+					break ready
+				}
+				items = append(items, item)
+			default:
+				break ready
+			}
+		}
+
+		if err := queue.Append(items); err != nil {
+			return err
+		}
+		if opts.OnQueued != nil {
+			opts.OnQueued(len(items))
+		}
+		select {
+		case written <- struct{}{}:
+		default:
+		}
+		if closed {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package shipper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/api/events"
+	v0 "github.com/jgfranco17/echoris/api/router/v0"
+	"github.com/jgfranco17/echoris/cli/client"
+	"github.com/jgfranco17/echoris/cli/shipper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSender records batches, failing the first failures sends with err
+type fakeSender struct {
+	mu       sync.Mutex
+	batches  [][]events.Entry
+	failures int
+	err      error
+}
+
+func (s *fakeSender) ForwardLogs(ctx context.Context, batch []events.Entry) (v0.IngestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return v0.IngestResult{}, s.err
+	}
+	s.batches = append(s.batches, batch)
+	return v0.IngestResult{Accepted: int64(len(batch))}, nil
+}
+
+func (s *fakeSender) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, batch := range s.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+var fastRetry = shipper.RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func items(n int) <-chan shipper.Item {
+	in := make(chan shipper.Item, n)
+	for i := 0; i < n; i++ {
+		in <- shipper.Item{Entry: events.Entry{Message: "m"}}
+	}
+	close(in)
+	return in
+}
+
+func TestShipBatches(t *testing.T) {
+	sender := &fakeSender{}
+	var reports []shipper.Report
+	err := shipper.Ship(context.Background(), sender, items(5), shipper.Options{
+		BatchSize: 2,
+		Retry:     fastRetry,
+		OnReport:  func(r shipper.Report) { reports = append(reports, r) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, sender.sizes())
+	require.Len(t, reports, 3)
+	assert.Equal(t, int64(1), reports[2].Result.Accepted)
+}
+
+func TestShipFlushesPartialBatches(t *testing.T) {
+	sender := &fakeSender{}
+	in := make(chan shipper.Item)
+	done := make(chan error)
+	go func() {
+		done <- shipper.Ship(context.Background(), sender, in, shipper.Options{
+			BatchSize:     10,
+			FlushInterval: 10 * time.Millisecond,
+			Retry:         fastRetry,
+		})
+	}()
+
+	in <- shipper.Item{}
+	assert.Eventually(t, func() bool { return len(sender.sizes()) == 1 }, time.Second, 5*time.Millisecond)
+	close(in)
+	require.NoError(t, <-done)
+	assert.Equal(t, []int{1}, sender.sizes())
+}
+
+func TestShipRetries(t *testing.T) {
+	t.Run("until the send succeeds", func(t *testing.T) {
+		sender := &fakeSender{failures: 2, err: &client.StatusError{Code: http.StatusBadGateway}}
+		var retries []int
+		err := shipper.Ship(context.Background(), sender, items(1), shipper.Options{
+			BatchSize: 1,
+			Retry:     fastRetry,
+			OnRetry:   func(err error, attempt int, wait time.Duration) { retries = append(retries, attempt) },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, retries)
+		assert.Equal(t, []int{1}, sender.sizes())
+	})
+
+	t.Run("giving up after the last attempt", func(t *testing.T) {
+		sender := &fakeSender{failures: 3, err: status.Error(codes.Unavailable, "worker down")}
+		err := shipper.Ship(context.Background(), sender, items(1), shipper.Options{BatchSize: 1, Retry: fastRetry})
+		assert.ErrorContains(t, err, "worker down")
+		assert.Empty(t, sender.sizes())
+	})
+
+	t.Run("without limit when attempts are zero", func(t *testing.T) {
+		sender := &fakeSender{failures: 10, err: errors.New("connection refused")}
+		err := shipper.Ship(context.Background(), sender, items(1), shipper.Options{
+			BatchSize: 1,
+			Retry:     shipper.RetryPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, sender.sizes())
+	})
+
+	t.Run("not when the request is rejected", func(t *testing.T) {
+		sender := &fakeSender{failures: 1, err: &client.StatusError{Code: http.StatusBadRequest, Message: "invalid JSON body"}}
+		err := shipper.Ship(context.Background(), sender, items(1), shipper.Options{BatchSize: 1, Retry: fastRetry})
+		assert.ErrorContains(t, err, "invalid JSON body")
+		assert.Equal(t, 0, sender.failures)
+	})
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	assert.NoError(t, shipper.DefaultRetryPolicy.Validate())
+	assert.EqualError(t, shipper.RetryPolicy{Backoff: time.Second}.Validate(), "retry backoff must be positive, got 1s up to 0s")
+}
+
+func TestRetryable(t *testing.T) {
+	cases := map[error]bool{
+		errors.New("connection refused"):                         true,
+		&client.StatusError{Code: http.StatusServiceUnavailable}: true,
+		&client.StatusError{Code: http.StatusTooManyRequests}:    true,
+		&client.StatusError{Code: http.StatusNotFound}:           false,
+		status.Error(codes.Unavailable, "down"):                  true,
+		status.Error(codes.InvalidArgument, "bad"):               false,
+		context.Canceled: false,
+	}
+	for err, expected := range cases {
+		assert.Equal(t, expected, shipper.Retryable(err), err.Error())
+	}
+}
@@ -15,6 +15,11 @@ func PrintColoredMessage(textColor string, message string, args ...any) {
 }
 
 func PrintColoredMessageTo(w io.Writer, textColor string, message string, args ...any) {
+	fmt.Fprintf(w, "%s\n", Colorize(textColor, fmt.Sprintf(message, args...)))
+}
+
+// Colorize returns text in one of the colors PrintColoredMessage accepts
+func Colorize(textColor string, text string) string {
 	var selectedColor color.Attribute
 	switch strings.ToLower(textColor) {
 	case "green":
@@ -30,9 +35,12 @@ func PrintColoredMessageTo(w io.Writer, textColor string, message string, args .
 	default:
 		selectedColor = color.FgWhite
 	}
-	colorFunc := color.New(selectedColor).SprintFunc()
-	fullMessage := fmt.Sprintf(message, args...)
-	fmt.Fprintf(w, "%s\n", colorFunc(fullMessage))
+	return color.New(selectedColor).Sprint(text)
+}
+
+// Highlight returns text in reverse video, to stand out within a line
+func Highlight(text string) string {
+	return color.New(color.ReverseVideo, color.Bold).Sprint(text)
 }
 
 func PrintTerminalWideLine(char string) {
@@ -51,3 +59,18 @@ func PrintTerminalWideLineTo(w io.Writer, char string) {
 	}
 	fmt.Fprintln(w, line)
 }
+
+// LevelColor returns the color that messages of a log level are printed in
+func LevelColor(level string) string {
+	switch strings.ToLower(level) {
+	case "error", "fatal", "panic", "critical":
+		return "red"
+	case "warn", "warning":
+		return "yellow"
+	case "info":
+		return "green"
+	case "debug", "trace":
+		return "blue"
+	}
+	return "white"
+}
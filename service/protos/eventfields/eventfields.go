@@ -0,0 +1,56 @@
+// Package eventfields converts the fields of a log to and from the typed
+// attributes and the legacy string map of a LogEvent, shared by the API's
+// client and the worker.
+package eventfields
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Set fills in the attributes of event from fields, and the legacy string
+// map for peers that predate attributes. Values other than strings are
+// written to the legacy map as JSON.
+func Set(event *pb.LogEvent, fields map[string]any) error {
+	if fields == nil {
+		return nil
+	}
+	attributes, err := structpb.NewStruct(fields)
+	if err != nil {
+		return fmt.Errorf("failed to convert fields to attributes: %w", err)
+	}
+	legacy := make(map[string]string, len(fields))
+	for key, value := range fields {
+		if text, ok := value.(string); ok {
+			legacy[key] = text
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode field %q: %w", key, err)
+		}
+		legacy[key] = string(encoded)
+	}
+	event.Attributes = attributes
+	event.Fields = legacy
+	return nil
+}
+
+// Get returns the fields of event, reading the legacy string map sent by
+// older peers when attributes is unset
+func Get(event *pb.LogEvent) map[string]any {
+	if event.GetAttributes() != nil {
+		return event.GetAttributes().AsMap()
+	}
+	if event.GetFields() == nil {
+		return nil
+	}
+	fields := make(map[string]any, len(event.GetFields()))
+	for key, value := range event.GetFields() {
+		fields[key] = value
+	}
+	return fields
+}
@@ -0,0 +1,52 @@
+package eventfields_test
+
+import (
+	"math"
+	"testing"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/protos/eventfields"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSet(t *testing.T) {
+	t.Run("fills in attributes and legacy fields", func(t *testing.T) {
+		event := &pb.LogEvent{}
+		require.NoError(t, eventfields.Set(event, map[string]any{
+			"user": "alice", "latency_ms": 812.5, "request": map[string]any{"method": "GET"},
+		}))
+		assert.Equal(t, map[string]any{"user": "alice", "latency_ms": 812.5, "request": map[string]any{"method": "GET"}}, event.Attributes.AsMap())
+		assert.Equal(t, map[string]string{"user": "alice", "latency_ms": "812.5", "request": `{"method":"GET"}`}, event.Fields)
+	})
+
+	t.Run("leaves events without fields alone", func(t *testing.T) {
+		event := &pb.LogEvent{}
+		require.NoError(t, eventfields.Set(event, nil))
+		assert.Nil(t, event.Attributes)
+		assert.Nil(t, event.Fields)
+	})
+
+	t.Run("reports values JSON cannot represent", func(t *testing.T) {
+		event := &pb.LogEvent{}
+		err := eventfields.Set(event, map[string]any{"ratio": math.NaN()})
+		assert.ErrorContains(t, err, `failed to encode field "ratio"`)
+		assert.Nil(t, event.Attributes)
+
+		err = eventfields.Set(event, map[string]any{"done": make(chan struct{})})
+		assert.ErrorContains(t, err, "failed to convert fields to attributes")
+	})
+}
+
+func TestGet(t *testing.T) {
+	attributes, err := structpb.NewStruct(map[string]any{"latency_ms": 812.5, "cached": true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"latency_ms": 812.5, "cached": true}, eventfields.Get(&pb.LogEvent{
+		Fields:     map[string]string{"latency_ms": "812.5", "cached": "true"},
+		Attributes: attributes,
+	}), "attributes are read before legacy fields")
+
+	assert.Equal(t, map[string]any{"user": "alice"}, eventfields.Get(&pb.LogEvent{Fields: map[string]string{"user": "alice"}}))
+	assert.Nil(t, eventfields.Get(&pb.LogEvent{}))
+}
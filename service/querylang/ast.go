@@ -0,0 +1,103 @@
+// Package querylang parses the log query language, in which terms such as
+// service:api, level:(error OR warn), fields.user:"alice" and
+// message~"timeout" are combined with AND, OR, NOT and parentheses.
+package querylang
+
+import (
+	"strings"
+)
+
+// Keys that terms may test. A field is tested by KeyFieldPrefix followed
+// by its key.
+const (
+	KeyService     = "service"
+	KeyLevel       = "level"
+	KeyMessage     = "message"
+	KeyFieldPrefix = "fields."
+)
+
+// Op is the operator of a term
+type Op string
+
+const (
+	OpEquals         Op = "eq"      // key:value, key has the value
+	OpPrefix         Op = "prefix"  // key:value*, key is a string starting with the value
+	OpExists         Op = "exists"  // fields.key:*, field is present
+	OpMatches        Op = "matches" // message~value, message contains the words of the value
+	OpGreater        Op = "gt"      // fields.key>value, field is a number greater than the value
+	OpGreaterOrEqual Op = "gte"     // fields.key>=value
+	OpLess           Op = "lt"      // fields.key<value
+	OpLessOrEqual    Op = "lte"     // fields.key<=value
+)
+
+// symbols are the operators as written in queries, besides the wildcards
+var symbols = map[Op]string{
+	OpEquals:         ":",
+	OpMatches:        "~",
+	OpGreater:        ">",
+	OpGreaterOrEqual: ">=",
+	OpLess:           "<",
+	OpLessOrEqual:    "<=",
+}
+
+// Expr is a node of a parsed query: an *And, *Or, *Not or *Term
+type Expr interface {
+	// Pos is the 1-based column where the expression starts
+	Pos() int
+	// String writes the expression back in the query language, with
+	// every operation parenthesized
+	String() string
+}
+
+// And holds when both of its operands hold
+type And struct {
+	Left, Right Expr
+}
+
+// Or holds when either of its operands holds
+type Or struct {
+	Left, Right Expr
+}
+
+// Not holds when its operand does not
+type Not struct {
+	Expr  Expr
+	Start int
+}
+
+// Term is a condition on a single key
+type Term struct {
+	Key   string // KeyService, KeyLevel, KeyMessage or KeyFieldPrefix and a key
+	Op    Op
+	Value string // Empty for OpExists
+	Start int
+}
+
+func (e *And) Pos() int  { return e.Left.Pos() }
+func (e *Or) Pos() int   { return e.Left.Pos() }
+func (e *Not) Pos() int  { return e.Start }
+func (e *Term) Pos() int { return e.Start }
+
+func (e *And) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e *Or) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e *Not) String() string { return "NOT " + e.Expr.String() }
+
+func (e *Term) String() string {
+	switch e.Op {
+	case OpExists:
+		return e.Key + ":*"
+	case OpPrefix:
+		return e.Key + ":" + quote(e.Value) + "*"
+	}
+	return e.Key + symbols[e.Op] + quote(e.Value)
+}
+
+// Field returns the key of the field the term tests, if it tests one
+func (e *Term) Field() (string, bool) {
+	return strings.CutPrefix(e.Key, KeyFieldPrefix)
+}
+
+// quote writes value as a quoted string
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,164 @@
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the lexical class of a token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+	tokOp
+	tokEquals    // "=", which is not an operator of the language
+	tokNotEquals // "!=", which is not an operator of the language
+)
+
+// token is a lexeme and the 1-based column where it starts
+type token struct {
+	kind     tokenKind
+	text     string // Unquoted text of words and strings, or the operator
+	pos      int
+	wildcard bool // A word or string directly followed by *
+}
+
+// describe names the token in syntax errors
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return quote(t.text)
+	}
+	return t.text
+}
+
+// keywords are the words with a meaning of their own. They must be
+// written in upper case; quote them to use them as values.
+var keywords = map[string]tokenKind{
+	"AND": tokAnd,
+	"OR":  tokOr,
+	"NOT": tokNot,
+}
+
+// lexer splits a query into tokens on demand. Keys end at an operator,
+// while values run to the next space or parenthesis, so the parser tells
+// the lexer which of the two it expects.
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: []rune(input)}
+}
+
+// next returns the next token, reading a bare word as a value when value
+// is set and as a key otherwise
+func (l *lexer) next(value bool) (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if start == len(l.input) {
+		return token{kind: tokEOF, pos: start + 1}, nil
+	}
+
+	switch c := l.input[start]; {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start + 1}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start + 1}, nil
+	case c == '"':
+		return l.quoted()
+	case !value && strings.ContainsRune(":~<>=!", c):
+		return l.operator(), nil
+	}
+	return l.word(value), nil
+}
+
+// operator reads an operator in key position
+func (l *lexer) operator() token {
+	start := l.pos
+	text := string(l.input[start])
+	if strings.ContainsRune("<>!", l.input[start]) && l.pos+1 < len(l.input) && l.input[l.pos+1] == '=' {
+		text += "="
+	}
+	l.pos += len(text)
+
+	kind := tokOp
+	switch text {
+	case "=":
+		kind = tokEquals
+	case "!", "!=":
+		kind = tokNotEquals
+	}
+	return token{kind: kind, text: text, pos: start + 1}
+}
+
+// word reads a bare word. Keys stop at an operator; values only stop at
+// a space, a parenthesis or a quote. A trailing * is a wildcard.
+func (l *lexer) word(value bool) token {
+	start := l.pos
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if unicode.IsSpace(c) || strings.ContainsRune(`()"`, c) || (!value && strings.ContainsRune(":~<>=!", c)) {
+			break
+		}
+		l.pos++
+	}
+
+	tok := token{kind: tokWord, text: string(l.input[start:l.pos]), pos: start + 1}
+	if kind, ok := keywords[tok.text]; ok {
+		tok.kind = kind
+		return tok
+	}
+	if value && strings.HasSuffix(tok.text, "*") {
+		tok.text = strings.TrimSuffix(tok.text, "*")
+		tok.wildcard = true
+	}
+	return tok
+}
+
+// quoted reads a double-quoted string, in which \" and \\ stand for a
+// quote and a backslash. A * right after the closing quote is a wildcard.
+func (l *lexer) quoted() (token, error) {
+	start := l.pos
+	var text strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		switch c := l.input[l.pos]; c {
+		case '"':
+			l.pos++
+			tok := token{kind: tokString, text: text.String(), pos: start + 1}
+			if l.pos < len(l.input) && l.input[l.pos] == '*' {
+				l.pos++
+				tok.wildcard = true
+			}
+			return tok, nil
+		case '\\':
+			l.pos++
+			if l.pos == len(l.input) {
+				break
+			}
+			if escaped := l.input[l.pos]; escaped == '"' || escaped == '\\' {
+				text.WriteRune(escaped)
+				continue
+			}
+			return token{}, &SyntaxError{Pos: l.pos, Msg: fmt.Sprintf(`unknown escape \%c, expected \" or \\`, l.input[l.pos])}
+		default:
+			text.WriteRune(c)
+		}
+	}
+	return token{}, &SyntaxError{Pos: start + 1, Msg: "unterminated quoted value"}
+}
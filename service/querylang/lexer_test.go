@@ -0,0 +1,34 @@
+package querylang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLexer(t *testing.T) {
+	// Values are read as values after an operator, as the parser does
+	l := newLexer(`fields.url:a:b>=c (NOT "x\"y"*) fields.n>=5`)
+	expected := []struct {
+		value bool
+		token token
+	}{
+		{false, token{kind: tokWord, text: "fields.url", pos: 1}},
+		{false, token{kind: tokOp, text: ":", pos: 11}},
+		{true, token{kind: tokWord, text: "a:b>=c", pos: 12}},
+		{false, token{kind: tokLParen, text: "(", pos: 19}},
+		{false, token{kind: tokNot, text: "NOT", pos: 20}},
+		{true, token{kind: tokString, text: `x"y`, pos: 24, wildcard: true}},
+		{false, token{kind: tokRParen, text: ")", pos: 31}},
+		{false, token{kind: tokWord, text: "fields.n", pos: 33}},
+		{false, token{kind: tokOp, text: ">=", pos: 41}},
+		{true, token{kind: tokWord, text: "5", pos: 43}},
+		{false, token{kind: tokEOF, pos: 44}},
+	}
+	for i, e := range expected {
+		tok, err := l.next(e.value)
+		require.NoError(t, err, i)
+		assert.Equal(t, e.token, tok, i)
+	}
+}
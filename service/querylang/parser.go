@@ -0,0 +1,281 @@
+package querylang
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDepth caps how deeply parentheses and NOT may nest
+const MaxDepth = 32
+
+// SyntaxError reports where and why a query could not be parsed
+type SyntaxError struct {
+	Pos int // 1-based column of the offending input
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at column %d: %s", e.Pos, e.Msg)
+}
+
+// operators maps the operators written in queries to term operators
+var operators = map[string]Op{
+	":":  OpEquals,
+	"~":  OpMatches,
+	">":  OpGreater,
+	">=": OpGreaterOrEqual,
+	"<":  OpLess,
+	"<=": OpLessOrEqual,
+}
+
+// Parse parses a query. Its grammar is
+//
+//	query = and { "OR" and }
+//	and   = unary { [ "AND" ] unary }
+//	unary = "NOT" unary | "(" query ")" | term
+//	term  = key op value | key op "(" value { "OR" value } ")"
+//
+// so AND binds tighter than OR, and adjacent terms are joined by AND.
+func Parse(query string) (Expr, error) {
+	p := &parser{lex: newLexer(query)}
+	if err := p.advance(false); err != nil {
+		return nil, err
+	}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok.describe())
+	}
+	return expr, nil
+}
+
+// parser is a recursive descent parser over one token of lookahead
+type parser struct {
+	lex   *lexer
+	tok   token
+	depth int
+}
+
+// advance reads the next token, as a value when value is set
+func (p *parser) advance(value bool) error {
+	tok, err := p.lex.next(value)
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// errorf reports a syntax error at the current token
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.tok.kind {
+		case tokAnd:
+			if err := p.advance(false); err != nil {
+				return nil, err
+			}
+		case tokWord, tokNot, tokLParen:
+		default:
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.depth++; p.depth > MaxDepth {
+		return nil, p.errorf("query nests deeper than %d levels", MaxDepth)
+	}
+	defer func() { p.depth-- }()
+
+	switch start := p.tok.pos; p.tok.kind {
+	case tokNot:
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr, Start: start}, nil
+	case tokLParen:
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ) to close the ( at column %d, found %s", start, p.tok.describe())
+		}
+		return expr, p.advance(false)
+	case tokWord:
+		return p.term()
+	}
+	return nil, p.errorf("expected a term, found %s", p.tok.describe())
+}
+
+// term parses a condition on a key, expanding a group of values into
+// terms joined by OR
+func (p *parser) term() (Expr, error) {
+	key := p.tok
+	if err := p.advance(false); err != nil {
+		return nil, err
+	}
+	op, err := p.operator(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.advance(true); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokLParen {
+		term, err := p.value(key, op)
+		if err != nil {
+			return nil, err
+		}
+		return term, p.advance(false)
+	}
+
+	open := p.tok.pos
+	var expr Expr
+	for {
+		if err := p.advance(true); err != nil {
+			return nil, err
+		}
+		term, err := p.value(key, op)
+		if err != nil {
+			return nil, err
+		}
+		if expr == nil {
+			expr = term
+		} else {
+			expr = &Or{Left: expr, Right: term}
+		}
+
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		switch p.tok.kind {
+		case tokOr:
+			continue
+		case tokRParen:
+			return expr, p.advance(false)
+		}
+		return nil, p.errorf("expected OR or ) to close the ( at column %d, found %s", open, p.tok.describe())
+	}
+}
+
+// operator checks the key of a term and reads its operator
+func (p *parser) operator(key token) (token, error) {
+	op := p.tok
+	switch op.kind {
+	case tokOp:
+	case tokEquals:
+		return op, p.errorf(`use ":" rather than "=" to match a value`)
+	case tokNotEquals:
+		return op, p.errorf("use NOT %s:value rather than %s to exclude a value", key.text, op.text)
+	default:
+		if !validKey(key.text) {
+			return op, &SyntaxError{Pos: key.pos, Msg: fmt.Sprintf(`expected a term such as service:api, found %s; use message~"%s" to search messages`, key.text, key.text)}
+		}
+		return op, p.errorf("expected an operator such as : after %s, found %s", key.text, op.describe())
+	}
+
+	if !validKey(key.text) {
+		return op, &SyntaxError{Pos: key.pos, Msg: fmt.Sprintf("unknown key %q, expected service, level, message or fields.<key>", key.text)}
+	}
+	return op, nil
+}
+
+func validKey(key string) bool {
+	switch key {
+	case KeyService, KeyLevel, KeyMessage:
+		return true
+	}
+	field, ok := strings.CutPrefix(key, KeyFieldPrefix)
+	return ok && field != ""
+}
+
+// value builds the term testing key with op against the current token
+func (p *parser) value(key, op token) (*Term, error) {
+	if p.tok.kind != tokWord && p.tok.kind != tokString {
+		return nil, p.errorf("expected a value after %s%s, found %s", key.text, op.text, p.tok.describe())
+	}
+	term := &Term{Key: key.text, Op: operators[op.text], Value: p.tok.text, Start: key.pos}
+	_, isField := term.Field()
+
+	if p.tok.wildcard {
+		if term.Op != OpEquals {
+			return nil, p.errorf("wildcards only apply to :")
+		}
+		term.Op = OpPrefix
+		if p.tok.kind == tokWord && term.Value == "" {
+			term.Op = OpExists
+		}
+	}
+
+	switch term.Op {
+	case OpExists:
+		if !isField {
+			return nil, p.errorf("only fields can be tested for presence")
+		}
+	case OpMatches:
+		if term.Key != KeyMessage {
+			return nil, &SyntaxError{Pos: op.pos, Msg: "~ only applies to message"}
+		}
+		if strings.TrimSpace(term.Value) == "" {
+			return nil, p.errorf("expected words to search for after message~")
+		}
+	case OpGreater, OpGreaterOrEqual, OpLess, OpLessOrEqual:
+		if !isField {
+			return nil, &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("only fields can be compared with %s", op.text)}
+		}
+		if !isNumber(term.Value) {
+			return nil, p.errorf("expected a number after %s%s, found %s", key.text, op.text, p.tok.describe())
+		}
+	}
+	return term, nil
+}
+
+// isNumber reports whether text is a finite decimal number
+func isNumber(text string) bool {
+	number, err := strconv.ParseFloat(text, 64)
+	return err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) && !strings.ContainsAny(text, "xX_")
+}
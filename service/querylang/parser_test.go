@@ -0,0 +1,103 @@
+package querylang_test
+
+import (
+	"testing"
+
+	"github.com/jgfranco17/echoris/service/querylang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		`service:api`: `service:"api"`,
+		`service:api AND level:(error OR warn) AND fields.user:"alice" AND message~"timeout"`: `(((service:"api" AND (level:"error" OR level:"warn")) AND fields.user:"alice") AND message~"timeout")`,
+		`service:api level:error`:                     `(service:"api" AND level:"error")`,
+		`service:api OR service:db AND level:error`:   `(service:"api" OR (service:"db" AND level:"error"))`,
+		`(service:api OR service:db) AND level:error`: `((service:"api" OR service:"db") AND level:"error")`,
+		`NOT level:debug`:                             `NOT level:"debug"`,
+		`NOT (level:debug OR level:info)`:             `NOT (level:"debug" OR level:"info")`,
+		`fields.path:/v0/*`:                           `fields.path:"/v0/"*`,
+		`fields.note:"a b"*`:                          `fields.note:"a b"*`,
+		`fields.note:"5*"`:                            `fields.note:"5*"`,
+		`fields.trace:*`:                              `fields.trace:*`,
+		`fields.url:http://example.com/a?b=c`:         `fields.url:"http://example.com/a?b=c"`,
+		`fields.latency_ms>500`:                       `fields.latency_ms>"500"`,
+		`fields.latency_ms >= -1.5e2`:                 `fields.latency_ms>="-1.5e2"`,
+		`fields.ratio<0.5 fields.ratio<=1`:            `(fields.ratio<"0.5" AND fields.ratio<="1")`,
+		`message:"said \"hi\" \\o/"`:                  `message:"said \"hi\" \\o/"`,
+		`level:"AND"`:                                 `level:"AND"`,
+		`service:"" `:                                 `service:""`,
+		`  message ~ "connection refused"  `:          `message~"connection refused"`,
+		`fields.region:(eu OR us-*)`:                  `(fields.region:"eu" OR fields.region:"us-"*)`,
+		`service:ünïcode`:                             `service:"ünïcode"`,
+	}
+	for query, expected := range cases {
+		expr, err := querylang.Parse(query)
+		require.NoError(t, err, query)
+		assert.Equal(t, expected, expr.String(), query)
+	}
+}
+
+func TestParseTerm(t *testing.T) {
+	expr, err := querylang.Parse(`level:warn AND fields.user:al*`)
+	require.NoError(t, err)
+
+	and, ok := expr.(*querylang.And)
+	require.True(t, ok)
+	assert.Equal(t, &querylang.Term{Key: "level", Op: querylang.OpEquals, Value: "warn", Start: 1}, and.Left)
+	assert.Equal(t, &querylang.Term{Key: "fields.user", Op: querylang.OpPrefix, Value: "al", Start: 16}, and.Right)
+	assert.Equal(t, 1, and.Pos())
+
+	key, ok := and.Right.(*querylang.Term).Field()
+	assert.True(t, ok)
+	assert.Equal(t, "user", key)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		``:                              "syntax error at column 1: expected a term, found end of query",
+		`service:api AND`:               "syntax error at column 16: expected a term, found end of query",
+		`AND service:api`:               "syntax error at column 1: expected a term, found AND",
+		`service:api)`:                  "syntax error at column 12: unexpected )",
+		`(service:api`:                  "syntax error at column 13: expected ) to close the ( at column 1, found end of query",
+		`level:(error warn)`:            "syntax error at column 14: expected OR or ) to close the ( at column 7, found warn",
+		`level:(error OR`:               "syntax error at column 16: expected a value after level:, found end of query",
+		`service:`:                      "syntax error at column 9: expected a value after service:, found end of query",
+		`host:web-1`:                    `syntax error at column 1: unknown key "host", expected service, level, message or fields.<key>`,
+		`fields.:x`:                     `syntax error at column 1: unknown key "fields.", expected service, level, message or fields.<key>`,
+		`timeout`:                       `syntax error at column 1: expected a term such as service:api, found timeout; use message~"timeout" to search messages`,
+		`service api`:                   "syntax error at column 9: expected an operator such as : after service, found api",
+		`service=api`:                   `syntax error at column 8: use ":" rather than "=" to match a value`,
+		`level!=debug`:                  "syntax error at column 6: use NOT level:value rather than != to exclude a value",
+		`service:"api`:                  "syntax error at column 9: unterminated quoted value",
+		`message:"a\tb"`:                `syntax error at column 11: unknown escape \t, expected \" or \\`,
+		`service:*`:                     "syntax error at column 9: only fields can be tested for presence",
+		`level~error`:                   "syntax error at column 6: ~ only applies to message",
+		`message~""`:                    "syntax error at column 9: expected words to search for after message~",
+		`message~time*`:                 "syntax error at column 9: wildcards only apply to :",
+		`level>3`:                       "syntax error at column 6: only fields can be compared with >",
+		`fields.latency_ms>fast`:        "syntax error at column 19: expected a number after fields.latency_ms>, found fast",
+		`fields.latency_ms<=0x10`:       "syntax error at column 20: expected a number after fields.latency_ms<=, found 0x10",
+		`message~"a" OR`:                "syntax error at column 15: expected a term, found end of query",
+		`service:api NOT`:               "syntax error at column 16: expected a term, found end of query",
+		`service:api OR OR level:error`: "syntax error at column 16: expected a term, found OR",
+	}
+	for query, expected := range cases {
+		_, err := querylang.Parse(query)
+		require.Error(t, err, query)
+		assert.Equal(t, expected, err.Error(), query)
+
+		var syntaxErr *querylang.SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, query)
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	query := ""
+	for range querylang.MaxDepth {
+		query += "NOT "
+	}
+	_, err := querylang.Parse(query + "level:debug")
+	assert.EqualError(t, err, "syntax error at column 129: query nests deeper than 32 levels")
+}
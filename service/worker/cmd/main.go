@@ -2,26 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/retention"
 	"github.com/jgfranco17/echoris/service/worker/server"
 	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/tail"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
 func main() {
 	port := flag.Int("port", 50051, "The server port")
-	connString := flag.String("conn", "", "PostgreSQL connection string")
+	storageURL := flag.String("storage", "", "Storage URL (postgres://..., memory://, file://<dir>), or memory, file or postgres")
+	connString := flag.String("conn", "", "PostgreSQL connection string (deprecated, use -storage)")
+	dataDir := flag.String("data-dir", "data", "Directory of -storage file")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	useEnv := flag.Bool("use-env", false, "Use environment variables for storage configuration")
+	migrate := flag.String("migrate", "", "Run a PostgreSQL schema migration command and exit (up, down, status)")
+	metricsPort := flag.Int("metrics-port", 0, "Port serving Prometheus metrics on /metrics, such as 9090 (disabled when 0)")
+	retentionInterval := flag.Duration("retention-interval", time.Hour, "How often expired logs are deleted (0 disables retention)")
+	retentionMaxAge := flag.String("retention-max-age", "", "Default log retention, e.g. 30d or 720h (empty keeps logs indefinitely)")
+	retentionServices := flag.String("retention-services", "", "Per-service retention overrides, e.g. billing=365d,api=14d")
+	retentionLevels := flag.String("retention-levels", "", "Per-level retention overrides, e.g. error=90d,debug=3d")
+	chunkSize := flag.Int("stream-chunk-size", server.DefaultChunkSize, "Number of StreamLogs events committed to storage together")
+	flushInterval := flag.Duration("stream-flush-interval", server.DefaultFlushInterval, "Longest time a StreamLogs event waits before it is committed")
+	useEnv := flag.Bool("use-env", false, "Use the DATABASE_URL or POSTGRES_* environment variables for storage configuration")
 	flag.Parse()
 
 	// Configure logger
@@ -38,42 +55,36 @@ func main() {
 		"port": *port,
 	}).Info("Starting log aggregator service")
 
-	// Initialize database storage with dependency injection
-	var store storage.Storage
+	// Resolve the storage URL
 	if *useEnv {
-		// Load configuration from environment variables
 		logger.Info("Loading storage configuration from environment")
-		store, err = storage.NewStorageFromEnv()
-	} else {
-		// Use command-line arguments
-		conn := *connString
-		if conn == "" {
-			logger.Fatal("No PostgreSQL connection defined")
-		}
+	}
+	conn, err := storageConn(*storageURL, *connString, *dataDir, *useEnv)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid storage configuration")
+	}
 
-		config := storage.Config{
-			ConnString: conn,
+	if *migrate != "" {
+		if err := runMigrations(context.Background(), *migrate, conn, os.Stdout); err != nil {
+			logger.WithError(err).Fatal("Migration failed")
 		}
-
-		store, err = storage.NewStorage(config)
+		return
 	}
 
-	if err != nil {
-		logger.WithError(err).Fatal("Failed to initialize storage")
-	}
+	store := openStorage(conn, logger)
 	defer store.Close()
 
-	// Verify database connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := store.HealthCheck(ctx); err != nil {
-		logger.WithError(err).Fatal("Database health check failed")
+	// Start the retention scheduler
+	policy, err := retentionPolicy(*retentionMaxAge, *retentionServices, *retentionLevels)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid retention configuration")
 	}
-	logger.Info("Database connection established")
+	retentionManager := startRetention(store, policy, *retentionInterval, logger)
+	metricsServer := serveMetrics(*metricsPort, logger)
 
 	// Create gRPC server
 	grpcServer := grpc.NewServer()
-	logServer := server.NewLogAggregatorServer(store, logger)
+	logServer := server.NewLogAggregatorServer(store, logger).WithChunking(*chunkSize, *flushInterval)
 	pb.RegisterLogAggregatorServer(grpcServer, logServer)
 
 	// Start listening
@@ -97,9 +108,119 @@ func main() {
 	<-done
 	logger.Info("Shutting down server...")
 
-	// Graceful shutdown
+	// Graceful shutdown; open tails would otherwise hold GracefulStop forever
+	logServer.StopTailing()
 	grpcServer.GracefulStop()
+	if retentionManager != nil {
+		retentionManager.Stop()
+	}
+	if metricsServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		metricsServer.Shutdown(shutdownCtx)
+		shutdownCancel()
+	}
 	logServer.Close()
 
 	logger.Info("Server stopped")
 }
+
+// openStorage initializes the storage of conn and verifies its connection
+func openStorage(conn string, logger *logrus.Logger) storage.Storage {
+	store, err := storage.NewStorage(storage.Config{
+		ConnString: conn,
+	})
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize storage")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.HealthCheck(ctx); err != nil {
+		store.Close()
+		logger.WithError(err).Fatal("Storage health check failed")
+	}
+	logger.Info("Storage connection established")
+	return store
+}
+
+// startRetention starts the retention scheduler, or returns nil when the
+// policy has no rules or retention is disabled
+func startRetention(store storage.Storage, policy retention.Policy, interval time.Duration, logger *logrus.Logger) *retention.Manager {
+	if interval <= 0 || len(policy.Rules()) == 0 {
+		return nil
+	}
+	manager, err := retention.NewManager(store, policy, interval, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid retention configuration")
+	}
+	manager.Start()
+	logger.WithFields(logrus.Fields{
+		"interval": interval.String(),
+		"rules":    len(manager.Rules()),
+	}).Info("Retention scheduler started")
+	return manager
+}
+
+// serveMetrics serves Prometheus metrics on port, or returns nil when port
+// is 0
+func serveMetrics(port int, logger *logrus.Logger) *http.Server {
+	if port <= 0 {
+		return nil
+	}
+	prometheus.MustRegister(retention.Collectors()...)
+	prometheus.MustRegister(tail.Collectors()...)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Error("Metrics server failed")
+		}
+	}()
+	return metricsServer
+}
+
+// storageConn resolves the storage URL from the command-line flags. The
+// bare backend names of earlier releases map to their URLs: file to the
+// -data-dir directory and postgres to -conn.
+func storageConn(storageURL, connString, dataDir string, useEnv bool) (string, error) {
+	if useEnv {
+		return storage.ConnStringFromEnv(), nil
+	}
+	switch storageURL {
+	case "memory":
+		return "memory://", nil
+	case "file":
+		return "file:" + dataDir, nil
+	case "postgres", "postgresql", "":
+		if connString == "" {
+			return "", fmt.Errorf("no storage URL defined, set -storage or -conn")
+		}
+		return connString, nil
+	}
+	if !strings.Contains(storageURL, "://") && !strings.HasPrefix(storageURL, "file:") {
+		return "", fmt.Errorf("unknown storage %q, expected memory, file, postgres or a URL such as memory://, file://<dir> or postgres://<user>@<host>/<db>", storageURL)
+	}
+	return storageURL, nil
+}
+
+// retentionPolicy builds the retention policy from the command-line flags
+func retentionPolicy(maxAge, services, levels string) (retention.Policy, error) {
+	var policy retention.Policy
+	if maxAge != "" {
+		d, err := retention.ParseDuration(maxAge)
+		if err != nil {
+			return policy, fmt.Errorf("invalid -retention-max-age: %w", err)
+		}
+		policy.MaxAge = d
+	}
+
+	var err error
+	if policy.Services, err = retention.ParseOverrides(services); err != nil {
+		return policy, fmt.Errorf("invalid -retention-services: %w", err)
+	}
+	if policy.Levels, err = retention.ParseOverrides(levels); err != nil {
+		return policy, fmt.Errorf("invalid -retention-levels: %w", err)
+	}
+	return policy, policy.Validate()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageConn(t *testing.T) {
+	for _, tc := range []struct {
+		storage, conn, want string
+	}{
+		{storage: "memory", want: "memory://"},
+		{storage: "file", want: "file:data"},
+		{storage: "postgres", conn: "host=db dbname=logs", want: "host=db dbname=logs"},
+		{conn: "postgres://db/logs", want: "postgres://db/logs"},
+		{storage: "file:///var/lib/echoris", conn: "ignored", want: "file:///var/lib/echoris"},
+	} {
+		conn, err := storageConn(tc.storage, tc.conn, "data", false)
+		require.NoError(t, err, "storage %q", tc.storage)
+		assert.Equal(t, tc.want, conn)
+	}
+
+	_, err := storageConn("postgres", "", "data", false)
+	assert.EqualError(t, err, "no storage URL defined, set -storage or -conn")
+	_, err = storageConn("mongo", "", "data", false)
+	assert.ErrorContains(t, err, `unknown storage "mongo", expected memory, file, postgres or a URL`)
+}
@@ -0,0 +1,36 @@
+package retention
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	DeletedLogs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echoris_retention_deleted_logs_total",
+			Help: "Number of logs deleted by retention, by rule",
+		}, []string{"rule"},
+	)
+	Runs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "echoris_retention_runs_total",
+			Help: "Number of retention cycles, by result",
+		}, []string{"result"},
+	)
+	LastRunTime = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "echoris_retention_last_run_time",
+			Help: "Time when the last retention cycle finished",
+		},
+	)
+	RunDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "echoris_retention_run_duration_seconds",
+			Help:    "Duration of retention cycles",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+	)
+)
+
+// Collectors returns the retention metrics for registration
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{DeletedLogs, Runs, LastRunTime, RunDuration}
+}
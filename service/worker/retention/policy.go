@@ -0,0 +1,150 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+)
+
+// Policy describes how long logs are kept. A level override takes
+// precedence over a service override, which takes precedence over MaxAge.
+// A zero MaxAge keeps logs that no override covers indefinitely.
+type Policy struct {
+	MaxAge   time.Duration
+	Services map[string]time.Duration
+	Levels   map[string]time.Duration
+}
+
+// Rule is one deletion pass of a policy
+type Rule struct {
+	Name   string
+	Filter storage.DeleteFilter
+}
+
+// Validate checks that every configured age is positive
+func (p Policy) Validate() error {
+	if p.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative, got %s", p.MaxAge)
+	}
+	for service, age := range p.Services {
+		if age <= 0 {
+			return fmt.Errorf("retention for service %q must be positive, got %s", service, age)
+		}
+	}
+	for level, age := range p.Levels {
+		if age <= 0 {
+			return fmt.Errorf("retention for level %q must be positive, got %s", level, age)
+		}
+	}
+	return nil
+}
+
+// Rules expands the policy into non-overlapping deletion passes. When a
+// global max age is set, an unscoped pass for the longest configured age
+// runs first so backends can drop whole time ranges cheaply.
+func (p Policy) Rules() []Rule {
+	levels := sortedKeys(p.Levels)
+	services := sortedKeys(p.Services)
+
+	var rules []Rule
+	if p.MaxAge > 0 && len(levels)+len(services) > 0 {
+		rules = append(rules, Rule{Name: "expired", Filter: storage.DeleteFilter{OlderThan: p.longestAge()}})
+	}
+	for _, level := range levels {
+		rules = append(rules, Rule{
+			Name:   "level:" + level,
+			Filter: storage.DeleteFilter{OlderThan: p.Levels[level], Levels: []string{level}},
+		})
+	}
+	for _, service := range services {
+		rules = append(rules, Rule{
+			Name: "service:" + service,
+			Filter: storage.DeleteFilter{
+				OlderThan:     p.Services[service],
+				Services:      []string{service},
+				ExcludeLevels: levels,
+			},
+		})
+	}
+	if p.MaxAge > 0 {
+		rules = append(rules, Rule{
+			Name: "default",
+			Filter: storage.DeleteFilter{
+				OlderThan:       p.MaxAge,
+				ExcludeServices: services,
+				ExcludeLevels:   levels,
+			},
+		})
+	}
+	return rules
+}
+
+func (p Policy) longestAge() time.Duration {
+	longest := p.MaxAge
+	for _, age := range p.Services {
+		longest = max(longest, age)
+	}
+	for _, age := range p.Levels {
+		longest = max(longest, age)
+	}
+	return longest
+}
+
+func sortedKeys(m map[string]time.Duration) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseDuration parses a Go duration, additionally accepting a whole
+// number of days such as "90d"
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// ParseOverrides parses a comma separated list of name=duration pairs,
+// e.g. "error=90d,debug=3d"
+func ParseOverrides(value string) (map[string]time.Duration, error) {
+	overrides := make(map[string]time.Duration)
+	if strings.TrimSpace(value) == "" {
+		return overrides, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, age, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid override %q, expected name=duration", pair)
+		}
+		if _, exists := overrides[name]; exists {
+			return nil, fmt.Errorf("duplicate override for %q", name)
+		}
+		d, err := ParseDuration(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %q: %w", pair, err)
+		}
+		overrides[name] = d
+	}
+	return overrides, nil
+}
@@ -0,0 +1,86 @@
+package retention_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/retention"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const day = 24 * time.Hour
+
+func TestPolicy_Rules(t *testing.T) {
+	t.Run("global only", func(t *testing.T) {
+		rules := retention.Policy{MaxAge: 30 * day}.Rules()
+		assert.Equal(t, []retention.Rule{
+			{Name: "default", Filter: storage.DeleteFilter{OlderThan: 30 * day}},
+		}, rules)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		rules := retention.Policy{
+			MaxAge:   30 * day,
+			Services: map[string]time.Duration{"billing": 365 * day},
+			Levels:   map[string]time.Duration{"error": 90 * day, "debug": 3 * day},
+		}.Rules()
+
+		levels := []string{"debug", "error"}
+		assert.Equal(t, []retention.Rule{
+			{Name: "expired", Filter: storage.DeleteFilter{OlderThan: 365 * day}},
+			{Name: "level:debug", Filter: storage.DeleteFilter{OlderThan: 3 * day, Levels: []string{"debug"}}},
+			{Name: "level:error", Filter: storage.DeleteFilter{OlderThan: 90 * day, Levels: []string{"error"}}},
+			{Name: "service:billing", Filter: storage.DeleteFilter{OlderThan: 365 * day, Services: []string{"billing"}, ExcludeLevels: levels}},
+			{Name: "default", Filter: storage.DeleteFilter{OlderThan: 30 * day, ExcludeServices: []string{"billing"}, ExcludeLevels: levels}},
+		}, rules)
+	})
+
+	t.Run("no global max age keeps uncovered logs", func(t *testing.T) {
+		rules := retention.Policy{Levels: map[string]time.Duration{"debug": 3 * day}}.Rules()
+		assert.Equal(t, []retention.Rule{
+			{Name: "level:debug", Filter: storage.DeleteFilter{OlderThan: 3 * day, Levels: []string{"debug"}}},
+		}, rules)
+	})
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	assert.NoError(t, retention.Policy{}.Validate())
+	assert.Error(t, retention.Policy{MaxAge: -time.Hour}.Validate())
+	assert.Error(t, retention.Policy{Levels: map[string]time.Duration{"error": 0}}.Validate())
+	assert.Error(t, retention.Policy{Services: map[string]time.Duration{"api": -time.Hour}}.Validate())
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90d":   90 * day,
+		"72h":   72 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	}
+	for input, expected := range cases {
+		d, err := retention.ParseDuration(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, d, input)
+	}
+
+	for _, input := range []string{"", "d", "1.5d", "ten"} {
+		_, err := retention.ParseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := retention.ParseOverrides("error=90d, debug=3d")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"error": 90 * day, "debug": 3 * day}, overrides)
+
+	overrides, err = retention.ParseOverrides("")
+	require.NoError(t, err)
+	assert.Empty(t, overrides)
+
+	for _, input := range []string{"error", "=3d", "error=soon", "error=1d,error=2d"} {
+		_, err := retention.ParseOverrides(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,122 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Manager periodically deletes logs that have outlived their policy
+type Manager struct {
+	store    storage.Storage
+	rules    []Rule
+	interval time.Duration
+	logger   *logrus.Logger
+
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewManager creates a retention manager that applies policy to store
+// every interval
+func NewManager(store storage.Storage, policy Policy, interval time.Duration, logger *logrus.Logger) (*Manager, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("retention interval must be positive, got %s", interval)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		store:    store,
+		rules:    policy.Rules(),
+		interval: interval,
+		logger:   logger,
+	}, nil
+}
+
+// Rules returns the deletion passes run on every cycle
+func (m *Manager) Rules() []Rule {
+	return m.rules
+}
+
+// Start runs a retention cycle immediately and then once per interval
+// until Stop is called
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := m.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				m.logger.WithError(err).Error("Retention cycle failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels any running cycle and waits for the manager to exit
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		if m.cancel != nil {
+			m.cancel()
+		}
+		m.wg.Wait()
+	})
+}
+
+// RunOnce applies every rule once and returns the number of logs deleted.
+// A failing rule does not prevent the remaining rules from running.
+func (m *Manager) RunOnce(ctx context.Context) (int64, error) {
+	start := time.Now()
+
+	var total int64
+	var errs []error
+	for _, rule := range m.rules {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		deleted, err := m.store.DeleteLogs(ctx, rule.Filter)
+		total += deleted
+		DeletedLogs.WithLabelValues(rule.Name).Add(float64(deleted))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.Name, err))
+			continue
+		}
+		if deleted > 0 {
+			m.logger.WithFields(logrus.Fields{
+				"rule":    rule.Name,
+				"deleted": deleted,
+			}).Info("Deleted expired logs")
+		}
+	}
+
+	err := errors.Join(errs...)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	Runs.WithLabelValues(result).Inc()
+	LastRunTime.SetToCurrentTime()
+	RunDuration.Observe(time.Since(start).Seconds())
+
+	return total, err
+}
@@ -0,0 +1,116 @@
+package retention_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/retention"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/storage/storagetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func seedStore(t *testing.T) *storage.MemoryStorage {
+	t.Helper()
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+		{Timestamp: now, Service: "api", Level: "info", Message: "api-info"},
+		{Timestamp: now, Service: "api", Level: "error", Message: "api-error"},
+		{Timestamp: now, Service: "api", Level: "debug", Message: "api-debug"},
+		{Timestamp: now, Service: "billing", Level: "info", Message: "billing-info"},
+		{Timestamp: now, Service: "billing", Level: "debug", Message: "billing-debug"},
+	}))
+	time.Sleep(200 * time.Millisecond)
+	return store
+}
+
+func TestNewManager_Validation(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	_, err := retention.NewManager(store, retention.Policy{MaxAge: time.Hour}, 0, newLogger())
+	assert.Error(t, err)
+
+	_, err = retention.NewManager(store, retention.Policy{MaxAge: -time.Hour}, time.Minute, newLogger())
+	assert.Error(t, err)
+}
+
+func TestManager_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	store := seedStore(t)
+
+	manager, err := retention.NewManager(store, retention.Policy{
+		MaxAge:   100 * time.Millisecond,
+		Services: map[string]time.Duration{"billing": time.Hour},
+		Levels:   map[string]time.Duration{"error": time.Hour, "debug": 50 * time.Millisecond},
+	}, time.Minute, newLogger())
+	require.NoError(t, err)
+
+	debugBefore := testutil.ToFloat64(retention.DeletedLogs.WithLabelValues("level:debug"))
+	defaultBefore := testutil.ToFloat64(retention.DeletedLogs.WithLabelValues("default"))
+	successBefore := testutil.ToFloat64(retention.Runs.WithLabelValues("success"))
+
+	deleted, err := manager.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"api-error", "billing-info"}, storagetest.Messages(entries))
+
+	assert.Equal(t, debugBefore+2, testutil.ToFloat64(retention.DeletedLogs.WithLabelValues("level:debug")))
+	assert.Equal(t, defaultBefore+1, testutil.ToFloat64(retention.DeletedLogs.WithLabelValues("default")))
+	assert.Equal(t, successBefore+1, testutil.ToFloat64(retention.Runs.WithLabelValues("success")))
+}
+
+func TestManager_RunOnceReportsFailure(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.Close())
+
+	manager, err := retention.NewManager(store, retention.Policy{MaxAge: time.Hour}, time.Minute, newLogger())
+	require.NoError(t, err)
+
+	failuresBefore := testutil.ToFloat64(retention.Runs.WithLabelValues("failure"))
+	_, err = manager.RunOnce(context.Background())
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(retention.Runs.WithLabelValues("failure")))
+}
+
+func TestManager_StartStop(t *testing.T) {
+	store := seedStore(t)
+
+	manager, err := retention.NewManager(store, retention.Policy{MaxAge: 100 * time.Millisecond}, 20*time.Millisecond, newLogger())
+	require.NoError(t, err)
+
+	manager.Start()
+	assert.Eventually(t, func() bool {
+		entries, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		return err == nil && len(entries) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.Stop()
+		manager.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("manager did not stop")
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// DefaultAggregateLimit is the bucket limit of aggregations that do not set one
+	DefaultAggregateLimit = 1000
+
+	// MaxAggregateLimit caps the buckets an aggregation may request
+	MaxAggregateLimit = 10000
+)
+
+// AggregateLogs counts the logs matching the request's filter, grouped by
+// the requested dimensions and time buckets
+func (s *LogAggregatorServer) AggregateLogs(ctx context.Context, req *pb.AggregateRequest) (*pb.AggregateResponse, error) {
+	query, limit, err := aggregateQuery(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"service":  query.Filter.Service,
+		"level":    query.Filter.Level,
+		"group_by": query.GroupBy,
+		"interval": query.Interval.String(),
+	}).Info("Aggregating logs")
+
+	buckets, err := s.storage.AggregateLogs(ctx, query)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to aggregate logs")
+		return nil, err
+	}
+
+	resp := &pb.AggregateResponse{
+		Buckets:   make([]*pb.AggregateBucket, 0, min(len(buckets), limit)),
+		Truncated: len(buckets) > limit,
+	}
+	for _, bucket := range buckets[:min(len(buckets), limit)] {
+		converted := &pb.AggregateBucket{Group: bucket.Group, Count: bucket.Count}
+		if !bucket.Start.IsZero() {
+			converted.Start = timestamppb.New(bucket.Start)
+		}
+		resp.Buckets = append(resp.Buckets, converted)
+	}
+	return resp, nil
+}
+
+// aggregateQuery converts an aggregate request into a storage query and
+// the number of buckets to return. The query fetches one extra bucket to
+// detect truncation.
+func aggregateQuery(req *pb.AggregateRequest) (storage.AggregateQuery, int, error) {
+	filter := req.GetFilter()
+	if filter == nil {
+		filter = &pb.QueryRequest{}
+	}
+	if filter.PageToken != "" {
+		return storage.AggregateQuery{}, 0, fmt.Errorf("page tokens do not apply to an aggregation")
+	}
+	if filter.Rank {
+		return storage.AggregateQuery{}, 0, fmt.Errorf("relevance ranking does not apply to an aggregation")
+	}
+
+	query := storage.AggregateQuery{GroupBy: req.GroupBy}
+	var err error
+	if query.Filter, _, err = queryFilter(filter); err != nil {
+		return query, 0, err
+	}
+
+	if req.Interval != nil {
+		if err := req.Interval.CheckValid(); err != nil {
+			return query, 0, fmt.Errorf("invalid interval: %w", err)
+		}
+		query.Interval = req.Interval.AsDuration()
+		if query.Interval < time.Second {
+			return query, 0, fmt.Errorf("interval must be at least 1s, got %s", query.Interval)
+		}
+	}
+
+	limit := int(req.Limit)
+	switch {
+	case limit < 0:
+		return query, 0, fmt.Errorf("limit must not be negative, got %d", limit)
+	case limit == 0:
+		limit = DefaultAggregateLimit
+	case limit > MaxAggregateLimit:
+		limit = MaxAggregateLimit
+	}
+	query.Limit = limit + 1
+
+	if err := query.Validate(); err != nil {
+		return query, 0, err
+	}
+	return query, limit, nil
+}
@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestLogAggregatorServer_AggregateLogs(t *testing.T) {
+	minute := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	srv, store := newTestServer(t)
+	require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+		{Timestamp: minute.Add(5 * time.Second), Service: "api", Level: "error", Message: "a"},
+		{Timestamp: minute.Add(10 * time.Second), Service: "api", Level: "error", Message: "b"},
+		{Timestamp: minute.Add(15 * time.Second), Service: "db", Level: "error", Message: "c"},
+		{Timestamp: minute.Add(70 * time.Second), Service: "api", Level: "error", Message: "d"},
+		{Timestamp: minute.Add(75 * time.Second), Service: "api", Level: "info", Message: "e"},
+	}))
+
+	t.Run("errors per service per minute", func(t *testing.T) {
+		resp, err := srv.AggregateLogs(context.Background(), &pb.AggregateRequest{
+			Filter:   &pb.QueryRequest{Level: "error"},
+			GroupBy:  []string{"service"},
+			Interval: durationpb.New(time.Minute),
+		})
+		require.NoError(t, err)
+		assert.False(t, resp.Truncated)
+		require.Len(t, resp.Buckets, 3)
+
+		expected := []struct {
+			start   time.Time
+			service string
+			count   int64
+		}{
+			{minute, "api", 2},
+			{minute, "db", 1},
+			{minute.Add(time.Minute), "api", 1},
+		}
+		for i, bucket := range resp.Buckets {
+			assert.True(t, expected[i].start.Equal(bucket.Start.AsTime()), "bucket %d", i)
+			assert.Equal(t, map[string]string{"service": expected[i].service}, bucket.Group)
+			assert.Equal(t, expected[i].count, bucket.Count)
+		}
+	})
+
+	t.Run("without an interval", func(t *testing.T) {
+		resp, err := srv.AggregateLogs(context.Background(), &pb.AggregateRequest{GroupBy: []string{"level"}})
+		require.NoError(t, err)
+		require.Len(t, resp.Buckets, 2)
+		assert.Nil(t, resp.Buckets[0].Start)
+		assert.Equal(t, int64(4), resp.Buckets[0].Count)
+	})
+
+	t.Run("truncated to the limit", func(t *testing.T) {
+		resp, err := srv.AggregateLogs(context.Background(), &pb.AggregateRequest{GroupBy: []string{"service"}, Limit: 1})
+		require.NoError(t, err)
+		assert.True(t, resp.Truncated)
+		require.Len(t, resp.Buckets, 1)
+		assert.Equal(t, map[string]string{"service": "api"}, resp.Buckets[0].Group)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := map[string]*pb.AggregateRequest{
+			"unknown dimension":   {GroupBy: []string{"message"}},
+			"short interval":      {Interval: durationpb.New(time.Millisecond)},
+			"fractional interval": {Interval: durationpb.New(1500 * time.Millisecond)},
+			"negative limit":      {Limit: -1},
+			"page token":          {Filter: &pb.QueryRequest{PageToken: "token"}},
+			"ranked":              {Filter: &pb.QueryRequest{Text: "a", Rank: true}},
+			"malformed start":     {Filter: &pb.QueryRequest{StartTime: "yesterday"}},
+		}
+		for name, req := range requests {
+			_, err := srv.AggregateLogs(context.Background(), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// DefaultCatalogLimit is the value limit of catalog requests that do not set one
+	DefaultCatalogLimit = 1000
+
+	// MaxCatalogLimit caps the values a catalog request may return
+	MaxCatalogLimit = 10000
+)
+
+// ListServices lists the distinct services that sent logs in the window
+func (s *LogAggregatorServer) ListServices(ctx context.Context, req *pb.CatalogRequest) (*pb.CatalogResponse, error) {
+	return s.listCatalog(ctx, storage.CatalogServices, req)
+}
+
+// ListLevels lists the distinct levels of logs in the window
+func (s *LogAggregatorServer) ListLevels(ctx context.Context, req *pb.CatalogRequest) (*pb.CatalogResponse, error) {
+	return s.listCatalog(ctx, storage.CatalogLevels, req)
+}
+
+// ListFieldKeys lists the distinct top-level field keys of logs in the window
+func (s *LogAggregatorServer) ListFieldKeys(ctx context.Context, req *pb.CatalogRequest) (*pb.CatalogResponse, error) {
+	return s.listCatalog(ctx, storage.CatalogFieldKeys, req)
+}
+
+func (s *LogAggregatorServer) listCatalog(ctx context.Context, kind storage.CatalogKind, req *pb.CatalogRequest) (*pb.CatalogResponse, error) {
+	query, limit, err := catalogQuery(kind, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"kind":  kind,
+		"limit": limit,
+	}).Info("Listing log catalog")
+
+	entries, err := s.storage.ListCatalog(ctx, query)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list log catalog")
+		return nil, err
+	}
+
+	resp := &pb.CatalogResponse{
+		Values:    make([]*pb.CatalogValue, 0, min(len(entries), limit)),
+		Truncated: len(entries) > limit,
+	}
+	for _, entry := range entries[:min(len(entries), limit)] {
+		resp.Values = append(resp.Values, &pb.CatalogValue{
+			Value:     entry.Value,
+			Count:     entry.Count,
+			FirstSeen: timestamppb.New(entry.FirstSeen),
+			LastSeen:  timestamppb.New(entry.LastSeen),
+		})
+	}
+	return resp, nil
+}
+
+// catalogQuery converts a catalog request into a storage query and the
+// number of values to return. The query fetches one extra value to detect
+// truncation.
+func catalogQuery(kind storage.CatalogKind, req *pb.CatalogRequest) (storage.CatalogQuery, int, error) {
+	query := storage.CatalogQuery{Kind: kind}
+	var err error
+	if query.Start, err = catalogTime("start", req.GetStart()); err != nil {
+		return query, 0, err
+	}
+	if query.End, err = catalogTime("end", req.GetEnd()); err != nil {
+		return query, 0, err
+	}
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return query, 0, fmt.Errorf("limit must not be negative, got %d", limit)
+	case limit == 0:
+		limit = DefaultCatalogLimit
+	case limit > MaxCatalogLimit:
+		limit = MaxCatalogLimit
+	}
+	query.Limit = limit + 1
+
+	if err := query.Validate(); err != nil {
+		return query, 0, err
+	}
+	return query, limit, nil
+}
+
+// catalogTime converts an optional window bound
+func catalogTime(name string, timestamp *timestamppb.Timestamp) (*time.Time, error) {
+	if timestamp == nil {
+		return nil, nil
+	}
+	if err := timestamp.CheckValid(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	t := timestamp.AsTime()
+	return &t, nil
+}
@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestLogAggregatorServer_Catalog(t *testing.T) {
+	hour := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+	srv, store := newTestServer(t)
+	require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+		{Timestamp: hour.Add(5 * time.Minute), Service: "api", Level: "info", Message: "a", Fields: map[string]any{"user": "alice"}},
+		{Timestamp: hour.Add(10 * time.Minute), Service: "api", Level: "error", Message: "b", Fields: map[string]any{"status": 500.0}},
+		{Timestamp: hour.Add(2 * time.Hour), Service: "db", Level: "warn", Message: "c", Fields: map[string]any{"user": "bob"}},
+	}))
+
+	t.Run("services", func(t *testing.T) {
+		resp, err := srv.ListServices(context.Background(), &pb.CatalogRequest{})
+		require.NoError(t, err)
+		assert.False(t, resp.Truncated)
+		require.Len(t, resp.Values, 2)
+		assert.Equal(t, "api", resp.Values[0].Value)
+		assert.Equal(t, int64(2), resp.Values[0].Count)
+		assert.True(t, hour.Add(5*time.Minute).Equal(resp.Values[0].FirstSeen.AsTime()))
+		assert.True(t, hour.Add(10*time.Minute).Equal(resp.Values[0].LastSeen.AsTime()))
+		assert.Equal(t, "db", resp.Values[1].Value)
+	})
+
+	t.Run("levels in a window", func(t *testing.T) {
+		resp, err := srv.ListLevels(context.Background(), &pb.CatalogRequest{
+			Start: timestamppb.New(hour.Add(30 * time.Minute)),
+			End:   timestamppb.New(hour.Add(30 * time.Minute)),
+		})
+		require.NoError(t, err)
+		var levels []string
+		for _, value := range resp.Values {
+			levels = append(levels, value.Value)
+		}
+		assert.Equal(t, []string{"error", "info"}, levels, "window widened to the whole hour")
+	})
+
+	t.Run("field keys truncated to the limit", func(t *testing.T) {
+		resp, err := srv.ListFieldKeys(context.Background(), &pb.CatalogRequest{Limit: 1})
+		require.NoError(t, err)
+		assert.True(t, resp.Truncated)
+		require.Len(t, resp.Values, 1)
+		assert.Equal(t, "status", resp.Values[0].Value)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := map[string]*pb.CatalogRequest{
+			"negative limit":  {Limit: -1},
+			"start after end": {Start: timestamppb.New(hour.Add(2 * time.Hour)), End: timestamppb.New(hour)},
+			"malformed start": {Start: &timestamppb.Timestamp{Nanos: -1}},
+		}
+		for name, req := range requests {
+			_, err := srv.ListServices(context.Background(), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+)
+
+const (
+	// DefaultPageSize is the page size of queries that do not set a limit
+	DefaultPageSize = 100
+
+	// MaxPageSize caps the page size a query may request
+	MaxPageSize = 1000
+)
+
+var errInvalidPageToken = errors.New("invalid page token")
+
+// pageToken is the decoded form of a next_page_token. Time-ordered
+// queries resume after the last log returned; relevance-ranked queries,
+// which have no stable keyset, resume at an offset.
+type pageToken struct {
+	Timestamp *time.Time `json:"t,omitempty"`
+	ID        int64      `json:"i,omitempty"`
+	Ascending bool       `json:"a,omitempty"`
+	Offset    int        `json:"o,omitempty"`
+}
+
+func encodePageToken(token pageToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(encoded string) (pageToken, error) {
+	var token pageToken
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return token, errInvalidPageToken
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return token, errInvalidPageToken
+	}
+	return token, nil
+}
+
+// pageSize resolves the requested limit to a page size
+func pageSize(limit int32) (int, error) {
+	switch {
+	case limit < 0:
+		return 0, fmt.Errorf("limit must not be negative, got %d", limit)
+	case limit == 0:
+		return DefaultPageSize, nil
+	case limit > MaxPageSize:
+		return MaxPageSize, nil
+	}
+	return int(limit), nil
+}
+
+// applyPageToken positions filter at the page described by encoded
+func applyPageToken(filter *storage.QueryFilter, encoded string) error {
+	if encoded == "" {
+		return nil
+	}
+	token, err := decodePageToken(encoded)
+	if err != nil {
+		return err
+	}
+
+	ranked := filter.Rank && filter.Text != ""
+	switch {
+	case ranked && token.Timestamp == nil && token.Offset > 0:
+		filter.Offset = token.Offset
+	case !ranked && token.Timestamp != nil && token.Offset == 0 && token.Ascending == filter.Ascending:
+		filter.After = &storage.Cursor{Timestamp: *token.Timestamp, ID: token.ID}
+	default:
+		return errInvalidPageToken
+	}
+	return nil
+}
+
+// nextPageToken returns the token for the page following entries, which
+// was fetched with filter, or an empty string on the last page
+func nextPageToken(filter storage.QueryFilter, entries []storage.LogEntry, size int) string {
+	if len(entries) <= size {
+		return ""
+	}
+	if filter.Rank && filter.Text != "" {
+		return encodePageToken(pageToken{Offset: filter.Offset + size})
+	}
+	last := entries[size-1]
+	return encodePageToken(pageToken{Timestamp: &last.Timestamp, ID: last.ID, Ascending: filter.Ascending})
+}
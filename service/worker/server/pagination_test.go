@@ -0,0 +1,167 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/server"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func seedLogs(t *testing.T, store storage.Storage, count int) {
+	t.Helper()
+	base := time.Now().Add(-time.Hour)
+	entries := make([]storage.LogEntry, count)
+	for i := range entries {
+		entries[i] = storage.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Service:   "api",
+			Level:     "info",
+			Message:   fmt.Sprintf("log %d", i),
+		}
+	}
+	require.NoError(t, store.InsertLogs(context.Background(), entries))
+}
+
+func collectPages(t *testing.T, srv *server.LogAggregatorServer, req *pb.QueryRequest) ([]string, int) {
+	t.Helper()
+	var messages []string
+	pages := 0
+	for {
+		resp, err := srv.QueryLogs(context.Background(), req)
+		require.NoError(t, err)
+		pages++
+		for _, e := range resp.Events {
+			messages = append(messages, e.Message)
+		}
+		if resp.NextPageToken == "" {
+			return messages, pages
+		}
+		require.Less(t, pages, 100, "pagination did not terminate")
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func TestLogAggregatorServer_QueryLogsPagination(t *testing.T) {
+	t.Run("pages through every log once", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 5)
+
+		messages, pages := collectPages(t, srv, &pb.QueryRequest{Limit: 2})
+		assert.Equal(t, []string{"log 4", "log 3", "log 2", "log 1", "log 0"}, messages)
+		assert.Equal(t, 3, pages)
+	})
+
+	t.Run("logs arriving between pages do not shift later pages", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 4)
+
+		first, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Limit: 2})
+		require.NoError(t, err)
+		require.NotEmpty(t, first.NextPageToken)
+
+		require.NoError(t, store.InsertLog(context.Background(), storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "late"}))
+
+		second, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Limit: 2, PageToken: first.NextPageToken})
+		require.NoError(t, err)
+		require.Len(t, second.Events, 2)
+		assert.Equal(t, "log 1", second.Events[0].Message)
+		assert.Equal(t, "log 0", second.Events[1].Message)
+		assert.Empty(t, second.NextPageToken)
+	})
+
+	t.Run("default and maximum page size", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, server.MaxPageSize+1)
+
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{})
+		require.NoError(t, err)
+		assert.Len(t, resp.Events, server.DefaultPageSize)
+		assert.NotEmpty(t, resp.NextPageToken)
+
+		resp, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{Limit: server.MaxPageSize * 2})
+		require.NoError(t, err)
+		assert.Len(t, resp.Events, server.MaxPageSize)
+		assert.NotEmpty(t, resp.NextPageToken)
+	})
+
+	t.Run("ranked results page by offset", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 5)
+
+		messages, pages := collectPages(t, srv, &pb.QueryRequest{Text: "log", Rank: true, Limit: 2})
+		assert.Len(t, messages, 5)
+		assert.ElementsMatch(t, []string{"log 0", "log 1", "log 2", "log 3", "log 4"}, messages)
+		assert.Equal(t, 3, pages)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 3)
+
+		ranked, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Text: "log", Rank: true, Limit: 1})
+		require.NoError(t, err)
+		timed, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Limit: 1})
+		require.NoError(t, err)
+
+		requests := map[string]*pb.QueryRequest{
+			"negative limit":            {Limit: -1},
+			"malformed token":           {PageToken: "not a token!"},
+			"token is not JSON":         {PageToken: "bm90LWpzb24"},
+			"ranked token on time page": {PageToken: ranked.NextPageToken},
+			"time token on ranked page": {Text: "log", Rank: true, PageToken: timed.NextPageToken},
+		}
+		for name, req := range requests {
+			_, err := srv.QueryLogs(context.Background(), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
+
+func TestLogAggregatorServer_QueryLogsTimeRangeAndOrder(t *testing.T) {
+	srv, store := newTestServer(t)
+	seedLogs(t, store, 5)
+
+	all, err := store.QueryLogs(context.Background(), storage.QueryFilter{Ascending: true})
+	require.NoError(t, err)
+	start := all[1].Timestamp.Format(time.RFC3339Nano)
+	end := all[3].Timestamp.Format(time.RFC3339Nano)
+
+	t.Run("time range is inclusive", func(t *testing.T) {
+		messages, _ := collectPages(t, srv, &pb.QueryRequest{StartTime: start, EndTime: end})
+		assert.Equal(t, []string{"log 3", "log 2", "log 1"}, messages)
+	})
+
+	t.Run("oldest first pages forwards", func(t *testing.T) {
+		messages, pages := collectPages(t, srv, &pb.QueryRequest{Order: pb.SortOrder_OLDEST_FIRST, Limit: 2})
+		assert.Equal(t, []string{"log 0", "log 1", "log 2", "log 3", "log 4"}, messages)
+		assert.Equal(t, 3, pages)
+	})
+
+	t.Run("token keeps its order", func(t *testing.T) {
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Order: pb.SortOrder_OLDEST_FIRST, Limit: 1})
+		require.NoError(t, err)
+		_, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{PageToken: resp.NextPageToken})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := map[string]*pb.QueryRequest{
+			"malformed start": {StartTime: "yesterday"},
+			"malformed end":   {EndTime: "2025-13-01T00:00:00Z"},
+			"inverted range":  {StartTime: end, EndTime: start},
+			"unknown order":   {Order: pb.SortOrder(7)},
+		}
+		for name, req := range requests {
+			_, err := srv.QueryLogs(context.Background(), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
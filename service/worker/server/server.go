@@ -2,11 +2,19 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/protos/eventfields"
+	"github.com/jgfranco17/echoris/service/querylang"
 	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/tail"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // LogAggregatorServer implements the LogAggregator gRPC service
@@ -14,6 +22,14 @@ type LogAggregatorServer struct {
 	pb.UnimplementedLogAggregatorServer
 	storage storage.Storage
 	logger  *logrus.Logger
+	hub     *tail.Hub
+
+	chunkSize     int
+	flushInterval time.Duration
+
+	// publishMu orders inserts against tail subscriptions so that a log is
+	// either replayed to a new tail or delivered to it live, never both
+	publishMu sync.RWMutex
 }
 
 // NewLogAggregatorServer creates a new LogAggregatorServer instance
@@ -21,10 +37,27 @@ func NewLogAggregatorServer(store storage.Storage, logger *logrus.Logger) *LogAg
 	return &LogAggregatorServer{
 		storage: store,
 		logger:  logger,
+		hub:     tail.NewHub(tail.DefaultBuffer, tail.DefaultHistory),
+
+		chunkSize:     DefaultChunkSize,
+		flushInterval: DefaultFlushInterval,
 	}
 }
 
-// SendLogs receives and stores a batch of log events
+// WithChunking sets how many streamed events StreamLogs accumulates, and
+// for how long, before committing them to storage
+func (s *LogAggregatorServer) WithChunking(size int, interval time.Duration) *LogAggregatorServer {
+	if size > 0 {
+		s.chunkSize = size
+	}
+	if interval > 0 {
+		s.flushInterval = interval
+	}
+	return s
+}
+
+// SendLogs validates and stores a batch of log events. Invalid events are
+// rejected individually; the rest are stored together.
 func (s *LogAggregatorServer) SendLogs(ctx context.Context, batch *pb.LogBatch) (*pb.SendLogsResponse, error) {
 	if len(batch.Events) == 0 {
 		return &pb.SendLogsResponse{Ok: true}, nil
@@ -32,43 +65,65 @@ func (s *LogAggregatorServer) SendLogs(ctx context.Context, batch *pb.LogBatch)
 
 	s.logger.WithField("count", len(batch.Events)).Info("Receiving log batch")
 
+	var report ingestReport
+	receivedAt := time.Now().UTC()
 	entries := make([]storage.LogEntry, 0, len(batch.Events))
-	for _, e := range batch.Events {
-		t, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+	for i, e := range batch.Events {
+		entry, err := validateEvent(e, receivedAt)
 		if err != nil {
-			s.logger.WithError(err).Warn("Failed to parse timestamp, using current time")
-			t = time.Now().UTC()
+			report.reject(int64(i), err)
+			continue
 		}
-
-		entries = append(entries, storage.LogEntry{
-			Timestamp: t,
-			Service:   e.Service,
-			Level:     e.Level,
-			Message:   e.Message,
-			Fields:    e.Fields,
-		})
+		entries = append(entries, entry)
+	}
+	if report.rejected > 0 {
+		s.logger.WithField("rejected", report.rejected).Warn("Rejected invalid log events")
 	}
 
-	if err := s.storage.InsertLogs(ctx, entries); err != nil {
-		s.logger.WithError(err).Error("Failed to insert logs")
-		return &pb.SendLogsResponse{Ok: false}, err
+	if len(entries) > 0 {
+		if err := s.store(ctx, entries); err != nil {
+			s.logger.WithError(err).Error("Failed to insert logs")
+			return &pb.SendLogsResponse{Ok: false}, err
+		}
 	}
+	report.accepted = int64(len(entries))
 
 	s.logger.WithField("count", len(entries)).Info("Successfully stored logs")
-	return &pb.SendLogsResponse{Ok: true}, nil
+	return &pb.SendLogsResponse{
+		Ok:       report.rejected == 0,
+		Accepted: report.accepted,
+		Rejected: report.rejected,
+		Errors:   report.errors,
+	}, nil
 }
 
-// QueryLogs retrieves logs based on the provided filters
-func (s *LogAggregatorServer) QueryLogs(ctx context.Context, req *pb.QueryRequest) (*pb.LogBatch, error) {
+// store inserts entries and publishes them to live tails
+func (s *LogAggregatorServer) store(ctx context.Context, entries []storage.LogEntry) error {
+	s.publishMu.RLock()
+	defer s.publishMu.RUnlock()
+
+	if err := s.storage.InsertLogs(ctx, entries); err != nil {
+		return err
+	}
+	s.hub.Publish(entries)
+	return nil
+}
+
+// QueryLogs retrieves a page of logs based on the provided filters
+func (s *LogAggregatorServer) QueryLogs(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
 	s.logger.WithFields(logrus.Fields{
 		"service": req.Service,
 		"level":   req.Level,
+		"text":    req.Text,
+		"query":   req.Query,
+		"start":   req.StartTime,
+		"end":     req.EndTime,
+		"order":   req.Order.String(),
 	}).Info("Querying logs")
 
-	filter := storage.QueryFilter{
-		Service: req.Service,
-		Level:   req.Level,
-		Limit:   1000, // Default limit to prevent large result sets
+	filter, size, err := queryFilter(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	entries, err := s.storage.QueryLogs(ctx, filter)
@@ -77,26 +132,158 @@ func (s *LogAggregatorServer) QueryLogs(ctx context.Context, req *pb.QueryReques
 		return nil, err
 	}
 
-	resp := &pb.LogBatch{
-		Events: make([]*pb.LogEvent, 0, len(entries)),
+	resp := &pb.QueryResponse{
+		Events:        make([]*pb.LogEvent, 0, min(len(entries), size)),
+		NextPageToken: nextPageToken(filter, entries, size),
 	}
 
-	for _, entry := range entries {
-		resp.Events = append(resp.Events, &pb.LogEvent{
-			Timestamp: entry.Timestamp.Format(time.RFC3339Nano),
-			Service:   entry.Service,
-			Level:     entry.Level,
-			Message:   entry.Message,
-			Fields:    entry.Fields,
-		})
+	for _, entry := range entries[:min(len(entries), size)] {
+		event, err := logEvent(entry)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to convert logs")
+			return nil, err
+		}
+		resp.Events = append(resp.Events, event)
 	}
 
 	s.logger.WithField("count", len(resp.Events)).Info("Successfully retrieved logs")
 	return resp, nil
 }
 
+// queryFilter converts a query request into a storage filter and the page
+// size to return. The filter fetches one extra log to detect a next page.
+func queryFilter(req *pb.QueryRequest) (storage.QueryFilter, int, error) {
+	filter := storage.QueryFilter{
+		Service: req.Service,
+		Level:   req.Level,
+		Text:    req.Text,
+		Rank:    req.Rank,
+	}
+
+	fields, err := fieldFilters(req.Fields)
+	if err != nil {
+		return filter, 0, err
+	}
+	filter.Fields = fields
+
+	if req.Query != "" {
+		if filter.Expr, err = querylang.Parse(req.Query); err != nil {
+			return filter, 0, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+
+	if filter.StartTime, filter.EndTime, err = timeRange(req.StartTime, req.EndTime); err != nil {
+		return filter, 0, err
+	}
+
+	switch req.Order {
+	case pb.SortOrder_NEWEST_FIRST:
+	case pb.SortOrder_OLDEST_FIRST:
+		filter.Ascending = true
+	default:
+		return filter, 0, fmt.Errorf("unknown sort order %d", req.Order)
+	}
+
+	size, err := pageSize(req.Limit)
+	if err != nil {
+		return filter, 0, err
+	}
+	filter.Limit = size + 1
+
+	if err := applyPageToken(&filter, req.PageToken); err != nil {
+		return filter, 0, err
+	}
+	return filter, size, nil
+}
+
+// timeRange parses the optional RFC 3339 bounds of a query
+func timeRange(start, end string) (*time.Time, *time.Time, error) {
+	parse := func(name, value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, expected RFC 3339", name, value)
+		}
+		return &t, nil
+	}
+
+	startTime, err := parse("start_time", start)
+	if err != nil {
+		return nil, nil, err
+	}
+	endTime, err := parse("end_time", end)
+	if err != nil {
+		return nil, nil, err
+	}
+	if startTime != nil && endTime != nil && startTime.After(*endTime) {
+		return nil, nil, fmt.Errorf("start_time %s is after end_time %s", start, end)
+	}
+	return startTime, endTime, nil
+}
+
+// fieldOps maps protobuf field filter operators to storage operators
+var fieldOps = map[pb.FieldFilter_Op]storage.FieldOp{
+	pb.FieldFilter_EQUALS:           storage.FieldEquals,
+	pb.FieldFilter_NOT_EQUALS:       storage.FieldNotEquals,
+	pb.FieldFilter_EXISTS:           storage.FieldExists,
+	pb.FieldFilter_NOT_EXISTS:       storage.FieldNotExists,
+	pb.FieldFilter_PREFIX:           storage.FieldPrefix,
+	pb.FieldFilter_IN:               storage.FieldIn,
+	pb.FieldFilter_GREATER:          storage.FieldGreater,
+	pb.FieldFilter_GREATER_OR_EQUAL: storage.FieldGreaterOrEqual,
+	pb.FieldFilter_LESS:             storage.FieldLess,
+	pb.FieldFilter_LESS_OR_EQUAL:    storage.FieldLessOrEqual,
+}
+
+// fieldFilters converts and validates the field filters of a query
+func fieldFilters(filters []*pb.FieldFilter) ([]storage.FieldFilter, error) {
+	converted := make([]storage.FieldFilter, 0, len(filters))
+	for _, f := range filters {
+		op, ok := fieldOps[f.GetOp()]
+		if !ok {
+			return nil, fmt.Errorf("unknown field filter operator %d", f.GetOp())
+		}
+		filter := storage.FieldFilter{Key: f.GetKey(), Op: op, Values: f.GetValues()}
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+		converted = append(converted, filter)
+	}
+	return converted, nil
+}
+
+// logEvent converts a stored log into its protobuf form. The legacy
+// timestamp string and fields map are filled in for clients that predate
+// time and attributes.
+func logEvent(entry storage.LogEntry) (*pb.LogEvent, error) {
+	event := &pb.LogEvent{
+		Timestamp: entry.Timestamp.Format(time.RFC3339Nano),
+		Time:      timestamppb.New(entry.Timestamp),
+		Service:   entry.Service,
+		Level:     entry.Level,
+		Message:   entry.Message,
+		Id:        entry.ID,
+	}
+	if !entry.CreatedAt.IsZero() {
+		event.ReceivedAt = timestamppb.New(entry.CreatedAt)
+	}
+	if err := eventfields.Set(event, entry.Fields); err != nil {
+		return nil, fmt.Errorf("failed to convert log %d: %w", entry.ID, err)
+	}
+	return event, nil
+}
+
+// StopTailing ends every open TailLogs stream. GracefulStop waits for
+// streams to finish, so call it first.
+func (s *LogAggregatorServer) StopTailing() {
+	s.hub.Close()
+}
+
 // Close closes the server and its dependencies
 func (s *LogAggregatorServer) Close() error {
+	s.hub.Close()
 	if s.storage != nil {
 		return s.storage.Close()
 	}
@@ -2,7 +2,9 @@ package server_test
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,55 +13,24 @@ import (
 	"github.com/jgfranco17/echoris/service/worker/storage"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// MockStorage is a mock implementation of storage.Storage
-type MockStorage struct {
-	mock.Mock
-}
-
-func (m *MockStorage) InsertLog(ctx context.Context, entry storage.LogEntry) error {
-	args := m.Called(ctx, entry)
-	return args.Error(0)
-}
-
-func (m *MockStorage) InsertLogs(ctx context.Context, entries []storage.LogEntry) error {
-	args := m.Called(ctx, entries)
-	return args.Error(0)
-}
-
-func (m *MockStorage) QueryLogs(ctx context.Context, filter storage.QueryFilter) ([]storage.LogEntry, error) {
-	args := m.Called(ctx, filter)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]storage.LogEntry), args.Error(1)
-}
-
-func (m *MockStorage) DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
-	args := m.Called(ctx, olderThan)
-	return args.Get(0).(int64), args.Error(1)
-}
-
-func (m *MockStorage) Close() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockStorage) HealthCheck(ctx context.Context) error {
-	args := m.Called(ctx)
-	return args.Error(0)
+func newTestServer(t *testing.T) (*server.LogAggregatorServer, *storage.MemoryStorage) {
+	t.Helper()
+	store := storage.NewMemoryStorage()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard) // Suppress log output in tests
+	return server.NewLogAggregatorServer(store, logger), store
 }
 
 func TestLogAggregatorServer_SendLogs(t *testing.T) {
 	t.Run("successful send", func(t *testing.T) {
-		mockStorage := new(MockStorage)
-		logger := logrus.New()
-		logger.SetOutput(io.Discard) // Suppress log output in tests
-
-		srv := server.NewLogAggregatorServer(mockStorage, logger)
+		srv, store := newTestServer(t)
 
 		batch := &pb.LogBatch{
 			Events: []*pb.LogEvent{
@@ -73,20 +44,19 @@ func TestLogAggregatorServer_SendLogs(t *testing.T) {
 			},
 		}
 
-		mockStorage.On("InsertLogs", mock.Anything, mock.AnythingOfType("[]storage.LogEntry")).Return(nil)
-
 		resp, err := srv.SendLogs(context.Background(), batch)
 		require.NoError(t, err)
 		assert.True(t, resp.Ok)
-		mockStorage.AssertExpectations(t)
+
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		require.NoError(t, err)
+		require.Len(t, stored, 1)
+		assert.Equal(t, "Test message", stored[0].Message)
+		assert.Equal(t, map[string]any{"key": "value"}, stored[0].Fields)
 	})
 
 	t.Run("empty batch", func(t *testing.T) {
-		mockStorage := new(MockStorage)
-		logger := logrus.New()
-		logger.SetOutput(io.Discard)
-
-		srv := server.NewLogAggregatorServer(mockStorage, logger)
+		srv, store := newTestServer(t)
 
 		batch := &pb.LogBatch{Events: []*pb.LogEvent{}}
 
@@ -94,16 +64,13 @@ func TestLogAggregatorServer_SendLogs(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, resp.Ok)
 
-		// Should not call InsertLogs for empty batch
-		mockStorage.AssertNotCalled(t, "InsertLogs")
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, stored)
 	})
 
 	t.Run("multiple logs", func(t *testing.T) {
-		mockStorage := new(MockStorage)
-		logger := logrus.New()
-		logger.SetOutput(io.Discard)
-
-		srv := server.NewLogAggregatorServer(mockStorage, logger)
+		srv, store := newTestServer(t)
 
 		batch := &pb.LogBatch{
 			Events: []*pb.LogEvent{
@@ -122,39 +89,118 @@ func TestLogAggregatorServer_SendLogs(t *testing.T) {
 			},
 		}
 
-		mockStorage.On("InsertLogs", mock.Anything, mock.MatchedBy(func(entries []storage.LogEntry) bool {
-			return len(entries) == 2
-		})).Return(nil)
-
 		resp, err := srv.SendLogs(context.Background(), batch)
 		require.NoError(t, err)
 		assert.True(t, resp.Ok)
-		mockStorage.AssertExpectations(t)
+
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		require.NoError(t, err)
+		assert.Len(t, stored, 2)
+	})
+
+	t.Run("storage failure", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		require.NoError(t, store.Close())
+
+		batch := &pb.LogBatch{
+			Events: []*pb.LogEvent{
+				{Timestamp: time.Now().Format(time.RFC3339Nano), Service: "api", Level: "info", Message: "lost"},
+			},
+		}
+
+		resp, err := srv.SendLogs(context.Background(), batch)
+		assert.ErrorIs(t, err, storage.ErrStorageClosed)
+		assert.False(t, resp.Ok)
+	})
+
+	t.Run("prefers time over the legacy timestamp", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		happened := time.Date(2024, 5, 31, 8, 0, 0, 0, time.UTC)
+
+		resp, err := srv.SendLogs(context.Background(), &pb.LogBatch{Events: []*pb.LogEvent{
+			{Time: timestamppb.New(happened), Timestamp: "2000-01-01T00:00:00Z", Service: "api", Level: "info", Message: "both"},
+			{Timestamp: "2024-05-31T09:00:00Z", Service: "api", Level: "info", Message: "legacy"},
+			{Time: &timestamppb.Timestamp{Nanos: -1}, Service: "api", Level: "info", Message: "invalid"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), resp.Accepted)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, int64(2), resp.Errors[0].Index)
+		assert.Contains(t, resp.Errors[0].Reason, "invalid time")
+
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		assert.True(t, stored[0].Timestamp.Equal(happened.Add(time.Hour)))
+		assert.True(t, stored[1].Timestamp.Equal(happened))
+	})
+
+	t.Run("rejects invalid events individually", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		now := time.Now().Format(time.RFC3339Nano)
+
+		batch := &pb.LogBatch{
+			Events: []*pb.LogEvent{
+				{Timestamp: now, Service: "api", Level: "info", Message: "kept"},
+				{Timestamp: "yesterday", Service: "api", Level: "info", Message: "bad time"},
+				{Service: "api", Level: "info", Message: "no time"},
+				{Timestamp: now, Level: "info", Message: "no service"},
+				{Timestamp: now, Service: "api", Level: "info"},
+				{Timestamp: now, Service: "api", Level: strings.Repeat("x", server.MaxLevelLength+1), Message: "long level"},
+				{Timestamp: now, Service: "api", Level: "info", Message: "nul\x00byte"},
+				{Timestamp: now, Service: "api", Level: "info", Message: "bad field", Fields: map[string]string{"": "empty key"}},
+				{Timestamp: now, Service: "api", Level: "info", Message: "bad attribute", Attributes: &structpb.Struct{
+					Fields: map[string]*structpb.Value{"request": structpb.NewListValue(&structpb.ListValue{
+						Values: []*structpb.Value{structpb.NewStringValue("nul\x00byte")},
+					})},
+				}},
+				{Timestamp: now, Service: "api", Level: "info", Message: "also kept"},
+			},
+		}
+
+		resp, err := srv.SendLogs(context.Background(), batch)
+		require.NoError(t, err)
+		assert.False(t, resp.Ok)
+		assert.Equal(t, int64(2), resp.Accepted)
+		assert.Equal(t, int64(8), resp.Rejected)
+		assert.Equal(t, []*pb.EventError{
+			{Index: 1, Reason: `invalid timestamp "yesterday", expected RFC 3339`},
+			{Index: 2, Reason: "timestamp is required"},
+			{Index: 3, Reason: "service is required"},
+			{Index: 4, Reason: "message is required"},
+			{Index: 5, Reason: "level exceeds 32 bytes"},
+			{Index: 6, Reason: "message must not contain NUL bytes"},
+			{Index: 7, Reason: "field key is required"},
+			{Index: 8, Reason: `field "request" must not contain NUL bytes`},
+		}, resp.Errors)
+
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		assert.Equal(t, "also kept", stored[0].Message)
+		assert.Equal(t, "kept", stored[1].Message)
 	})
 }
 
 func TestLogAggregatorServer_QueryLogs(t *testing.T) {
 	t.Run("successful query", func(t *testing.T) {
-		mockStorage := new(MockStorage)
-		logger := logrus.New()
-		logger.SetOutput(io.Discard)
-
-		srv := server.NewLogAggregatorServer(mockStorage, logger)
+		srv, store := newTestServer(t)
 
-		expectedEntries := []storage.LogEntry{
+		require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
 			{
-				ID:        1,
 				Timestamp: time.Now(),
 				Service:   "api",
 				Level:     "info",
 				Message:   "Test log",
-				Fields:    map[string]string{"key": "value"},
+				Fields:    map[string]any{"key": "value"},
 			},
-		}
-
-		mockStorage.On("QueryLogs", mock.Anything, mock.MatchedBy(func(filter storage.QueryFilter) bool {
-			return filter.Service == "api" && filter.Level == "info"
-		})).Return(expectedEntries, nil)
+			{
+				Timestamp: time.Now(),
+				Service:   "api",
+				Level:     "error",
+				Message:   "Other log",
+			},
+		}))
 
 		req := &pb.QueryRequest{
 			Service: "api",
@@ -167,17 +213,151 @@ func TestLogAggregatorServer_QueryLogs(t *testing.T) {
 		assert.Equal(t, "api", resp.Events[0].Service)
 		assert.Equal(t, "info", resp.Events[0].Level)
 		assert.Equal(t, "Test log", resp.Events[0].Message)
-		mockStorage.AssertExpectations(t)
+		assert.Equal(t, map[string]string{"key": "value"}, resp.Events[0].Fields)
+		assert.Equal(t, map[string]any{"key": "value"}, resp.Events[0].Attributes.AsMap())
 	})
 
-	t.Run("empty results", func(t *testing.T) {
-		mockStorage := new(MockStorage)
-		logger := logrus.New()
-		logger.SetOutput(io.Discard)
+	t.Run("typed attributes", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		now := time.Now()
+
+		for i, latency := range []float64{120, 750.5} {
+			attributes, err := structpb.NewStruct(map[string]any{
+				"latency_ms": latency,
+				"cached":     i == 0,
+				"request":    map[string]any{"method": "GET"},
+			})
+			require.NoError(t, err)
+			_, err = srv.SendLogs(context.Background(), &pb.LogBatch{Events: []*pb.LogEvent{{
+				Time:       timestamppb.New(now.Add(time.Duration(i) * time.Second)),
+				Service:    "api",
+				Level:      "info",
+				Message:    fmt.Sprintf("request %d", i),
+				Fields:     map[string]string{"ignored": "when attributes are set"},
+				Attributes: attributes,
+			}}})
+			require.NoError(t, err)
+		}
+
+		stored, err := store.QueryLogs(context.Background(), storage.QueryFilter{Ascending: true})
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		assert.Equal(t, map[string]any{"latency_ms": 120.0, "cached": true, "request": map[string]any{"method": "GET"}}, stored[0].Fields)
+
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Fields: []*pb.FieldFilter{{Key: "latency_ms", Op: pb.FieldFilter_GREATER, Values: []string{"500"}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 1)
+		assert.Equal(t, "request 1", resp.Events[0].Message)
+		assert.Equal(t, 750.5, resp.Events[0].Attributes.AsMap()["latency_ms"])
+		assert.Equal(t, map[string]string{
+			"latency_ms": "750.5",
+			"cached":     "false",
+			"request":    `{"method":"GET"}`,
+		}, resp.Events[0].Fields)
+
+		_, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Fields: []*pb.FieldFilter{{Key: "latency_ms", Op: pb.FieldFilter_LESS, Values: []string{"fast"}}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("returns time, received at and id", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+		happened := time.Date(2024, 5, 31, 8, 0, 0, 123456789, time.UTC)
+
+		_, err := srv.SendLogs(context.Background(), &pb.LogBatch{Events: []*pb.LogEvent{
+			{Time: timestamppb.New(happened), Service: "api", Level: "info", Message: "typed"},
+		}})
+		require.NoError(t, err)
+
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 1)
+		event := resp.Events[0]
+		assert.True(t, happened.Equal(event.Time.AsTime()))
+		assert.Equal(t, "2024-05-31T08:00:00.123456789Z", event.Timestamp)
+		assert.WithinDuration(t, time.Now(), event.ReceivedAt.AsTime(), time.Minute)
+		assert.NotZero(t, event.Id)
+	})
+
+	t.Run("text search", func(t *testing.T) {
+		srv, store := newTestServer(t)
 
-		srv := server.NewLogAggregatorServer(mockStorage, logger)
+		now := time.Now()
+		require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+			{Timestamp: now.Add(-2 * time.Second), Service: "api", Level: "error", Message: "connection refused"},
+			{Timestamp: now.Add(-time.Second), Service: "api", Level: "info", Message: "request served"},
+			{Timestamp: now, Service: "api", Level: "warn", Message: "connection retry after connection reset"},
+		}))
 
-		mockStorage.On("QueryLogs", mock.Anything, mock.Anything).Return([]storage.LogEntry{}, nil)
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{Text: "connection"})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 2)
+		assert.Equal(t, "connection retry after connection reset", resp.Events[0].Message)
+
+		resp, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{Text: "refused connection", Rank: true})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 1)
+		assert.Equal(t, "connection refused", resp.Events[0].Message)
+	})
+
+	t.Run("field filters", func(t *testing.T) {
+		srv, store := newTestServer(t)
+
+		now := time.Now()
+		require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+			{Timestamp: now.Add(-time.Second), Service: "api", Level: "info", Message: "eu", Fields: map[string]any{"region": "eu"}},
+			{Timestamp: now, Service: "api", Level: "info", Message: "us", Fields: map[string]any{"region": "us"}},
+		}))
+
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Fields: []*pb.FieldFilter{{Key: "region", Op: pb.FieldFilter_NOT_EQUALS, Values: []string{"eu"}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 1)
+		assert.Equal(t, "us", resp.Events[0].Message)
+	})
+
+	t.Run("query language", func(t *testing.T) {
+		srv, store := newTestServer(t)
+
+		now := time.Now()
+		require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+			{Timestamp: now.Add(-2 * time.Second), Service: "api", Level: "error", Message: "request timeout", Fields: map[string]any{"user": "alice"}},
+			{Timestamp: now.Add(-time.Second), Service: "api", Level: "warn", Message: "request timeout", Fields: map[string]any{"user": "bob"}},
+			{Timestamp: now, Service: "api", Level: "info", Message: "request served", Fields: map[string]any{"user": "alice"}},
+		}))
+
+		resp, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Query: `service:api AND level:(error OR warn) AND fields.user:"alice" AND message~"timeout"`,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 1)
+		assert.Equal(t, "error", resp.Events[0].Level)
+
+		_, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{Query: "service:api AND"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "invalid query: syntax error at column 16: expected a term, found end of query", status.Convert(err).Message())
+	})
+
+	t.Run("invalid field filter", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+
+		_, err := srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Fields: []*pb.FieldFilter{{Key: "region", Op: pb.FieldFilter_IN}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = srv.QueryLogs(context.Background(), &pb.QueryRequest{
+			Fields: []*pb.FieldFilter{{Key: "region", Op: pb.FieldFilter_Op(42)}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("empty results", func(t *testing.T) {
+		srv, _ := newTestServer(t)
 
 		req := &pb.QueryRequest{
 			Service: "nonexistent",
@@ -187,20 +367,13 @@ func TestLogAggregatorServer_QueryLogs(t *testing.T) {
 		resp, err := srv.QueryLogs(context.Background(), req)
 		require.NoError(t, err)
 		assert.Empty(t, resp.Events)
-		mockStorage.AssertExpectations(t)
 	})
 }
 
 func TestLogAggregatorServer_Close(t *testing.T) {
-	mockStorage := new(MockStorage)
-	logger := logrus.New()
-	logger.SetOutput(nil)
-
-	srv := server.NewLogAggregatorServer(mockStorage, logger)
-
-	mockStorage.On("Close").Return(nil)
+	srv, store := newTestServer(t)
 
 	err := srv.Close()
 	assert.NoError(t, err)
-	mockStorage.AssertExpectations(t)
+	assert.ErrorIs(t, store.HealthCheck(context.Background()), storage.ErrStorageClosed)
 }
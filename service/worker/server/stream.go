@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultChunkSize is the number of streamed events committed together
+	DefaultChunkSize = 500
+
+	// DefaultFlushInterval bounds how long a streamed event waits for its
+	// chunk to fill before it is committed
+	DefaultFlushInterval = time.Second
+)
+
+// StreamLogs receives log events one at a time and commits them to storage
+// in chunks bounded by size and age. Invalid events, and the events of a
+// chunk that fails to insert, are rejected while the stream carries on; once
+// the client closes the stream the remaining events are committed and the
+// totals returned.
+func (s *LogAggregatorServer) StreamLogs(stream grpc.ClientStreamingServer[pb.LogEvent, pb.StreamLogsResponse]) error {
+	ctx := stream.Context()
+	received := make(chan *pb.LogEvent)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case received <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	chunks := &chunker{server: s, size: s.chunkSize}
+	timer := time.NewTimer(s.flushInterval)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-received:
+			if len(chunks.pending) == 0 {
+				timer.Reset(s.flushInterval)
+			}
+			if chunks.add(ctx, event) {
+				timer.Stop()
+			}
+		case <-timer.C:
+			chunks.flush(ctx)
+		case err := <-failed:
+			if !errors.Is(err, io.EOF) {
+				// Uncommitted events are dropped with the failed stream
+				s.logger.WithError(err).Warn("Log stream ended abnormally")
+				return err
+			}
+			chunks.flush(ctx)
+			s.logger.WithFields(logrus.Fields{
+				"accepted": chunks.report.accepted,
+				"rejected": chunks.report.rejected,
+			}).Info("Log stream closed")
+			return stream.SendAndClose(&pb.StreamLogsResponse{
+				Accepted: chunks.report.accepted,
+				Rejected: chunks.report.rejected,
+				Errors:   chunks.report.errors,
+			})
+		}
+	}
+}
+
+// chunker validates streamed events and commits them in chunks
+type chunker struct {
+	server  *LogAggregatorServer
+	size    int
+	next    int64 // Index of the next event in the stream
+	pending []storage.LogEntry
+	indices []int64 // Stream index of each pending entry
+	report  ingestReport
+}
+
+// add queues a valid event and commits the chunk once it is full,
+// reporting whether it did
+func (c *chunker) add(ctx context.Context, event *pb.LogEvent) bool {
+	index := c.next
+	c.next++
+	entry, err := validateEvent(event, time.Now().UTC())
+	if err != nil {
+		c.report.reject(index, err)
+		return false
+	}
+
+	c.pending = append(c.pending, entry)
+	c.indices = append(c.indices, index)
+	if len(c.pending) < c.size {
+		return false
+	}
+	c.flush(ctx)
+	return true
+}
+
+// flush commits the pending entries
+func (c *chunker) flush(ctx context.Context) {
+	if len(c.pending) == 0 {
+		return
+	}
+	if err := c.server.store(ctx, c.pending); err != nil {
+		c.server.logger.WithError(err).WithField("count", len(c.pending)).Error("Failed to insert streamed logs")
+		for _, index := range c.indices {
+			c.report.reject(index, fmt.Errorf("failed to store log: %w", err))
+		}
+	} else {
+		c.report.accepted += int64(len(c.pending))
+	}
+	c.pending = make([]storage.LogEntry, 0, c.size)
+	c.indices = make([]int64, 0, c.size)
+}
@@ -0,0 +1,131 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// ingestStream feeds events to StreamLogs and captures its summary
+type ingestStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	events   chan *pb.LogEvent
+	err      error
+	response *pb.StreamLogsResponse
+}
+
+func newIngestStream() *ingestStream {
+	return &ingestStream{ctx: context.Background(), events: make(chan *pb.LogEvent), err: io.EOF}
+}
+
+func (s *ingestStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ingestStream) Recv() (*pb.LogEvent, error) {
+	event, ok := <-s.events
+	if !ok {
+		return nil, s.err
+	}
+	return event, nil
+}
+
+func (s *ingestStream) SendAndClose(response *pb.StreamLogsResponse) error {
+	s.response = response
+	return nil
+}
+
+func (s *ingestStream) send(count int) {
+	for i := 0; i < count; i++ {
+		s.events <- &pb.LogEvent{
+			Timestamp: time.Now().Format(time.RFC3339Nano),
+			Service:   "api",
+			Level:     "info",
+			Message:   fmt.Sprintf("streamed %d", i),
+		}
+	}
+}
+
+func storedCount(t *testing.T, store storage.Storage) int {
+	t.Helper()
+	entries, err := store.QueryLogs(context.Background(), storage.QueryFilter{})
+	require.NoError(t, err)
+	return len(entries)
+}
+
+func TestLogAggregatorServer_StreamLogs(t *testing.T) {
+	t.Run("commits full chunks and the remainder", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		srv.WithChunking(2, time.Hour)
+		stream := newIngestStream()
+		result := make(chan error, 1)
+		go func() { result <- srv.StreamLogs(stream) }()
+
+		stream.send(4)
+		assert.Eventually(t, func() bool { return storedCount(t, store) == 4 }, time.Second, 5*time.Millisecond)
+		stream.send(1)
+		close(stream.events)
+
+		require.NoError(t, <-result)
+		assert.Equal(t, &pb.StreamLogsResponse{Accepted: 5}, stream.response)
+		assert.Equal(t, 5, storedCount(t, store))
+	})
+
+	t.Run("commits partial chunks after the flush interval", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		srv.WithChunking(100, 10*time.Millisecond)
+		stream := newIngestStream()
+		result := make(chan error, 1)
+		go func() { result <- srv.StreamLogs(stream) }()
+
+		stream.send(3)
+		assert.Eventually(t, func() bool { return storedCount(t, store) == 3 }, time.Second, 5*time.Millisecond)
+		close(stream.events)
+
+		require.NoError(t, <-result)
+		assert.Equal(t, &pb.StreamLogsResponse{Accepted: 3}, stream.response)
+	})
+
+	t.Run("rejects invalid events and chunks that fail to insert", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		srv.WithChunking(2, time.Hour)
+		require.NoError(t, store.Close())
+		stream := newIngestStream()
+		result := make(chan error, 1)
+		go func() { result <- srv.StreamLogs(stream) }()
+
+		stream.send(2)
+		stream.events <- &pb.LogEvent{Service: "api", Level: "info", Message: "no timestamp"}
+		close(stream.events)
+
+		require.NoError(t, <-result)
+		assert.Equal(t, &pb.StreamLogsResponse{
+			Rejected: 3,
+			Errors: []*pb.EventError{
+				{Index: 0, Reason: "failed to store log: storage is closed"},
+				{Index: 1, Reason: "failed to store log: storage is closed"},
+				{Index: 2, Reason: "timestamp is required"},
+			},
+		}, stream.response)
+	})
+
+	t.Run("returns stream errors", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+		stream := newIngestStream()
+		stream.err = errors.New("connection reset")
+		close(stream.events)
+
+		assert.EqualError(t, srv.StreamLogs(stream), "connection reset")
+		assert.Nil(t, stream.response)
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/tail"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TailLogs replays the most recent logs matching the request's filter, or
+// the logs after the request's cursor, and then streams matching logs as
+// SendLogs stores them
+func (s *LogAggregatorServer) TailLogs(req *pb.TailRequest, stream grpc.ServerStreamingServer[pb.TailEvent]) error {
+	filter, err := tailFilter(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx := stream.Context()
+	s.logger.WithFields(logrus.Fields{
+		"service": filter.Service,
+		"level":   filter.Level,
+		"replay":  req.Replay,
+		"resume":  req.Cursor != "",
+	}).Info("Tailing logs")
+
+	sub, backlog, err := s.subscribe(ctx, filter, req.Cursor)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	for _, event := range backlog {
+		if err := s.sendTailEvent(stream, event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Done():
+			return tailError(sub.Err())
+		case event := <-sub.Entries():
+			if err := s.sendTailEvent(stream, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replayTimeout bounds the replay query of a new tail
+const replayTimeout = 5 * time.Second
+
+// subscribe registers a tail subscription and returns the logs to send
+// ahead of live ones, oldest first. A tail that cannot resume from its
+// cursor falls back to replaying recent logs. Replayed logs are held to the
+// same matching as live ones, so a backend that searches text differently
+// never replays a log the tail would not deliver live.
+func (s *LogAggregatorServer) subscribe(ctx context.Context, filter storage.QueryFilter, cursor string) (*tail.Subscription, []tail.Event, error) {
+	sub, backlog, err := s.register(filter, cursor)
+	if errors.Is(err, tail.ErrCursorExpired) {
+		s.logger.WithField("cursor", cursor).Warn("Tail cursor expired, replaying recent logs instead")
+		cursor = ""
+		sub, backlog, err = s.register(filter, "")
+	}
+	if err != nil {
+		return nil, nil, tailError(err)
+	}
+	if cursor != "" || filter.Limit == 0 {
+		sub.Delivered()
+		return sub, backlog, nil
+	}
+
+	replayCtx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+	replayed, err := s.storage.QueryLogs(replayCtx, filter)
+	if err != nil {
+		sub.Close()
+		s.logger.WithError(err).Error("Failed to query logs to replay")
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil, status.Errorf(codes.DeadlineExceeded, "replay took longer than %s, ask for fewer logs", replayTimeout)
+		}
+		return nil, nil, err
+	}
+
+	// Logs stored while the replay ran are delivered live as well. Waiting
+	// out the inserts in progress ensures those already queried have been.
+	s.publishMu.Lock()
+	live := sub.Delivered()
+	s.publishMu.Unlock()
+
+	// Replayed logs precede the subscription, so each resumes where it started
+	backlog = make([]tail.Event, 0, len(replayed))
+	for i := len(replayed) - 1; i >= 0; i-- {
+		if filter.Matches(replayed[i]) && !live[replayed[i].ID] {
+			backlog = append(backlog, tail.Event{Seq: sub.Start(), Entry: replayed[i]})
+		}
+	}
+	return sub, backlog, nil
+}
+
+// register subscribes to the hub while inserts are held off, so that every
+// log is either stored before the subscription or delivered to it
+func (s *LogAggregatorServer) register(filter storage.QueryFilter, cursor string) (*tail.Subscription, []tail.Event, error) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+	return s.hub.Subscribe(filter, cursor)
+}
+
+// sendTailEvent sends a published log in its protobuf form
+func (s *LogAggregatorServer) sendTailEvent(stream grpc.ServerStreamingServer[pb.TailEvent], event tail.Event) error {
+	converted, err := logEvent(event.Entry)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to convert tailed log")
+		return err
+	}
+	return stream.Send(&pb.TailEvent{Event: converted, Cursor: s.hub.Cursor(event.Seq)})
+}
+
+// tailFilter converts a tail request into a storage filter whose limit is
+// the number of logs to replay
+func tailFilter(req *pb.TailRequest) (storage.QueryFilter, error) {
+	query := req.GetFilter()
+	if query == nil {
+		query = &pb.QueryRequest{}
+	}
+	if query.PageToken != "" {
+		return storage.QueryFilter{}, fmt.Errorf("page tokens do not apply to a tail")
+	}
+	if query.Rank {
+		return storage.QueryFilter{}, fmt.Errorf("relevance ranking does not apply to a tail")
+	}
+	if req.Replay < 0 || req.Replay > MaxPageSize {
+		return storage.QueryFilter{}, fmt.Errorf("replay must be between 0 and %d, got %d", MaxPageSize, req.Replay)
+	}
+
+	filter, _, err := queryFilter(query)
+	if err != nil {
+		return filter, err
+	}
+	filter.Ascending = false
+	filter.Limit = int(req.Replay)
+	return filter, nil
+}
+
+// tailError converts the reason a subscription ended into a gRPC status
+func tailError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, tail.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, tail.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, tail.ErrHubClosed):
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/worker/server"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tailStream captures the events sent on a TailLogs stream
+type tailStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.TailEvent
+}
+
+func newTailStream(ctx context.Context) *tailStream {
+	return &tailStream{ctx: ctx, events: make(chan *pb.TailEvent, 100)}
+}
+
+func (s *tailStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *tailStream) Send(event *pb.TailEvent) error {
+	s.events <- event
+	return nil
+}
+
+func (s *tailStream) next(t *testing.T) string {
+	t.Helper()
+	return s.nextEvent(t).Event.Message
+}
+
+func (s *tailStream) nextEvent(t *testing.T) *pb.TailEvent {
+	t.Helper()
+	select {
+	case event := <-s.events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a tailed log")
+	}
+	return nil
+}
+
+// looseSearch is a backend whose text search matches more than the
+// substring matching of live tails
+type looseSearch struct {
+	*storage.MemoryStorage
+}
+
+func (s looseSearch) QueryLogs(ctx context.Context, filter storage.QueryFilter) ([]storage.LogEntry, error) {
+	filter.Text = ""
+	return s.MemoryStorage.QueryLogs(ctx, filter)
+}
+
+// insertDuringQuery is a backend that stores a log through the server
+// while a replay query runs
+type insertDuringQuery struct {
+	*storage.MemoryStorage
+	insert func()
+}
+
+func (s insertDuringQuery) QueryLogs(ctx context.Context, filter storage.QueryFilter) ([]storage.LogEntry, error) {
+	if s.insert != nil {
+		s.insert()
+	}
+	return s.MemoryStorage.QueryLogs(ctx, filter)
+}
+
+// startTail runs TailLogs in the background and returns its stream and result
+func startTail(t *testing.T, srv *server.LogAggregatorServer, req *pb.TailRequest) (*tailStream, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newTailStream(ctx)
+	result := make(chan error, 1)
+	go func() { result <- srv.TailLogs(req, stream) }()
+	return stream, cancel, result
+}
+
+func sendLog(t *testing.T, srv *server.LogAggregatorServer, service, message string) {
+	t.Helper()
+	_, err := srv.SendLogs(context.Background(), &pb.LogBatch{Events: []*pb.LogEvent{{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Service:   service,
+		Level:     "info",
+		Message:   message,
+	}}})
+	require.NoError(t, err)
+}
+
+func TestLogAggregatorServer_TailLogs(t *testing.T) {
+	t.Run("replays then streams matching logs", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 5)
+
+		stream, cancel, result := startTail(t, srv, &pb.TailRequest{
+			Filter: &pb.QueryRequest{Service: "api"},
+			Replay: 2,
+		})
+		assert.Equal(t, "log 3", stream.next(t))
+		replayed := stream.nextEvent(t).Event
+		assert.Equal(t, "log 4", replayed.Message)
+
+		sendLog(t, srv, "db", "ignored")
+		sendLog(t, srv, "api", "live")
+		live := stream.nextEvent(t).Event
+		assert.Equal(t, "live", live.Message)
+		assert.Greater(t, live.Id, replayed.Id, "live logs carry their storage id")
+
+		cancel()
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("replays only logs that match live", func(t *testing.T) {
+		store := looseSearch{storage.NewMemoryStorage()}
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		srv := server.NewLogAggregatorServer(store, logger)
+		require.NoError(t, store.InsertLogs(context.Background(), []storage.LogEntry{
+			{Timestamp: time.Now().Add(-time.Minute), Service: "api", Level: "info", Message: "connection timeout"},
+			{Timestamp: time.Now(), Service: "api", Level: "info", Message: "all good"},
+		}))
+
+		stream, cancel, result := startTail(t, srv, &pb.TailRequest{
+			Filter: &pb.QueryRequest{Text: "timeout"},
+			Replay: 10,
+		})
+		assert.Equal(t, "connection timeout", stream.next(t))
+		sendLog(t, srv, "api", "timeout again")
+		assert.Equal(t, "timeout again", stream.next(t))
+		cancel()
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("inserts proceed during a replay without duplicating logs", func(t *testing.T) {
+		store := &insertDuringQuery{MemoryStorage: storage.NewMemoryStorage()}
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		srv := server.NewLogAggregatorServer(store, logger)
+		seedLogs(t, store, 1)
+		store.insert = func() { sendLog(t, srv, "api", "during replay") }
+
+		stream, cancel, result := startTail(t, srv, &pb.TailRequest{Replay: 10})
+		assert.Equal(t, "log 0", stream.next(t))
+		assert.Equal(t, "during replay", stream.next(t))
+		store.insert = nil
+		sendLog(t, srv, "api", "live")
+		assert.Equal(t, "live", stream.next(t))
+		cancel()
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("resumes after a cursor", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 1)
+
+		// Waiting for the replayed log ensures the tail is subscribed
+		stream, cancel, result := startTail(t, srv, &pb.TailRequest{Replay: 1})
+		assert.Equal(t, "log 0", stream.next(t))
+		sendLog(t, srv, "api", "first")
+		cursor := stream.nextEvent(t).Cursor
+		require.NotEmpty(t, cursor)
+		cancel()
+		require.NoError(t, <-result)
+
+		sendLog(t, srv, "api", "missed")
+		stream, cancel, result = startTail(t, srv, &pb.TailRequest{Cursor: cursor, Replay: 10})
+		assert.Equal(t, "missed", stream.next(t))
+		sendLog(t, srv, "api", "live")
+		assert.Equal(t, "live", stream.next(t))
+		cancel()
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("replays when the cursor has expired", func(t *testing.T) {
+		srv, store := newTestServer(t)
+		seedLogs(t, store, 3)
+
+		// A cursor issued before a restart has expired
+		restarted, restartedStore := newTestServer(t)
+		seedLogs(t, restartedStore, 1)
+		stream, cancel, result := startTail(t, restarted, &pb.TailRequest{Replay: 1})
+		cursor := stream.nextEvent(t).Cursor
+		cancel()
+		require.NoError(t, <-result)
+
+		stream, cancel, result = startTail(t, srv, &pb.TailRequest{Cursor: cursor, Replay: 1})
+		assert.Equal(t, "log 2", stream.next(t))
+		cancel()
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("stopping the server ends the stream", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+		_, cancel, result := startTail(t, srv, &pb.TailRequest{})
+		defer cancel()
+
+		srv.StopTailing()
+
+		err := <-result
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		srv, _ := newTestServer(t)
+		for _, req := range []*pb.TailRequest{
+			{Replay: -1},
+			{Replay: server.MaxPageSize + 1},
+			{Filter: &pb.QueryRequest{PageToken: "token"}},
+			{Filter: &pb.QueryRequest{Text: "timeout", Rank: true}},
+			{Filter: &pb.QueryRequest{StartTime: "yesterday"}},
+			{Cursor: "garbage"},
+		} {
+			err := srv.TailLogs(req, newTailStream(context.Background()))
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), "request %v", req)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/jgfranco17/echoris/service/protos"
+	"github.com/jgfranco17/echoris/service/protos/eventfields"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+)
+
+const (
+	MaxServiceLength   = 128
+	MaxLevelLength     = 32
+	MaxMessageBytes    = 64 * 1024
+	MaxFields          = 64
+	MaxFieldKeyLength  = 128
+	MaxFieldValueBytes = 8 * 1024
+
+	// MaxReportedErrors caps the per-event errors returned by one call
+	MaxReportedErrors = 1000
+)
+
+// validateEvent checks a log event received at receivedAt and converts it
+// into its stored form
+func validateEvent(e *pb.LogEvent, receivedAt time.Time) (storage.LogEntry, error) {
+	timestamp, err := eventTime(e)
+	if err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	if err := validateText("service", e.Service, MaxServiceLength); err != nil {
+		return storage.LogEntry{}, err
+	}
+	if err := validateText("level", e.Level, MaxLevelLength); err != nil {
+		return storage.LogEntry{}, err
+	}
+	if err := validateText("message", e.Message, MaxMessageBytes); err != nil {
+		return storage.LogEntry{}, err
+	}
+	fields := eventfields.Get(e)
+	if err := validateFields(fields); err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	return storage.LogEntry{
+		Timestamp: timestamp,
+		Service:   e.Service,
+		Level:     e.Level,
+		Message:   e.Message,
+		Fields:    fields,
+		CreatedAt: receivedAt,
+	}, nil
+}
+
+// eventTime returns when e happened, preferring time over the legacy
+// timestamp string sent by older clients
+func eventTime(e *pb.LogEvent) (time.Time, error) {
+	if e.Time != nil {
+		if err := e.Time.CheckValid(); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time: %w", err)
+		}
+		return e.Time.AsTime(), nil
+	}
+	if e.Timestamp == "" {
+		return time.Time{}, errors.New("timestamp is required")
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q, expected RFC 3339", e.Timestamp)
+	}
+	return timestamp, nil
+}
+
+// validateText checks a required string attribute of an event. NUL bytes
+// are rejected because PostgreSQL cannot store them.
+func validateText(name, value string, maxBytes int) error {
+	switch {
+	case value == "":
+		return fmt.Errorf("%s is required", name)
+	case len(value) > maxBytes:
+		return fmt.Errorf("%s exceeds %d bytes", name, maxBytes)
+	case strings.ContainsRune(value, 0):
+		return fmt.Errorf("%s must not contain NUL bytes", name)
+	}
+	return nil
+}
+
+// validateFields checks the keys of fields and the size of each value
+// encoded as JSON
+func validateFields(fields map[string]any) error {
+	if len(fields) > MaxFields {
+		return fmt.Errorf("too many fields, at most %d are allowed", MaxFields)
+	}
+	for key, value := range fields {
+		if err := validateText("field key", key, MaxFieldKeyLength); err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("field %q is not a valid JSON value", key)
+		}
+		if len(encoded) > MaxFieldValueBytes {
+			return fmt.Errorf("field %q exceeds %d bytes", key, MaxFieldValueBytes)
+		}
+		if containsNUL(value) {
+			return fmt.Errorf("field %q must not contain NUL bytes", key)
+		}
+	}
+	return nil
+}
+
+// containsNUL reports whether a field value holds a NUL byte in any of its
+// strings or nested keys
+func containsNUL(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.ContainsRune(v, 0)
+	case []any:
+		return slices.ContainsFunc(v, containsNUL)
+	case map[string]any:
+		for key, element := range v {
+			if strings.ContainsRune(key, 0) || containsNUL(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ingestReport tallies the outcome of the events of one call
+type ingestReport struct {
+	accepted int64
+	rejected int64
+	errors   []*pb.EventError
+}
+
+// reject records that the event at index was rejected for err
+func (r *ingestReport) reject(index int64, err error) {
+	r.rejected++
+	if len(r.errors) < MaxReportedErrors {
+		r.errors = append(r.errors, &pb.EventError{Index: index, Reason: err.Error()})
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Dimensions that aggregations group logs by. A field is grouped by
+// GroupByFieldPrefix followed by its key.
+const (
+	GroupByService     = "service"
+	GroupByLevel       = "level"
+	GroupByFieldPrefix = "fields."
+)
+
+// AggregateQuery counts the logs matching Filter, grouped by the values of
+// GroupBy and by time buckets Interval wide
+type AggregateQuery struct {
+	Filter   QueryFilter   // Only its conditions apply; order, cursor and paging are ignored
+	GroupBy  []string      // GroupByService, GroupByLevel or GroupByFieldPrefix and a key
+	Interval time.Duration // Bucket width in whole seconds, aligned to the Unix epoch; zero for none
+	Limit    int           // Maximum number of buckets; zero for all
+}
+
+// Bucket is the number of logs sharing a time bucket and group. Buckets
+// are ordered by start and then by group values in GroupBy order, with
+// missing fields first.
+type Bucket struct {
+	Start time.Time         // Zero when the query has no interval
+	Group map[string]string // Value of each dimension; missing fields are left out
+	Count int64
+}
+
+// Validate checks the parts of the query that can be malformed
+func (q AggregateQuery) Validate() error {
+	if err := q.Filter.Validate(); err != nil {
+		return err
+	}
+	if q.Interval < 0 || q.Interval%time.Second != 0 {
+		return fmt.Errorf("interval must be a whole number of seconds, got %s", q.Interval)
+	}
+	for i, dimension := range q.GroupBy {
+		key, isField := strings.CutPrefix(dimension, GroupByFieldPrefix)
+		if dimension != GroupByService && dimension != GroupByLevel && (!isField || key == "") {
+			return fmt.Errorf("cannot group by %q, expected service, level or fields.<key>", dimension)
+		}
+		if slices.Contains(q.GroupBy[:i], dimension) {
+			return fmt.Errorf("cannot group by %q more than once", dimension)
+		}
+	}
+	return nil
+}
+
+// selection returns the filter's conditions without its order, cursor and
+// paging
+func (q AggregateQuery) selection() QueryFilter {
+	filter := q.Filter
+	filter.Rank, filter.Ascending, filter.After = false, false, nil
+	filter.Limit, filter.Offset = 0, 0
+	return filter
+}
+
+// groupValue returns the value of dimension for entry. Fields are grouped
+// by their text, with values other than strings written as JSON.
+func groupValue(entry LogEntry, dimension string) (string, bool) {
+	switch dimension {
+	case GroupByService:
+		return entry.Service, true
+	case GroupByLevel:
+		return entry.Level, true
+	}
+	value := entry.Fields[strings.TrimPrefix(dimension, GroupByFieldPrefix)]
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value), true
+	}
+	return string(encoded), true
+}
+
+// bucketStart returns the start of the interval-wide bucket holding t
+func bucketStart(t time.Time, interval time.Duration) time.Time {
+	width := int64(interval / time.Second)
+	seconds := t.Unix()
+	start := seconds - seconds%width
+	if seconds%width < 0 {
+		start -= width
+	}
+	return time.Unix(start, 0).UTC()
+}
+
+// aggregator counts logs for the backends that scan them in Go
+type aggregator struct {
+	query   AggregateQuery
+	buckets map[string]*Bucket
+}
+
+func newAggregator(query AggregateQuery) *aggregator {
+	return &aggregator{query: query, buckets: make(map[string]*Bucket)}
+}
+
+// add counts entry in its bucket
+func (a *aggregator) add(entry LogEntry) {
+	bucket := Bucket{Group: make(map[string]string, len(a.query.GroupBy))}
+	if a.query.Interval > 0 {
+		bucket.Start = bucketStart(entry.Timestamp, a.query.Interval)
+	}
+	for _, dimension := range a.query.GroupBy {
+		if value, ok := groupValue(entry, dimension); ok {
+			bucket.Group[dimension] = value
+		}
+	}
+
+	key, _ := json.Marshal(bucket)
+	if existing, ok := a.buckets[string(key)]; ok {
+		existing.Count++
+		return
+	}
+	bucket.Count = 1
+	a.buckets[string(key)] = &bucket
+}
+
+// result returns the buckets in order, up to the query's limit
+func (a *aggregator) result() []Bucket {
+	buckets := make([]Bucket, 0, len(a.buckets))
+	for _, bucket := range a.buckets {
+		buckets = append(buckets, *bucket)
+	}
+	slices.SortFunc(buckets, a.compare)
+	if a.query.Limit > 0 && len(buckets) > a.query.Limit {
+		buckets = buckets[:a.query.Limit]
+	}
+	return buckets
+}
+
+func (a *aggregator) compare(x, y Bucket) int {
+	if order := x.Start.Compare(y.Start); order != 0 {
+		return order
+	}
+	for _, dimension := range a.query.GroupBy {
+		xValue, xOK := x.Group[dimension]
+		yValue, yOK := y.Group[dimension]
+		if xOK != yOK {
+			if xOK {
+				return 1
+			}
+			return -1
+		}
+		if order := cmp.Compare(xValue, yValue); order != 0 {
+			return order
+		}
+	}
+	return 0
+}
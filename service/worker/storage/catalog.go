@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CatalogKind is what a catalog lists the distinct values of
+type CatalogKind string
+
+const (
+	CatalogServices  CatalogKind = "service"
+	CatalogLevels    CatalogKind = "level"
+	CatalogFieldKeys CatalogKind = "field_key" // Top-level keys of fields
+)
+
+// CatalogBucket is the granularity of catalog windows. Windows are
+// widened to whole buckets, so the PostgreSQL catalog can keep one count
+// per value and bucket.
+const CatalogBucket = time.Hour
+
+// CatalogQuery lists the distinct values of Kind among logs in a window
+type CatalogQuery struct {
+	Kind  CatalogKind
+	Start *time.Time // Only logs in or after the bucket holding Start
+	End   *time.Time // Only logs in or before the bucket holding End
+	Limit int        // Maximum number of values; zero for all
+}
+
+// CatalogEntry is a distinct value with the number of logs carrying it
+// and the timestamps of the first and last of them, in the query's window
+type CatalogEntry struct {
+	Value     string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Validate checks the parts of the query that can be malformed
+func (q CatalogQuery) Validate() error {
+	switch q.Kind {
+	case CatalogServices, CatalogLevels, CatalogFieldKeys:
+	default:
+		return fmt.Errorf("unknown catalog kind %q", q.Kind)
+	}
+	if q.Start != nil && q.End != nil && q.Start.After(*q.End) {
+		return fmt.Errorf("start %s is after end %s", q.Start.Format(time.RFC3339), q.End.Format(time.RFC3339))
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", q.Limit)
+	}
+	return nil
+}
+
+// window returns the filter selecting the logs in the query's window,
+// widened to whole buckets
+func (q CatalogQuery) window() QueryFilter {
+	var filter QueryFilter
+	if q.Start != nil {
+		start := catalogBucket(*q.Start)
+		filter.StartTime = &start
+	}
+	if q.End != nil {
+		end := catalogBucket(*q.End).Add(CatalogBucket - time.Nanosecond)
+		filter.EndTime = &end
+	}
+	return filter
+}
+
+// catalogBucket returns the start of the bucket holding t
+func catalogBucket(t time.Time) time.Time {
+	return t.UTC().Truncate(CatalogBucket)
+}
+
+// catalogValues returns the values entry adds to a catalog of kind
+func catalogValues(entry LogEntry, kind CatalogKind) []string {
+	switch kind {
+	case CatalogServices:
+		return []string{entry.Service}
+	case CatalogLevels:
+		return []string{entry.Level}
+	case CatalogFieldKeys:
+		keys := make([]string, 0, len(entry.Fields))
+		for key := range entry.Fields {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	return nil
+}
+
+// cataloger counts distinct values for the backends that scan logs in Go
+type cataloger struct {
+	query   CatalogQuery
+	entries map[string]*CatalogEntry
+}
+
+func newCataloger(query CatalogQuery) *cataloger {
+	return &cataloger{query: query, entries: make(map[string]*CatalogEntry)}
+}
+
+// add counts a log with the given timestamp carrying value
+func (c *cataloger) add(value string, timestamp time.Time) {
+	timestamp = timestamp.UTC()
+	existing, ok := c.entries[value]
+	if !ok {
+		c.entries[value] = &CatalogEntry{Value: value, Count: 1, FirstSeen: timestamp, LastSeen: timestamp}
+		return
+	}
+	existing.Count++
+	if timestamp.Before(existing.FirstSeen) {
+		existing.FirstSeen = timestamp
+	}
+	if timestamp.After(existing.LastSeen) {
+		existing.LastSeen = timestamp
+	}
+}
+
+// result returns the values in bytewise order, up to the query's limit
+func (c *cataloger) result() []CatalogEntry {
+	entries := make([]CatalogEntry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, *entry)
+	}
+	slices.SortFunc(entries, func(x, y CatalogEntry) int { return strings.Compare(x.Value, y.Value) })
+	if c.query.Limit > 0 && len(entries) > c.query.Limit {
+		entries = entries[:c.query.Limit]
+	}
+	return entries
+}
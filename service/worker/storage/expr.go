@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/jgfranco17/echoris/service/querylang"
+)
+
+// termFilters maps the operators of terms on fields to field filters
+var termFilters = map[querylang.Op]FieldOp{
+	querylang.OpEquals:         FieldEquals,
+	querylang.OpPrefix:         FieldPrefix,
+	querylang.OpExists:         FieldExists,
+	querylang.OpGreater:        FieldGreater,
+	querylang.OpGreaterOrEqual: FieldGreaterOrEqual,
+	querylang.OpLess:           FieldLess,
+	querylang.OpLessOrEqual:    FieldLessOrEqual,
+}
+
+// fieldTerm returns the field filter equivalent to a term on a field, so
+// that both match values the same way
+func fieldTerm(term *querylang.Term) (FieldFilter, bool) {
+	key, ok := term.Field()
+	if !ok {
+		return FieldFilter{}, false
+	}
+	filter := FieldFilter{Key: key, Op: termFilters[term.Op]}
+	if term.Op != querylang.OpExists {
+		filter.Values = []string{term.Value}
+	}
+	return filter, true
+}
+
+// matchesExpr reports whether entry satisfies expr. It is the plan of a
+// query for backends that scan logs in Go; see exprCondition for SQL.
+func matchesExpr(entry LogEntry, expr querylang.Expr) bool {
+	switch e := expr.(type) {
+	case *querylang.And:
+		return matchesExpr(entry, e.Left) && matchesExpr(entry, e.Right)
+	case *querylang.Or:
+		return matchesExpr(entry, e.Left) || matchesExpr(entry, e.Right)
+	case *querylang.Not:
+		return !matchesExpr(entry, e.Expr)
+	case *querylang.Term:
+		return matchesTerm(entry, e)
+	}
+	return false
+}
+
+func matchesTerm(entry LogEntry, term *querylang.Term) bool {
+	if filter, ok := fieldTerm(term); ok {
+		return filter.Matches(entry.Fields)
+	}
+
+	var value string
+	switch term.Key {
+	case querylang.KeyService:
+		value = entry.Service
+	case querylang.KeyLevel:
+		value = entry.Level
+	case querylang.KeyMessage:
+		value = entry.Message
+	default:
+		return false
+	}
+
+	switch term.Op {
+	case querylang.OpEquals:
+		return value == term.Value
+	case querylang.OpPrefix:
+		return strings.HasPrefix(value, term.Value)
+	case querylang.OpMatches:
+		return matchesText(value, term.Value)
+	}
+	return false
+}
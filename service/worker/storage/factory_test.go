@@ -0,0 +1,107 @@
+package storage_test
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStorage_DispatchesOnScheme(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+
+	tcases := []struct {
+		name     string
+		conn     string
+		expected storage.Storage
+	}{{
+		name:     "memory",
+		conn:     "memory://",
+		expected: &storage.MemoryStorage{},
+	}, {
+		name:     "file with options",
+		conn:     "file://" + dir + "?max_segment_bytes=1024&compaction_ratio=0.25&sync=false",
+		expected: &storage.FileStorage{},
+	}, {
+		name:     "scheme is case insensitive",
+		conn:     "MEMORY://",
+		expected: &storage.MemoryStorage{},
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := storage.NewStorage(storage.Config{ConnString: tc.conn})
+			require.NoError(t, err)
+			defer store.Close()
+			assert.IsType(t, tc.expected, store)
+			assert.NoError(t, store.HealthCheck(context.Background()))
+		})
+	}
+
+	_, err := os.Stat(dir)
+	assert.NoError(t, err, "file backend should create its directory")
+}
+
+func TestNewStorage_Errors(t *testing.T) {
+	tcases := []struct {
+		name          string
+		conn          string
+		expectedError string
+	}{{
+		name:          "empty",
+		conn:          "",
+		expectedError: "connection string is empty",
+	}, {
+		name:          "unknown scheme",
+		conn:          "mongodb://localhost",
+		expectedError: `unsupported storage scheme "mongodb"`,
+	}, {
+		name:          "invalid option value",
+		conn:          "file:///tmp/echoris?sync=sometimes",
+		expectedError: `invalid value "sometimes" for storage option sync`,
+	}, {
+		name:          "unknown option",
+		conn:          "memory://?size=10",
+		expectedError: "unknown storage options: size",
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := storage.NewStorage(storage.Config{ConnString: tc.conn})
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	called := false
+	storage.Register("test-register", func(u *url.URL) (storage.Storage, error) {
+		called = true
+		assert.Equal(t, "bucket", u.Host)
+		return storage.NewMemoryStorage(), nil
+	})
+
+	assert.Contains(t, storage.Schemes(), "test-register")
+	assert.Panics(t, func() {
+		storage.Register("test-register", func(u *url.URL) (storage.Storage, error) { return nil, nil })
+	})
+
+	store, err := storage.NewStorage(storage.Config{ConnString: "test-register://bucket"})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.True(t, called)
+}
+
+func TestNewStorageFromEnv_UsesDatabaseURL(t *testing.T) {
+	t.Setenv("DATABASE_URL", "memory://")
+
+	store, err := storage.NewStorageFromEnv()
+	require.NoError(t, err)
+	defer store.Close()
+	assert.IsType(t, &storage.MemoryStorage{}, store)
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldOp is a comparison applied to one key of a log entry's Fields
+type FieldOp string
+
+const (
+	FieldEquals         FieldOp = "eq"         // Key is present with the value
+	FieldNotEquals      FieldOp = "ne"         // Key is absent or has another value
+	FieldExists         FieldOp = "exists"     // Key is present
+	FieldNotExists      FieldOp = "not_exists" // Key is absent
+	FieldPrefix         FieldOp = "prefix"     // Key is a string starting with the value
+	FieldIn             FieldOp = "in"         // Key is present with one of the values
+	FieldGreater        FieldOp = "gt"         // Key is a number greater than the value
+	FieldGreaterOrEqual FieldOp = "gte"        // Key is a number greater than or equal to the value
+	FieldLess           FieldOp = "lt"         // Key is a number less than the value
+	FieldLessOrEqual    FieldOp = "lte"        // Key is a number less than or equal to the value
+)
+
+// FieldFilter matches log entries on one key of their Fields. Values are
+// written as text: a value equals a string with that text and, when the
+// text is a number or boolean, a number or boolean with that value.
+type FieldFilter struct {
+	Key    string
+	Op     FieldOp
+	Values []string
+}
+
+// Validate checks that the filter names a key and carries as many values
+// as its operator needs
+func (f FieldFilter) Validate() error {
+	if f.Key == "" {
+		return fmt.Errorf("field filter needs a key")
+	}
+
+	switch f.Op {
+	case FieldEquals, FieldNotEquals, FieldPrefix:
+		if len(f.Values) != 1 {
+			return fmt.Errorf("field filter %s %s needs exactly one value, got %d", f.Key, f.Op, len(f.Values))
+		}
+	case FieldExists, FieldNotExists:
+		if len(f.Values) != 0 {
+			return fmt.Errorf("field filter %s %s takes no values, got %d", f.Key, f.Op, len(f.Values))
+		}
+	case FieldIn:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("field filter %s %s needs at least one value", f.Key, f.Op)
+		}
+	case FieldGreater, FieldGreaterOrEqual, FieldLess, FieldLessOrEqual:
+		if len(f.Values) != 1 {
+			return fmt.Errorf("field filter %s %s needs exactly one value, got %d", f.Key, f.Op, len(f.Values))
+		}
+		if _, ok := parseNumber(f.Values[0]); !ok {
+			return fmt.Errorf("field filter %s %s needs a number, got %q", f.Key, f.Op, f.Values[0])
+		}
+	default:
+		return fmt.Errorf("unknown field filter operator %q", f.Op)
+	}
+	return nil
+}
+
+// Matches reports whether fields satisfy the filter
+func (f FieldFilter) Matches(fields map[string]any) bool {
+	value, ok := fields[f.Key]
+
+	switch f.Op {
+	case FieldEquals:
+		return ok && equalsText(value, f.Values[0])
+	case FieldNotEquals:
+		return !ok || !equalsText(value, f.Values[0])
+	case FieldExists:
+		return ok
+	case FieldNotExists:
+		return !ok
+	case FieldPrefix:
+		text, isString := value.(string)
+		return isString && strings.HasPrefix(text, f.Values[0])
+	case FieldIn:
+		return ok && slices.ContainsFunc(f.Values, func(text string) bool { return equalsText(value, text) })
+	case FieldGreater, FieldGreaterOrEqual, FieldLess, FieldLessOrEqual:
+		return ok && f.compare(value)
+	}
+	return false
+}
+
+// compare applies a comparison operator to value, which only numbers satisfy
+func (f FieldFilter) compare(value any) bool {
+	number, ok := numberValue(value)
+	if !ok {
+		return false
+	}
+	bound, _ := parseNumber(f.Values[0])
+
+	switch f.Op {
+	case FieldGreater:
+		return number > bound
+	case FieldGreaterOrEqual:
+		return number >= bound
+	case FieldLess:
+		return number < bound
+	case FieldLessOrEqual:
+		return number <= bound
+	}
+	return false
+}
+
+func matchesFields(fields map[string]any, filters []FieldFilter) bool {
+	for _, filter := range filters {
+		if !filter.Matches(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// textValues returns the field values that a filter value written as text
+// equals: the string itself, and the number or boolean it spells
+func textValues(text string) []any {
+	values := []any{text}
+	if number, ok := parseNumber(text); ok {
+		values = append(values, number)
+	}
+	if text == "true" || text == "false" {
+		values = append(values, text == "true")
+	}
+	return values
+}
+
+// equalsText reports whether a field value equals a filter value written as text
+func equalsText(value any, text string) bool {
+	for _, candidate := range textValues(text) {
+		switch c := candidate.(type) {
+		case string:
+			if s, ok := value.(string); ok && s == c {
+				return true
+			}
+		case float64:
+			if n, ok := numberValue(value); ok && n == c {
+				return true
+			}
+		case bool:
+			if b, ok := value.(bool); ok && b == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseNumber parses a finite decimal number
+func parseNumber(text string) (float64, bool) {
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsInf(number, 0) || math.IsNaN(number) || strings.ContainsAny(text, "xX_") {
+		return 0, false
+	}
+	return number, true
+}
+
+// numberValue returns a numeric field value as a float64. Values decoded
+// from JSON are float64; the integer types cover entries built in Go.
+func numberValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
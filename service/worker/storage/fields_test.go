@@ -0,0 +1,40 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldFilter_Validate(t *testing.T) {
+	valid := []storage.FieldFilter{
+		{Key: "user", Op: storage.FieldEquals, Values: []string{"alice"}},
+		{Key: "user", Op: storage.FieldNotEquals, Values: []string{"alice"}},
+		{Key: "user", Op: storage.FieldExists},
+		{Key: "user", Op: storage.FieldNotExists},
+		{Key: "user", Op: storage.FieldPrefix, Values: []string{"al"}},
+		{Key: "user", Op: storage.FieldIn, Values: []string{"alice", "bob"}},
+		{Key: "latency_ms", Op: storage.FieldGreater, Values: []string{"500"}},
+		{Key: "latency_ms", Op: storage.FieldLessOrEqual, Values: []string{"-1.5e2"}},
+	}
+	for _, filter := range valid {
+		assert.NoError(t, filter.Validate(), filter.Op)
+	}
+
+	invalid := []storage.FieldFilter{
+		{Op: storage.FieldExists},
+		{Key: "user", Op: storage.FieldEquals},
+		{Key: "user", Op: storage.FieldPrefix, Values: []string{"a", "b"}},
+		{Key: "user", Op: storage.FieldExists, Values: []string{"alice"}},
+		{Key: "user", Op: storage.FieldIn},
+		{Key: "user", Op: "like", Values: []string{"a"}},
+		{Key: "latency_ms", Op: storage.FieldGreater},
+		{Key: "latency_ms", Op: storage.FieldLess, Values: []string{"slow"}},
+		{Key: "latency_ms", Op: storage.FieldLess, Values: []string{"NaN"}},
+		{Key: "latency_ms", Op: storage.FieldLess, Values: []string{"0x10"}},
+	}
+	for _, filter := range invalid {
+		assert.Error(t, filter.Validate(), filter)
+	}
+}
@@ -0,0 +1,1025 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSegmentBytes is the size after which the active segment is sealed
+	DefaultMaxSegmentBytes int64 = 64 << 20
+
+	// DefaultCompactionRatio is the live-record fraction below which a sealed
+	// segment is rewritten after logs are deleted
+	DefaultCompactionRatio = 0.5
+
+	segmentFilePrefix = "segment-"
+	segmentFileSuffix = ".log"
+	compactFileSuffix = ".compact"
+
+	recordHeaderSize = 8
+	maxRecordBytes   = 16 << 20
+
+	recordTypeEntry = "entry"
+	recordTypePurge = "purge"
+)
+
+var (
+	errTornRecord    = errors.New("incomplete record")
+	errCorruptRecord = errors.New("corrupt record")
+)
+
+func init() {
+	Register("file", newFileStorageFromURL)
+}
+
+// FileOptions configures the file-backed storage engine
+type FileOptions struct {
+	Dir             string  // Directory holding the segment files
+	MaxSegmentBytes int64   // Size at which the active segment is sealed
+	CompactionRatio float64 // Live fraction below which sealed segments are rewritten
+	SyncWrites      bool    // Fsync the active segment after every write
+}
+
+// fileRecord is the JSON payload of a single record in a segment.
+// Entry records carry a log entry; purge records delete every entry
+// written before them whose creation time precedes Before and, when a
+// Scope is present, whose service and level fall within it.
+type fileRecord struct {
+	Type      string         `json:"type"`
+	ID        int64          `json:"id,omitempty"`
+	Timestamp time.Time      `json:"timestamp,omitempty"`
+	Service   string         `json:"service,omitempty"`
+	Level     string         `json:"level,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	CreatedAt time.Time      `json:"created_at,omitempty"`
+	Before    *time.Time     `json:"before,omitempty"`
+	Scope     *purgeScope    `json:"scope,omitempty"`
+}
+
+// purgeScope is the persisted service and level scope of a purge record
+type purgeScope struct {
+	Services        []string `json:"services,omitempty"`
+	Levels          []string `json:"levels,omitempty"`
+	ExcludeServices []string `json:"exclude_services,omitempty"`
+	ExcludeLevels   []string `json:"exclude_levels,omitempty"`
+}
+
+func newPurgeScope(filter DeleteFilter) *purgeScope {
+	if !filter.Scoped() {
+		return nil
+	}
+	return &purgeScope{
+		Services:        filter.Services,
+		Levels:          filter.Levels,
+		ExcludeServices: filter.ExcludeServices,
+		ExcludeLevels:   filter.ExcludeLevels,
+	}
+}
+
+func (p *purgeScope) filter() DeleteFilter {
+	if p == nil {
+		return DeleteFilter{}
+	}
+	return DeleteFilter{
+		Services:        p.Services,
+		Levels:          p.Levels,
+		ExcludeServices: p.ExcludeServices,
+		ExcludeLevels:   p.ExcludeLevels,
+	}
+}
+
+// segment is one append-only file of records
+type segment struct {
+	seq   uint64
+	path  string
+	file  *os.File
+	size  int64
+	total int // entry records physically present in the file
+	live  int // entry records still visible to queries
+}
+
+// recordPointer locates an entry on disk and carries the columns
+// needed to answer index lookups without reading the record
+type recordPointer struct {
+	id        int64
+	timestamp time.Time
+	service   string
+	level     string
+	seg       *segment
+	offset    int64
+	length    int64
+}
+
+// FileStorage implements Storage interface as an append-only segment log
+// on local disk. Entries are located through an in-memory time index and
+// per-service index that are rebuilt from the segments on startup.
+type FileStorage struct {
+	mu           sync.RWMutex
+	opts         FileOptions
+	segments     []*segment
+	timeIndex    []*recordPointer
+	serviceIndex map[string][]*recordPointer
+	byID         map[int64]*recordPointer
+	nextID       int64
+	closed       bool
+	now          func() time.Time
+}
+
+// newFileStorageFromURL opens the directory named by a file:// URL, reading
+// the max_segment_bytes, compaction_ratio and sync query parameters.
+// Relative directories are written as file://./data or file:data.
+func newFileStorageFromURL(u *url.URL) (Storage, error) {
+	dir := u.Host + u.Path
+	if u.Opaque != "" {
+		dir = u.Opaque
+	}
+
+	params := newURLOptions(u)
+	opts := FileOptions{
+		Dir:             dir,
+		MaxSegmentBytes: params.Int64("max_segment_bytes", DefaultMaxSegmentBytes),
+		CompactionRatio: params.Float("compaction_ratio", DefaultCompactionRatio),
+		SyncWrites:      params.Bool("sync", true),
+	}
+	if err := params.Finish(); err != nil {
+		return nil, err
+	}
+
+	return NewFileStorage(opts)
+}
+
+// NewFileStorage opens the segment log in opts.Dir, creating it if needed,
+// and replays existing segments to rebuild the indexes. A record torn by
+// a crash at the tail of the newest segment is truncated away.
+func NewFileStorage(opts FileOptions) (*FileStorage, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("storage directory is required")
+	}
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = DefaultMaxSegmentBytes
+	}
+	if opts.CompactionRatio <= 0 {
+		opts.CompactionRatio = DefaultCompactionRatio
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	s := &FileStorage{
+		opts:         opts,
+		serviceIndex: make(map[string][]*recordPointer),
+		byID:         make(map[int64]*recordPointer),
+		nextID:       1,
+		now:          time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		s.closeSegments()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load discovers the segment files and replays them in sequence order
+func (s *FileStorage) load() error {
+	dirEntries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	var seqs []uint64
+	for _, d := range dirEntries {
+		name := d.Name()
+		if strings.HasSuffix(name, compactFileSuffix) {
+			// Leftover from a compaction interrupted before its rename
+			if err := os.Remove(filepath.Join(s.opts.Dir, name)); err != nil {
+				return fmt.Errorf("failed to remove stale compaction file: %w", err)
+			}
+			continue
+		}
+		if seq, ok := parseSegmentName(name); ok {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	for i, seq := range seqs {
+		seg, err := s.openSegment(seq, os.O_RDWR)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+		if err := s.replaySegment(seg, i == len(seqs)-1); err != nil {
+			return err
+		}
+	}
+
+	if len(s.segments) == 0 {
+		seg, err := s.openSegment(1, os.O_RDWR|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	return nil
+}
+
+// replaySegment applies every record of seg to the indexes
+func (s *FileStorage) replaySegment(seg *segment, active bool) error {
+	info, err := seg.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat segment %s: %w", seg.path, err)
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(seg.file, 0, info.Size()))
+	var offset int64
+	for {
+		rec, n, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !active {
+				return fmt.Errorf("failed to read segment %s at offset %d: %w", seg.path, offset, err)
+			}
+			// Crash recovery: drop the partially written tail
+			if err := seg.file.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to truncate segment %s: %w", seg.path, err)
+			}
+			break
+		}
+
+		s.applyRecord(rec, seg, offset, n)
+		offset += n
+	}
+	seg.size = offset
+
+	return nil
+}
+
+func (s *FileStorage) applyRecord(rec fileRecord, seg *segment, offset, length int64) {
+	switch rec.Type {
+	case recordTypeEntry:
+		s.index(&recordPointer{
+			id:        rec.ID,
+			timestamp: rec.Timestamp,
+			service:   rec.Service,
+			level:     rec.Level,
+			seg:       seg,
+			offset:    offset,
+			length:    length,
+		})
+		seg.total++
+		seg.live++
+		if rec.ID >= s.nextID {
+			s.nextID = rec.ID + 1
+		}
+	case recordTypePurge:
+		if rec.Before != nil {
+			s.purge(*rec.Before, rec.Scope.filter())
+		}
+	}
+}
+
+// InsertLog inserts a single log entry
+func (s *FileStorage) InsertLog(ctx context.Context, entry LogEntry) error {
+	return s.InsertLogs(ctx, []LogEntry{entry})
+}
+
+// InsertLogs appends a batch of log entries to the active segment
+func (s *FileStorage) InsertLogs(ctx context.Context, entries []LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+
+	createdAt := s.now().UTC()
+	var buf bytes.Buffer
+	pointers := make([]*recordPointer, 0, len(entries))
+	for i, entry := range entries {
+		rec := fileRecord{
+			Type:      recordTypeEntry,
+			ID:        s.nextID + int64(i),
+			Timestamp: entry.Timestamp,
+			Service:   entry.Service,
+			Level:     entry.Level,
+			Message:   entry.Message,
+			Fields:    entry.Fields,
+			CreatedAt: entry.CreatedAt.UTC(),
+		}
+		if entry.CreatedAt.IsZero() {
+			rec.CreatedAt = createdAt
+		}
+		start := int64(buf.Len())
+		if err := writeRecord(&buf, rec); err != nil {
+			return err
+		}
+		pointers = append(pointers, &recordPointer{
+			id:        rec.ID,
+			timestamp: rec.Timestamp,
+			service:   rec.Service,
+			level:     rec.Level,
+			offset:    start,
+			length:    int64(buf.Len()) - start,
+		})
+	}
+
+	seg, err := s.appendToActive(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to insert logs: %w", err)
+	}
+
+	for _, p := range pointers {
+		p.seg = seg
+		p.offset += seg.size - int64(buf.Len())
+		s.index(p)
+	}
+	seg.total += len(pointers)
+	seg.live += len(pointers)
+	for i, p := range pointers {
+		entries[i].ID = p.id
+	}
+	s.nextID += int64(len(entries))
+
+	return nil
+}
+
+// appendToActive writes data at the end of the active segment, rolling to a
+// new segment first when the active one would grow past MaxSegmentBytes
+func (s *FileStorage) appendToActive(data []byte) (*segment, error) {
+	seg := s.segments[len(s.segments)-1]
+	if seg.size > 0 && seg.size+int64(len(data)) > s.opts.MaxSegmentBytes {
+		next, err := s.roll()
+		if err != nil {
+			return nil, err
+		}
+		seg = next
+	}
+
+	if _, err := seg.file.WriteAt(data, seg.size); err != nil {
+		// Leave no partial record behind for the next writer
+		seg.file.Truncate(seg.size)
+		return nil, fmt.Errorf("failed to write segment: %w", err)
+	}
+	if s.opts.SyncWrites {
+		if err := seg.file.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync segment: %w", err)
+		}
+	}
+	seg.size += int64(len(data))
+
+	return seg, nil
+}
+
+// roll seals the active segment and starts a new one
+func (s *FileStorage) roll() (*segment, error) {
+	active := s.segments[len(s.segments)-1]
+	if err := active.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync segment: %w", err)
+	}
+
+	seg, err := s.openSegment(active.seq+1, os.O_RDWR|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(s.opts.Dir); err != nil {
+		seg.file.Close()
+		return nil, err
+	}
+	s.segments = append(s.segments, seg)
+
+	return seg, nil
+}
+
+// QueryLogs retrieves logs based on filters, newest first
+func (s *FileStorage) QueryLogs(ctx context.Context, filter QueryFilter) ([]LogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	candidates := s.timeIndex
+	if filter.Service != "" {
+		candidates = s.serviceIndex[filter.Service]
+	}
+
+	lo, hi := candidateRange(candidates, filter)
+
+	// Ranked results need every match before they can be ordered
+	ranked := filter.Rank && filter.Text != ""
+	skip, limit := filter.Offset, filter.Limit
+	if ranked {
+		skip, limit = 0, 0
+	}
+
+	start, end, step := scanOrder(lo, hi, filter.Ascending)
+	entries := []LogEntry{}
+	for i := start; i != end; i += step {
+		entry, ok, err := s.match(candidates[i], filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+	}
+
+	if ranked {
+		sortByRelevance(entries, filter.Text)
+		return paginate(entries, filter.Limit, filter.Offset), nil
+	}
+	return entries, nil
+}
+
+// match reads the entry of a pointer and reports whether it satisfies the
+// filter, skipping the read when the indexed level already rules it out
+func (s *FileStorage) match(p *recordPointer, filter QueryFilter) (LogEntry, bool, error) {
+	if filter.Level != "" && p.level != filter.Level {
+		return LogEntry{}, false, nil
+	}
+	entry, err := s.readEntry(p)
+	if err != nil {
+		return LogEntry{}, false, err
+	}
+	return entry, filter.Matches(entry), nil
+}
+
+// scanOrder returns the first index, the index past the last and the step
+// that walk candidates[lo:hi] newest first, or oldest first when ascending
+func scanOrder(lo, hi int, ascending bool) (int, int, int) {
+	if ascending {
+		return lo, hi, 1
+	}
+	return hi - 1, lo - 1, -1
+}
+
+// AggregateLogs counts the logs matching a query in ordered buckets
+func (s *FileStorage) AggregateLogs(ctx context.Context, query AggregateQuery) ([]Bucket, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	filter := query.selection()
+	candidates := s.timeIndex
+	if filter.Service != "" {
+		candidates = s.serviceIndex[filter.Service]
+	}
+
+	counts := newAggregator(query)
+	lo, hi := candidateRange(candidates, filter)
+	for _, p := range candidates[lo:hi] {
+		entry, ok, err := s.match(p, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			counts.add(entry)
+		}
+	}
+	return counts.result(), nil
+}
+
+// ListCatalog lists the distinct values of a kind among the stored logs.
+// Services and levels are read from the index; field keys need the
+// records themselves.
+func (s *FileStorage) ListCatalog(ctx context.Context, query CatalogQuery) ([]CatalogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	catalog := newCataloger(query)
+	lo, hi := candidateRange(s.timeIndex, query.window())
+	for _, p := range s.timeIndex[lo:hi] {
+		switch query.Kind {
+		case CatalogServices:
+			catalog.add(p.service, p.timestamp)
+		case CatalogLevels:
+			catalog.add(p.level, p.timestamp)
+		default:
+			entry, err := s.readEntry(p)
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range catalogValues(entry, query.Kind) {
+				catalog.add(value, entry.Timestamp)
+			}
+		}
+	}
+	return catalog.result(), nil
+}
+
+// candidateRange narrows pointers, which are sorted oldest first, to the
+// index range [lo, hi) allowed by the filter's time bounds and cursor
+func candidateRange(pointers []*recordPointer, filter QueryFilter) (int, int) {
+	lo, hi := 0, len(pointers)
+	if filter.StartTime != nil {
+		lo = sort.Search(len(pointers), func(i int) bool {
+			return !pointers[i].timestamp.Before(*filter.StartTime)
+		})
+	}
+	if filter.EndTime != nil {
+		hi = sort.Search(len(pointers), func(i int) bool {
+			return pointers[i].timestamp.After(*filter.EndTime)
+		})
+	}
+
+	if filter.After != nil {
+		// First pointer at or after the cursor in oldest-first order
+		at := sort.Search(len(pointers), func(i int) bool {
+			return filter.After.Compare(pointers[i].timestamp, pointers[i].id) >= 0
+		})
+		if filter.Ascending {
+			if at < len(pointers) && filter.After.Compare(pointers[at].timestamp, pointers[at].id) == 0 {
+				at++
+			}
+			lo = max(lo, at)
+		} else {
+			hi = min(hi, at)
+		}
+	}
+	return lo, max(lo, hi)
+}
+
+// readEntry loads the record a pointer refers to from its segment
+func (s *FileStorage) readEntry(p *recordPointer) (LogEntry, error) {
+	buf := make([]byte, p.length)
+	if _, err := p.seg.file.ReadAt(buf, p.offset); err != nil {
+		return LogEntry{}, fmt.Errorf("failed to read record %d: %w", p.id, err)
+	}
+
+	rec, _, err := readRecord(bytes.NewReader(buf))
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("failed to decode record %d: %w", p.id, err)
+	}
+
+	return LogEntry{
+		ID:        rec.ID,
+		Timestamp: rec.Timestamp,
+		Service:   rec.Service,
+		Level:     rec.Level,
+		Message:   rec.Message,
+		Fields:    rec.Fields,
+		CreatedAt: rec.CreatedAt,
+	}, nil
+}
+
+// DeleteOldLogs deletes logs older than the specified duration and
+// compacts the segments left mostly empty by the deletion
+func (s *FileStorage) DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return s.DeleteLogs(ctx, DeleteFilter{OlderThan: olderThan})
+}
+
+// DeleteLogs deletes logs older than filter.OlderThan within the filter's
+// scope and compacts the segments left mostly empty by the deletion
+func (s *FileStorage) DeleteLogs(ctx context.Context, filter DeleteFilter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStorageClosed
+	}
+
+	cutoffTime := s.now().Add(-filter.OlderThan).UTC()
+	if s.countExpired(cutoffTime, filter) == 0 {
+		return 0, nil
+	}
+
+	// Persist the purge before applying it so a restart replays it
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, fileRecord{Type: recordTypePurge, Before: &cutoffTime, Scope: newPurgeScope(filter)}); err != nil {
+		return 0, err
+	}
+	seg, err := s.appendToActive(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old logs: %w", err)
+	}
+	if !s.opts.SyncWrites {
+		if err := seg.file.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync segment: %w", err)
+		}
+	}
+
+	deleted := s.purge(cutoffTime, filter)
+	if err := s.compact(s.opts.CompactionRatio); err != nil {
+		return deleted, fmt.Errorf("failed to compact segments: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// Compact rewrites every sealed segment that still holds deleted records
+func (s *FileStorage) Compact(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+	return s.compact(1)
+}
+
+// compact rewrites sealed segments whose live fraction is below ratio and
+// removes those left empty. Purge records are only dropped once no segment
+// up to and including theirs holds deleted entries they could resurrect.
+func (s *FileStorage) compact(ratio float64) error {
+	sealed := s.segments[:len(s.segments)-1]
+	kept := make([]*segment, 0, len(s.segments))
+	clean := true
+
+	for _, seg := range sealed {
+		dead := seg.total - seg.live
+		if dead == 0 || float64(seg.live)/float64(seg.total) >= ratio {
+			clean = clean && dead == 0
+			kept = append(kept, seg)
+			continue
+		}
+
+		empty, err := s.rewriteSegment(seg, clean)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			kept = append(kept, seg)
+		}
+	}
+
+	s.segments = append(kept, s.segments[len(s.segments)-1])
+	return syncDir(s.opts.Dir)
+}
+
+// rewriteSegment copies the live records of seg into a replacement file and
+// atomically renames it into place. It reports whether nothing was left,
+// in which case the segment file is removed instead.
+func (s *FileStorage) rewriteSegment(seg *segment, dropPurges bool) (bool, error) {
+	tmpPath := seg.path + compactFileSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create compaction file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	moved, size, err := copyLiveRecords(seg, tmp, s.byID, dropPurges)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		return false, fmt.Errorf("failed to compact segment %s: %w", seg.path, err)
+	}
+
+	if size == 0 {
+		tmp.Close()
+		seg.file.Close()
+		if err := os.Remove(seg.path); err != nil {
+			return false, fmt.Errorf("failed to remove segment %s: %w", seg.path, err)
+		}
+		return true, nil
+	}
+
+	if err := os.Rename(tmpPath, seg.path); err != nil {
+		tmp.Close()
+		return false, fmt.Errorf("failed to replace segment %s: %w", seg.path, err)
+	}
+
+	seg.file.Close()
+	seg.file = tmp
+	seg.size = size
+	seg.total = len(moved)
+	for p, offset := range moved {
+		p.offset = offset
+	}
+
+	return false, nil
+}
+
+// copyLiveRecords writes the records of seg that are still indexed, plus
+// purge records unless dropPurges is set, to dst. It returns the new
+// offset of every copied entry and the number of bytes written.
+func copyLiveRecords(seg *segment, dst io.Writer, byID map[int64]*recordPointer, dropPurges bool) (map[*recordPointer]int64, int64, error) {
+	moved := make(map[*recordPointer]int64, seg.live)
+	reader := bufio.NewReader(io.NewSectionReader(seg.file, 0, seg.size))
+	writer := bufio.NewWriter(dst)
+
+	var written int64
+	for {
+		rec, _, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var p *recordPointer
+		switch rec.Type {
+		case recordTypeEntry:
+			if p = byID[rec.ID]; p == nil {
+				continue
+			}
+		case recordTypePurge:
+			if dropPurges {
+				continue
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := writeRecord(&buf, rec); err != nil {
+			return nil, 0, err
+		}
+		if _, err := writer.Write(buf.Bytes()); err != nil {
+			return nil, 0, err
+		}
+		if p != nil {
+			moved[p] = written
+		}
+		written += int64(buf.Len())
+	}
+
+	if err := writer.Flush(); err != nil {
+		return nil, 0, err
+	}
+	return moved, written, nil
+}
+
+// Close closes every segment file
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	active := s.segments[len(s.segments)-1]
+	syncErr := active.file.Sync()
+	if err := s.closeSegments(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+func (s *FileStorage) closeSegments() error {
+	var firstErr error
+	for _, seg := range s.segments {
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close segment %s: %w", seg.path, err)
+		}
+	}
+	return firstErr
+}
+
+// HealthCheck verifies the storage is open and its directory reachable
+func (s *FileStorage) HealthCheck(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+	if _, err := os.Stat(s.opts.Dir); err != nil {
+		return fmt.Errorf("storage directory unavailable: %w", err)
+	}
+	return ctx.Err()
+}
+
+// index adds a pointer to the time, service and id indexes
+func (s *FileStorage) index(p *recordPointer) {
+	s.timeIndex = insertPointer(s.timeIndex, p)
+	s.serviceIndex[p.service] = insertPointer(s.serviceIndex[p.service], p)
+	s.byID[p.id] = p
+}
+
+// purge drops every entry timestamped before cutoff within the filter's
+// scope from the indexes
+func (s *FileStorage) purge(cutoff time.Time, filter DeleteFilter) int64 {
+	expired := func(p *recordPointer) bool { return expiredPointer(p, cutoff, filter) }
+
+	var deleted int64
+	kept := s.timeIndex[:0]
+	for _, p := range s.timeIndex {
+		if expired(p) {
+			delete(s.byID, p.id)
+			p.seg.live--
+			deleted++
+			continue
+		}
+		kept = append(kept, p)
+	}
+	s.timeIndex = kept
+
+	for service, pointers := range s.serviceIndex {
+		remaining := pointers[:0]
+		for _, p := range pointers {
+			if !expired(p) {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(s.serviceIndex, service)
+			continue
+		}
+		s.serviceIndex[service] = remaining
+	}
+
+	return deleted
+}
+
+func (s *FileStorage) countExpired(cutoff time.Time, filter DeleteFilter) int {
+	count := 0
+	for _, p := range s.timeIndex {
+		if expiredPointer(p, cutoff, filter) {
+			count++
+		}
+	}
+	return count
+}
+
+func expiredPointer(p *recordPointer, cutoff time.Time, filter DeleteFilter) bool {
+	return p.timestamp.Before(cutoff) && filter.Matches(p.service, p.level)
+}
+
+func (s *FileStorage) openSegment(seq uint64, flag int) (*segment, error) {
+	path := filepath.Join(s.opts.Dir, segmentName(seq))
+	file, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %s: %w", path, err)
+	}
+	return &segment{seq: seq, path: path, file: file}, nil
+}
+
+// insertPointer keeps pointers ordered by (timestamp, id); in-order
+// arrivals take the append fast path
+func insertPointer(pointers []*recordPointer, p *recordPointer) []*recordPointer {
+	n := len(pointers)
+	if n == 0 || !pointerLess(p, pointers[n-1]) {
+		return append(pointers, p)
+	}
+
+	i := sort.Search(n, func(i int) bool { return pointerLess(p, pointers[i]) })
+	pointers = append(pointers, nil)
+	copy(pointers[i+1:], pointers[i:])
+	pointers[i] = p
+	return pointers
+}
+
+func pointerLess(a, b *recordPointer) bool {
+	if a.timestamp.Equal(b.timestamp) {
+		return a.id < b.id
+	}
+	return a.timestamp.Before(b.timestamp)
+}
+
+// writeRecord frames rec as [length][crc32][json payload]
+func writeRecord(w io.Writer, rec fileRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	var header [recordHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(payload)
+	return err
+}
+
+// readRecord decodes the next framed record, returning io.EOF at a clean
+// end of input and errTornRecord or errCorruptRecord otherwise
+func readRecord(r io.Reader) (fileRecord, int64, error) {
+	var rec fileRecord
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+		return rec, 0, errTornRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length == 0 || length > maxRecordBytes {
+		return rec, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, 0, errTornRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, 0, errCorruptRecord
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+
+	return rec, int64(recordHeaderSize + length), nil
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%s%020d%s", segmentFilePrefix, seq, segmentFileSuffix)
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+		return 0, false
+	}
+	digits := strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileSuffix)
+	seq, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// syncDir flushes directory entries so created, renamed and removed
+// segments survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open storage directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync storage directory: %w", err)
+	}
+	return nil
+}
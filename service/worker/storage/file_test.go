@@ -0,0 +1,217 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openFileStorage(t *testing.T, opts storage.FileOptions) *storage.FileStorage {
+	t.Helper()
+	store, err := storage.NewFileStorage(opts)
+	require.NoError(t, err)
+	return store
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestFileStorage_Suite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return openFileStorage(t, storage.FileOptions{Dir: t.TempDir()})
+	})
+}
+
+func TestFileStorage_ReopenRestoresEntries(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := openFileStorage(t, storage.FileOptions{Dir: dir, SyncWrites: true})
+	require.NoError(t, store.InsertLogs(ctx, storagetest.SeedEntries()))
+	require.NoError(t, store.Close())
+
+	store = openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{Service: "api"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fourth", "second", "first"}, storagetest.Messages(entries))
+	assert.Equal(t, "alice", entries[0].Fields["user"])
+
+	// New ids continue after the replayed ones
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "fifth"}))
+	entries, err = store.QueryLogs(ctx, storage.QueryFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), entries[0].ID)
+}
+
+func TestFileStorage_ReopenReplaysScopedDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := openFileStorage(t, storage.FileOptions{Dir: dir, SyncWrites: true})
+	require.NoError(t, store.InsertLogs(ctx, storagetest.SeedEntries()))
+	time.Sleep(50 * time.Millisecond)
+	deleted, err := store.DeleteLogs(ctx, storage.DeleteFilter{OlderThan: time.Millisecond, Levels: []string{"info"}, ExcludeServices: []string{"worker"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	require.NoError(t, store.Close())
+
+	store = openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"third", "second"}, storagetest.Messages(entries))
+}
+
+func TestFileStorage_RecoversFromTornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := openFileStorage(t, storage.FileOptions{Dir: dir})
+	require.NoError(t, store.InsertLogs(ctx, storagetest.SeedEntries()))
+	require.NoError(t, store.Close())
+
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+
+	// Simulate a crash halfway through appending a record
+	f, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 64, 1, 2, 3, 4, '{', '"'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store = openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+
+	restored, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), restored.Size())
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestFileStorage_RejectsCorruptSealedSegment(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store := openFileStorage(t, storage.FileOptions{Dir: dir, MaxSegmentBytes: 1})
+	for _, entry := range storagetest.SeedEntries() {
+		require.NoError(t, store.InsertLog(ctx, entry))
+	}
+	require.NoError(t, store.Close())
+
+	files := segmentFiles(t, dir)
+	require.Greater(t, len(files), 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(files[0], data, 0o644))
+
+	_, err = storage.NewFileStorage(storage.FileOptions{Dir: dir})
+	assert.ErrorContains(t, err, "corrupt record")
+}
+
+func TestFileStorage_CompactionAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// One record per segment so every delete leaves whole segments empty
+	store := openFileStorage(t, storage.FileOptions{Dir: dir, MaxSegmentBytes: 1})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: fmt.Sprintf("old-%d", i)}))
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "new"}))
+	require.Len(t, segmentFiles(t, dir), 4)
+
+	deleted, err := store.DeleteOldLogs(ctx, 25*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.Len(t, segmentFiles(t, dir), 2)
+	require.NoError(t, store.Close())
+
+	// Deleted entries stay deleted after a restart
+	store = openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, storagetest.Messages(entries))
+}
+
+func TestFileStorage_CompactKeepsLiveRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	now := time.Now()
+	store := openFileStorage(t, storage.FileOptions{Dir: dir, CompactionRatio: 0.01})
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: now.Add(-time.Hour), Service: "api", Level: "info", Message: "old"}))
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: now, Service: "api", Level: "info", Message: "kept"}))
+
+	// Seal the segment holding both entries, then delete without compacting
+	require.NoError(t, store.Close())
+	store = openFileStorage(t, storage.FileOptions{Dir: dir, MaxSegmentBytes: 1, CompactionRatio: 0.01})
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: now.Add(time.Second), Service: "worker", Level: "info", Message: "active"}))
+	deleted, err := store.DeleteOldLogs(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	before, err := os.Stat(segmentFiles(t, dir)[0])
+	require.NoError(t, err)
+	require.NoError(t, store.Compact(ctx))
+	after, err := os.Stat(segmentFiles(t, dir)[0])
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"active", "kept"}, storagetest.Messages(entries))
+	require.NoError(t, store.Close())
+
+	store = openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+	entries, err = store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"active", "kept"}, storagetest.Messages(entries))
+}
+
+func TestFileStorage_RemovesInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "segment-00000000000000000001.log.compact")
+	require.NoError(t, os.WriteFile(stale, []byte("partial"), 0o644))
+
+	store := openFileStorage(t, storage.FileOptions{Dir: dir})
+	defer store.Close()
+
+	_, err := os.Stat(stale)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileStorage_Close(t *testing.T) {
+	ctx := context.Background()
+	store := openFileStorage(t, storage.FileOptions{Dir: t.TempDir()})
+	require.NoError(t, store.Close())
+
+	assert.ErrorIs(t, store.HealthCheck(ctx), storage.ErrStorageClosed)
+	assert.ErrorIs(t, store.InsertLog(ctx, storage.LogEntry{}), storage.ErrStorageClosed)
+	_, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
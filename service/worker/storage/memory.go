@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrStorageClosed is returned when operating on a closed storage backend
+var ErrStorageClosed = errors.New("storage is closed")
+
+func init() {
+	Register("memory", func(u *url.URL) (Storage, error) {
+		if err := newURLOptions(u).Finish(); err != nil {
+			return nil, err
+		}
+		return NewMemoryStorage(), nil
+	})
+}
+
+// MemoryStorage implements Storage interface using an in-process slice.
+// It is intended for tests and single-node development; nothing is persisted.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	entries []LogEntry
+	nextID  int64
+	closed  bool
+	now     func() time.Time
+}
+
+// NewMemoryStorage creates a new in-memory storage instance
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		nextID: 1,
+		now:    time.Now,
+	}
+}
+
+// InsertLog inserts a single log entry
+func (s *MemoryStorage) InsertLog(ctx context.Context, entry LogEntry) error {
+	return s.InsertLogs(ctx, []LogEntry{entry})
+}
+
+// InsertLogs inserts multiple log entries in a batch
+func (s *MemoryStorage) InsertLogs(ctx context.Context, entries []LogEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+
+	createdAt := s.now()
+	for i := range entries {
+		entries[i].ID = s.nextID
+		entry := entries[i]
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = createdAt
+		}
+		entry.Fields = copyFields(entry.Fields)
+		s.entries = append(s.entries, entry)
+		s.nextID++
+	}
+
+	return nil
+}
+
+// QueryLogs retrieves logs based on filters, newest first
+func (s *MemoryStorage) QueryLogs(ctx context.Context, filter QueryFilter) ([]LogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	var matched []LogEntry
+	for _, entry := range s.entries {
+		if filter.Matches(entry) {
+			entry.Fields = copyFields(entry.Fields)
+			matched = append(matched, entry)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		older := CursorAt(matched[j]).Compare(matched[i].Timestamp, matched[i].ID) < 0
+		return older == filter.Ascending
+	})
+	if filter.Rank && filter.Text != "" {
+		sortByRelevance(matched, filter.Text)
+	}
+
+	return paginate(matched, filter.Limit, filter.Offset), nil
+}
+
+// AggregateLogs counts the logs matching a query in ordered buckets
+func (s *MemoryStorage) AggregateLogs(ctx context.Context, query AggregateQuery) ([]Bucket, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	filter := query.selection()
+	counts := newAggregator(query)
+	for _, entry := range s.entries {
+		if filter.Matches(entry) {
+			counts.add(entry)
+		}
+	}
+	return counts.result(), nil
+}
+
+// ListCatalog lists the distinct values of a kind among the stored logs
+func (s *MemoryStorage) ListCatalog(ctx context.Context, query CatalogQuery) ([]CatalogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	window := query.window()
+	catalog := newCataloger(query)
+	for _, entry := range s.entries {
+		if !window.Matches(entry) {
+			continue
+		}
+		for _, value := range catalogValues(entry, query.Kind) {
+			catalog.add(value, entry.Timestamp)
+		}
+	}
+	return catalog.result(), nil
+}
+
+// DeleteOldLogs deletes logs older than the specified duration
+func (s *MemoryStorage) DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return s.DeleteLogs(ctx, DeleteFilter{OlderThan: olderThan})
+}
+
+// DeleteLogs deletes logs older than filter.OlderThan within the filter's scope
+func (s *MemoryStorage) DeleteLogs(ctx context.Context, filter DeleteFilter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStorageClosed
+	}
+
+	cutoffTime := s.now().Add(-filter.OlderThan)
+	kept := s.entries[:0]
+	var deleted int64
+	for _, entry := range s.entries {
+		if entry.Timestamp.Before(cutoffTime) && filter.Matches(entry.Service, entry.Level) {
+			deleted++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	s.entries = kept
+
+	return deleted, nil
+}
+
+// Close releases all stored entries; later calls fail with ErrStorageClosed
+func (s *MemoryStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.entries = nil
+	return nil
+}
+
+// HealthCheck verifies the storage has not been closed
+func (s *MemoryStorage) HealthCheck(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+	return ctx.Err()
+}
+
+// paginate applies offset and limit to an already sorted result set
+func paginate(entries []LogEntry, limit, offset int) []LogEntry {
+	if offset > 0 {
+		if offset >= len(entries) {
+			return []LogEntry{}
+		}
+		entries = entries[offset:]
+	}
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func copyFields(fields map[string]any) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(fields))
+	for k, v := range fields {
+		copied[k] = copyValue(v)
+	}
+	return copied
+}
+
+// copyValue deep-copies the objects and arrays of a field value
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return copyFields(v)
+	case []any:
+		copied := make([]any, len(v))
+		for i, element := range v {
+			copied[i] = copyValue(element)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_Suite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage()
+	})
+}
+
+func TestMemoryStorage_ReturnedFieldsAreCopies(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.InsertLogs(ctx, storagetest.SeedEntries()))
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{Limit: 1})
+	require.NoError(t, err)
+	entries[0].Fields["user"] = "mallory"
+
+	entries, err = store.QueryLogs(ctx, storage.QueryFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", entries[0].Fields["user"])
+}
+
+func TestMemoryStorage_NestedFieldsAreCopies(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	request := map[string]any{"method": "GET", "tags": []any{"a"}}
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{
+		Timestamp: storagetest.BaseTime, Service: "api", Level: "info", Message: "nested", Fields: map[string]any{"request": request},
+	}))
+	request["method"] = "POST"
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	returned := entries[0].Fields["request"].(map[string]any)
+	returned["tags"].([]any)[0] = "b"
+
+	entries, err = store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"method": "GET", "tags": []any{"a"}}, entries[0].Fields["request"])
+}
+
+func TestMemoryStorage_ConcurrentInserts(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				entry := storage.LogEntry{Timestamp: time.Now(), Service: fmt.Sprintf("svc-%d", i), Level: "info", Message: "msg"}
+				assert.NoError(t, store.InsertLog(ctx, entry))
+				_, err := store.QueryLogs(ctx, storage.QueryFilter{Service: entry.Service, Limit: 5})
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 500)
+}
+
+func TestMemoryStorage_Close(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	require.NoError(t, store.HealthCheck(ctx))
+	require.NoError(t, store.Close())
+
+	assert.ErrorIs(t, store.HealthCheck(ctx), storage.ErrStorageClosed)
+	assert.ErrorIs(t, store.InsertLog(ctx, storage.LogEntry{}), storage.ErrStorageClosed)
+	_, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(embeddedMigrations, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tcases := []struct {
+		name             string
+		files            fstest.MapFS
+		expectedError    string
+		expectedVersions []int64
+	}{{
+		name: "sorted by version",
+		files: fstest.MapFS{
+			"m/0010_later.up.sql":   {Data: []byte("SELECT 10")},
+			"m/0010_later.down.sql": {Data: []byte("SELECT -10")},
+			"m/0002_first.up.sql":   {Data: []byte("SELECT 2")},
+			"m/0002_first.down.sql": {Data: []byte("SELECT -2")},
+		},
+		expectedVersions: []int64{2, 10},
+	}, {
+		name: "missing down script",
+		files: fstest.MapFS{
+			"m/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		},
+		expectedError: "needs both up and down scripts",
+	}, {
+		name: "invalid file name",
+		files: fstest.MapFS{
+			"m/init.sql": {Data: []byte("SELECT 1")},
+		},
+		expectedError: `invalid migration file name "init.sql"`,
+	}, {
+		name: "conflicting names",
+		files: fstest.MapFS{
+			"m/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+			"m/0001_other.down.sql": {Data: []byte("SELECT -1")},
+		},
+		expectedError: "conflicting names",
+	}}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tc.files, "m")
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			versions := make([]int64, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tc.expectedVersions, versions)
+		})
+	}
+}
+
+func TestParsePostgresConnString(t *testing.T) {
+	driverConn, opts, err := parsePostgresConnString("postgres://u:p@db:5432/logs?sslmode=disable&max_open_conns=50&conn_max_lifetime=10m&auto_migrate=false")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://u:p@db:5432/logs?sslmode=disable", driverConn)
+	assert.Equal(t, 50, opts.MaxOpenConns)
+	assert.Equal(t, DefaultPostgresOptions().MaxIdleConns, opts.MaxIdleConns)
+	assert.Equal(t, "10m0s", opts.ConnMaxLifetime.String())
+	assert.False(t, opts.AutoMigrate)
+
+	dsn := "host=localhost port=5432 user=postgres dbname=logs"
+	driverConn, opts, err = parsePostgresConnString(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, dsn, driverConn)
+	assert.Equal(t, DefaultPostgresOptions(), opts)
+
+	_, _, err = parsePostgresConnString("postgres://db/logs?max_open_conns=lots")
+	assert.ErrorContains(t, err, "max_open_conns")
+}
@@ -0,0 +1,18 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created before
+-- migrations existed adopt this version without changes.
+CREATE TABLE IF NOT EXISTS logs (
+	id BIGSERIAL PRIMARY KEY,
+	timestamp TIMESTAMPTZ NOT NULL,
+	service TEXT NOT NULL,
+	level TEXT NOT NULL,
+	message TEXT NOT NULL,
+	fields JSONB,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
+CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
+CREATE INDEX IF NOT EXISTS idx_logs_service_level ON logs(service, level);
+CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_logs_fields ON logs USING gin(fields);
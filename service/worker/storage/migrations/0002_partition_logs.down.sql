@@ -0,0 +1,26 @@
+CREATE TABLE logs_unpartitioned (
+	id BIGINT PRIMARY KEY DEFAULT nextval('logs_id_seq'),
+	timestamp TIMESTAMPTZ NOT NULL,
+	service TEXT NOT NULL,
+	level TEXT NOT NULL,
+	message TEXT NOT NULL,
+	fields JSONB,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO logs_unpartitioned (id, timestamp, service, level, message, fields, created_at)
+SELECT id, timestamp, service, level, message, fields, created_at FROM logs;
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs_unpartitioned.id;
+DROP TABLE logs;
+DROP TABLE log_partitions;
+
+ALTER TABLE logs_unpartitioned RENAME TO logs;
+ALTER INDEX logs_unpartitioned_pkey RENAME TO logs_pkey;
+
+CREATE INDEX idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX idx_logs_service ON logs(service);
+CREATE INDEX idx_logs_level ON logs(level);
+CREATE INDEX idx_logs_service_level ON logs(service, level);
+CREATE INDEX idx_logs_created_at ON logs(created_at);
+CREATE INDEX idx_logs_fields ON logs USING gin(fields);
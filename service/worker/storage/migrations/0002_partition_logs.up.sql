@@ -0,0 +1,66 @@
+-- Convert logs into a table range-partitioned on timestamp. Existing rows
+-- are copied into daily partitions; rows outside every partition land in
+-- logs_default. The worker creates further partitions ahead of time and
+-- records every partition it manages in log_partitions.
+ALTER TABLE logs RENAME TO logs_unpartitioned;
+ALTER INDEX logs_pkey RENAME TO logs_unpartitioned_pkey;
+DROP INDEX IF EXISTS idx_logs_timestamp;
+DROP INDEX IF EXISTS idx_logs_service;
+DROP INDEX IF EXISTS idx_logs_level;
+DROP INDEX IF EXISTS idx_logs_service_level;
+DROP INDEX IF EXISTS idx_logs_created_at;
+DROP INDEX IF EXISTS idx_logs_fields;
+
+CREATE TABLE logs (
+	id BIGINT NOT NULL DEFAULT nextval('logs_id_seq'),
+	timestamp TIMESTAMPTZ NOT NULL,
+	service TEXT NOT NULL,
+	level TEXT NOT NULL,
+	message TEXT NOT NULL,
+	fields JSONB,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE logs_default PARTITION OF logs DEFAULT;
+
+CREATE TABLE log_partitions (
+	name TEXT PRIMARY KEY,
+	range_start TIMESTAMPTZ NOT NULL,
+	range_end TIMESTAMPTZ NOT NULL
+);
+
+DO $$
+DECLARE
+	range_start TIMESTAMPTZ;
+	last_timestamp TIMESTAMPTZ;
+	partition_name TEXT;
+BEGIN
+	SELECT date_trunc('day', min(timestamp), 'UTC'), max(timestamp)
+	INTO range_start, last_timestamp
+	FROM logs_unpartitioned;
+
+	WHILE range_start IS NOT NULL AND range_start <= last_timestamp LOOP
+		partition_name := 'logs_p' || to_char(range_start AT TIME ZONE 'UTC', 'YYYYMMDD"T"HH24MI');
+		EXECUTE format(
+			'CREATE TABLE %I PARTITION OF logs FOR VALUES FROM (%L) TO (%L)',
+			partition_name, range_start, range_start + interval '24 hours'
+		);
+		INSERT INTO log_partitions (name, range_start, range_end)
+		VALUES (partition_name, range_start, range_start + interval '24 hours');
+		range_start := range_start + interval '24 hours';
+	END LOOP;
+END $$;
+
+INSERT INTO logs (id, timestamp, service, level, message, fields, created_at)
+SELECT id, timestamp, service, level, message, fields, created_at FROM logs_unpartitioned;
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs.id;
+DROP TABLE logs_unpartitioned;
+
+CREATE INDEX idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX idx_logs_service ON logs(service);
+CREATE INDEX idx_logs_level ON logs(level);
+CREATE INDEX idx_logs_service_level ON logs(service, level);
+CREATE INDEX idx_logs_created_at ON logs(created_at);
+CREATE INDEX idx_logs_fields ON logs USING gin(fields);
@@ -0,0 +1,7 @@
+-- Full-text search over log messages. The column is generated from the
+-- message with the 'english' configuration, which must match searchConfig
+-- in search.go. Adding a stored column rewrites every partition.
+ALTER TABLE logs ADD COLUMN search tsvector
+	GENERATED ALWAYS AS (to_tsvector('english', message)) STORED;
+
+CREATE INDEX idx_logs_search ON logs USING gin(search);
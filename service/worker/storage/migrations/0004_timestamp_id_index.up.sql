@@ -0,0 +1,4 @@
+-- Keyset pagination orders by (timestamp, id); an index on both columns
+-- serves that order and supersedes the timestamp-only index.
+CREATE INDEX idx_logs_timestamp_id ON logs(timestamp, id);
+DROP INDEX IF EXISTS idx_logs_timestamp;
@@ -0,0 +1,26 @@
+-- Catalog of the services, levels and field keys seen in logs, with one
+-- row per value and hour so that listing them does not scan logs. The
+-- worker adds to it in the transaction that inserts logs and subtracts
+-- the logs it deletes. Buckets must match CatalogBucket in catalog.go.
+CREATE TABLE log_catalog (
+	kind TEXT NOT NULL,
+	value TEXT NOT NULL,
+	bucket TIMESTAMPTZ NOT NULL,
+	count BIGINT NOT NULL,
+	first_seen TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (kind, value, bucket)
+);
+
+CREATE INDEX idx_log_catalog_kind_bucket ON log_catalog(kind, bucket);
+
+INSERT INTO log_catalog (kind, value, bucket, count, first_seen, last_seen)
+SELECT entries.kind, entries.value, date_trunc('hour', logs.timestamp, 'UTC'),
+	count(*), min(logs.timestamp), max(logs.timestamp)
+FROM logs CROSS JOIN LATERAL (
+	VALUES ('service'::text, logs.service), ('level'::text, logs.level)
+	UNION ALL
+	SELECT 'field_key'::text, key
+	FROM jsonb_object_keys(CASE WHEN jsonb_typeof(logs.fields) = 'object' THEN logs.fields END) AS key
+) AS entries(kind, value)
+GROUP BY 1, 2, 3;
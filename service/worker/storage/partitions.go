@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultPartitionInterval is the time range covered by one logs partition
+	DefaultPartitionInterval = 24 * time.Hour
+
+	// DefaultPartitionPremake is how many future partitions are kept ready
+	DefaultPartitionPremake = 3
+
+	// partitionLockKey serializes partition maintenance across workers
+	partitionLockKey int64 = 0x6563686f726970 // "echorip"
+
+	partitionNamePrefix = "logs_p"
+	partitionNameLayout = "20060102T1504"
+)
+
+// partitionManager maintains the range partitions of the logs table.
+// Partitions are aligned to multiples of interval since the Unix epoch in
+// UTC and tracked in the log_partitions table.
+type partitionManager struct {
+	db       *sql.DB
+	interval time.Duration
+	premake  int
+}
+
+// logPartition is one row of log_partitions
+type logPartition struct {
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+func partitionName(start time.Time) string {
+	return partitionNamePrefix + start.UTC().Format(partitionNameLayout)
+}
+
+// ensure creates every partition between the current one and premake
+// intervals ahead that does not exist yet. New ranges continue from the
+// newest existing partition, so a change of interval never overlaps.
+func (m *partitionManager) ensure(ctx context.Context, now time.Time) (int, error) {
+	current := bucketStart(now, m.interval)
+	horizon := current.Add(time.Duration(m.premake+1) * m.interval)
+
+	created := 0
+	err := m.withLock(ctx, func(tx *sql.Tx) error {
+		var newestEnd sql.NullTime
+		if err := tx.QueryRowContext(ctx, "SELECT max(range_end) FROM log_partitions").Scan(&newestEnd); err != nil {
+			return fmt.Errorf("failed to read log_partitions: %w", err)
+		}
+
+		start := current
+		if newestEnd.Valid && newestEnd.Time.After(start) {
+			start = newestEnd.Time.UTC()
+		}
+
+		for ; start.Before(horizon); start = start.Add(m.interval) {
+			end := start.Add(m.interval)
+			if err := createPartition(ctx, tx, logPartition{name: partitionName(start), start: start, end: end}); err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+
+	return created, err
+}
+
+// createPartition builds a partition standalone, moves in any rows of the
+// range that fell into logs_default, and attaches it to logs
+func createPartition(ctx context.Context, tx *sql.Tx, p logPartition) error {
+	name := pq.QuoteIdentifier(p.name)
+	from := pq.QuoteLiteral(p.start.Format(time.RFC3339Nano))
+	to := pq.QuoteLiteral(p.end.Format(time.RFC3339Nano))
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING GENERATED)", name),
+		// Generated columns such as search are recomputed rather than copied
+		fmt.Sprintf(`WITH moved AS (
+			DELETE FROM logs_default WHERE timestamp >= %s AND timestamp < %s RETURNING *
+		) INSERT INTO %s (%s) SELECT %s FROM moved`, from, to, name, selectColumns, selectColumns),
+		fmt.Sprintf("ALTER TABLE logs ATTACH PARTITION %s FOR VALUES FROM (%s) TO (%s)", name, from, to),
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", p.name, err)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO log_partitions (name, range_start, range_end) VALUES ($1, $2, $3)",
+		p.name, p.start, p.end,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record partition %s: %w", p.name, err)
+	}
+	return nil
+}
+
+// dropBefore detaches and drops every partition whose whole range ends at
+// or before cutoff, returning the number of rows they held
+func (m *partitionManager) dropBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var dropped int64
+	err := m.withLock(ctx, func(tx *sql.Tx) error {
+		partitions, err := expiredPartitions(ctx, tx, cutoff)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range partitions {
+			name := pq.QuoteIdentifier(p.name)
+
+			var count int64
+			if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", name)).Scan(&count); err != nil {
+				return fmt.Errorf("failed to count partition %s: %w", p.name, err)
+			}
+			statements := []string{
+				fmt.Sprintf("ALTER TABLE logs DETACH PARTITION %s", name),
+				fmt.Sprintf("DROP TABLE %s", name),
+			}
+			for _, statement := range statements {
+				if _, err := tx.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("failed to drop partition %s: %w", p.name, err)
+				}
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM log_partitions WHERE name = $1", p.name); err != nil {
+				return fmt.Errorf("failed to forget partition %s: %w", p.name, err)
+			}
+			dropped += count
+		}
+		return nil
+	})
+
+	return dropped, err
+}
+
+func expiredPartitions(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]logPartition, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT name, range_start, range_end FROM log_partitions WHERE range_end <= $1 ORDER BY range_start",
+		cutoff,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []logPartition
+	for rows.Next() {
+		var p logPartition
+		if err := rows.Scan(&p.name, &p.start, &p.end); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// withLock runs fn in a transaction holding the partition maintenance lock
+func (m *partitionManager) withLock(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", partitionLockKey); err != nil {
+		return fmt.Errorf("failed to acquire partition lock: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// run keeps future partitions created until stop is closed, recording the
+// outcome of the latest attempt through report
+func (m *partitionManager) run(stop <-chan struct{}, report func(error)) {
+	ticker := time.NewTicker(m.checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			_, err := m.ensure(ctx, now)
+			cancel()
+			report(err)
+		}
+	}
+}
+
+// checkInterval is how often run re-checks the premade partitions
+func (m *partitionManager) checkInterval() time.Duration {
+	check := m.interval / 4
+	if check > time.Hour {
+		check = time.Hour
+	}
+	return check
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionName(t *testing.T) {
+	start := time.Date(2025, 3, 9, 6, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	assert.Equal(t, "logs_p20250309T0400", partitionName(start))
+}
+
+func TestPartitionManager_CheckInterval(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, (&partitionManager{interval: time.Hour}).checkInterval())
+	assert.Equal(t, time.Hour, (&partitionManager{interval: 24 * time.Hour}).checkInterval())
+}
+
+func TestPartitionManager_Postgres(t *testing.T) {
+	connString := os.Getenv("ECHORIS_TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("ECHORIS_TEST_DATABASE_URL not set, skipping PostgreSQL tests")
+	}
+	ctx := context.Background()
+
+	store, err := NewPostgresStorage(connString)
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = store.db.Exec("TRUNCATE logs")
+	require.NoError(t, err)
+
+	t.Run("future partitions are premade", func(t *testing.T) {
+		var count int
+		err := store.db.QueryRow("SELECT count(*) FROM log_partitions WHERE range_end > now()").Scan(&count)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, count, DefaultPartitionPremake+1)
+
+		created, err := store.partitions.ensure(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, created, "ensure is idempotent")
+	})
+
+	t.Run("retention drops whole partitions", func(t *testing.T) {
+		start := time.Now().UTC().Truncate(24 * time.Hour).Add(-30 * 24 * time.Hour)
+		old := logPartition{name: partitionName(start), start: start, end: start.Add(24 * time.Hour)}
+
+		// A row in logs_default is moved into the partition when it is created
+		require.NoError(t, store.InsertLog(ctx, LogEntry{Timestamp: start.Add(time.Hour), Service: "api", Level: "info", Message: "old"}))
+		require.NoError(t, store.partitions.withLock(ctx, func(tx *sql.Tx) error {
+			return createPartition(ctx, tx, old)
+		}))
+		require.NoError(t, store.InsertLog(ctx, LogEntry{Timestamp: start.Add(2 * time.Hour), Service: "api", Level: "info", Message: "old"}))
+		require.NoError(t, store.InsertLog(ctx, LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "new"}))
+
+		deleted, err := store.DeleteOldLogs(ctx, 7*24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		var exists bool
+		err = store.db.QueryRow("SELECT EXISTS (SELECT 1 FROM log_partitions WHERE name = $1)", old.name).Scan(&exists)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		entries, err := store.QueryLogs(ctx, QueryFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "new", entries[0].Message)
+	})
+}
@@ -3,261 +3,407 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"net/url"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// PostgresStorage implements Storage interface using PostgreSQL
+func init() {
+	Register("postgres", newPostgresStorageFromURL)
+	Register("postgresql", newPostgresStorageFromURL)
+}
+
+// PostgresOptions holds connection pool and partitioning settings for PostgresStorage
+type PostgresOptions struct {
+	MaxOpenConns      int
+	MaxIdleConns      int
+	ConnMaxLifetime   time.Duration
+	ConnMaxIdleTime   time.Duration
+	PingTimeout       time.Duration
+	AutoMigrate       bool          // Apply pending migrations on startup instead of refusing to start
+	PartitionInterval time.Duration // Time range covered by each logs partition
+	PartitionPremake  int           // Future partitions kept created ahead of time
+	CopyThreshold     int           // Batch size from which InsertLogs switches to COPY
+	CopyBatchSize     int           // Rows streamed per COPY statement
+}
+
+// DefaultPostgresOptions returns the pool settings used when none are given
+func DefaultPostgresOptions() PostgresOptions {
+	return PostgresOptions{
+		MaxOpenConns:      25,
+		MaxIdleConns:      5,
+		ConnMaxLifetime:   5 * time.Minute,
+		ConnMaxIdleTime:   1 * time.Minute,
+		PingTimeout:       5 * time.Second,
+		AutoMigrate:       true,
+		PartitionInterval: DefaultPartitionInterval,
+		PartitionPremake:  DefaultPartitionPremake,
+		CopyThreshold:     DefaultCopyThreshold,
+		CopyBatchSize:     DefaultCopyBatchSize,
+	}
+}
+
+// PostgresStorage implements Storage interface using PostgreSQL.
+// The logs table is range-partitioned on timestamp; partitions are created
+// ahead of time in the background and dropped whole by DeleteOldLogs.
 type PostgresStorage struct {
-	db *sql.DB
+	db            *sql.DB
+	partitions    *partitionManager
+	copyThreshold int
+	copyBatchSize int
+	stop          chan struct{}
+	wg            sync.WaitGroup
+
+	mu             sync.Mutex
+	maintenanceErr error
+}
+
+// newPostgresStorageFromURL opens the PostgreSQL backend of a postgres:// URL
+func newPostgresStorageFromURL(u *url.URL) (Storage, error) {
+	driverConn, opts, err := parsePostgresConnString(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresStorageWithOptions(driverConn, opts)
+}
+
+// parsePostgresConnString reads pool settings from the query parameters
+// max_open_conns, max_idle_conns, conn_max_lifetime, conn_max_idle_time,
+// ping_timeout, auto_migrate, partition_interval, partition_premake,
+// copy_threshold and copy_batch_size of a postgres:// URL and returns the
+// URL without them for the driver. Key/value strings get the defaults.
+func parsePostgresConnString(connString string) (string, PostgresOptions, error) {
+	defaults := DefaultPostgresOptions()
+	if !hasURLScheme(connString) {
+		return connString, defaults, nil
+	}
+
+	u, err := url.Parse(connString)
+	if err != nil {
+		return "", defaults, fmt.Errorf("invalid storage URL: %w", err)
+	}
+
+	params := newURLOptions(u)
+	opts := PostgresOptions{
+		MaxOpenConns:      params.Int("max_open_conns", defaults.MaxOpenConns),
+		MaxIdleConns:      params.Int("max_idle_conns", defaults.MaxIdleConns),
+		ConnMaxLifetime:   params.Duration("conn_max_lifetime", defaults.ConnMaxLifetime),
+		ConnMaxIdleTime:   params.Duration("conn_max_idle_time", defaults.ConnMaxIdleTime),
+		PingTimeout:       params.Duration("ping_timeout", defaults.PingTimeout),
+		AutoMigrate:       params.Bool("auto_migrate", defaults.AutoMigrate),
+		PartitionInterval: params.Duration("partition_interval", defaults.PartitionInterval),
+		PartitionPremake:  params.Int("partition_premake", defaults.PartitionPremake),
+		CopyThreshold:     params.Int("copy_threshold", defaults.CopyThreshold),
+		CopyBatchSize:     params.Int("copy_batch_size", defaults.CopyBatchSize),
+	}
+	if err := params.Err(); err != nil {
+		return "", defaults, err
+	}
+
+	u.RawQuery = params.Remaining().Encode()
+	return u.String(), opts, nil
 }
 
 // NewPostgresStorage creates a new PostgreSQL storage instance
 func NewPostgresStorage(connString string) (*PostgresStorage, error) {
+	return NewPostgresStorageWithOptions(connString, DefaultPostgresOptions())
+}
+
+// NewPostgresStorageWithOptions creates a new PostgreSQL storage instance
+// with custom connection pool settings
+func NewPostgresStorageWithOptions(connString string, opts PostgresOptions) (*PostgresStorage, error) {
+	if opts.PartitionInterval < time.Hour {
+		return nil, fmt.Errorf("partition interval must be at least 1h, got %s", opts.PartitionInterval)
+	}
+	if opts.PartitionPremake < 1 {
+		return nil, fmt.Errorf("partition premake must be at least 1, got %d", opts.PartitionPremake)
+	}
+	if opts.CopyThreshold < 1 || opts.CopyBatchSize < 1 {
+		return nil, fmt.Errorf("copy threshold and batch size must be positive")
+	}
+
 	db, err := sql.Open("postgres", connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetConnMaxIdleTime(1 * time.Minute)
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 
 	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.PingTimeout)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	storage := &PostgresStorage{db: db}
+	storage := &PostgresStorage{
+		db: db,
+		partitions: &partitionManager{
+			db:       db,
+			interval: opts.PartitionInterval,
+			premake:  opts.PartitionPremake,
+		},
+		copyThreshold: opts.CopyThreshold,
+		copyBatchSize: opts.CopyBatchSize,
+		stop:          make(chan struct{}),
+	}
 
-	// Initialize schema
-	if err := storage.initSchema(); err != nil {
+	// Bring the schema up to date; waiting on another worker's migration
+	// lock is not bounded by the ping timeout
+	if err := storage.migrate(context.Background(), opts.AutoMigrate); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	// Inserts never fail for lack of a partition thanks to logs_default,
+	// but rows landing there miss out on pruning and partition retention
+	if _, err := storage.partitions.ensure(context.Background(), time.Now()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create partitions: %w", err)
+	}
+	storage.wg.Add(1)
+	go func() {
+		defer storage.wg.Done()
+		storage.partitions.run(storage.stop, storage.setMaintenanceErr)
+	}()
+
 	return storage, nil
 }
 
-// initSchema creates the necessary tables
-func (s *PostgresStorage) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS logs (
-		id BIGSERIAL PRIMARY KEY,
-		timestamp TIMESTAMPTZ NOT NULL,
-		service TEXT NOT NULL,
-		level TEXT NOT NULL,
-		message TEXT NOT NULL,
-		fields JSONB,
-		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
-	CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
-	CREATE INDEX IF NOT EXISTS idx_logs_service_level ON logs(service, level);
-	CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs(created_at);
-	CREATE INDEX IF NOT EXISTS idx_logs_fields ON logs USING gin(fields);
-	`
-
-	_, err := s.db.Exec(schema)
-	return err
+func (s *PostgresStorage) setMaintenanceErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maintenanceErr = err
 }
 
-// InsertLog inserts a single log entry
-func (s *PostgresStorage) InsertLog(ctx context.Context, entry LogEntry) error {
-	fieldsJSON, err := json.Marshal(entry.Fields)
+// migrate applies pending migrations, or only verifies that none are
+// pending when autoMigrate is off and migrations are run separately
+func (s *PostgresStorage) migrate(ctx context.Context, autoMigrate bool) error {
+	migrator, err := NewMigrator(s.db)
 	if err != nil {
-		return fmt.Errorf("failed to marshal fields: %w", err)
+		return err
 	}
 
-	query := `
-		INSERT INTO logs (timestamp, service, level, message, fields)
-		VALUES ($1, $2, $3, $4, $5)
-	`
-
-	_, err = s.db.ExecContext(ctx, query,
-		entry.Timestamp,
-		entry.Service,
-		entry.Level,
-		entry.Message,
-		fieldsJSON,
-	)
+	if autoMigrate {
+		_, err := migrator.Up(ctx)
+		return err
+	}
 
+	pending, err := migrator.Pending(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to insert log: %w", err)
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d pending migrations, run the worker with -migrate up", pending)
 	}
-
 	return nil
 }
 
-// InsertLogs inserts multiple log entries in a batch
+// InsertLog inserts a single log entry
+func (s *PostgresStorage) InsertLog(ctx context.Context, entry LogEntry) error {
+	return s.InsertLogs(ctx, []LogEntry{entry})
+}
+
+// InsertLogs inserts multiple log entries in a single transaction,
+// streaming them with COPY once the batch reaches the copy threshold and
+// using multi-row INSERT statements below it. The ids given to the entries
+// are set on them.
 func (s *PostgresStorage) InsertLogs(ctx context.Context, entries []LogEntry) error {
 	if len(entries) == 0 {
 		return nil
 	}
 
+	rows, err := logRows(entries)
+	if err != nil {
+		return err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO logs (timestamp, service, level, message, fields)
-		VALUES ($1, $2, $3, $4, $5)
-	`)
+	var ids []int64
+	if len(rows) >= s.copyThreshold {
+		ids, err = copyRows(ctx, tx, rows, s.copyBatchSize)
+	} else {
+		ids, err = insertRows(ctx, tx, rows)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return err
 	}
-	defer stmt.Close()
-
-	for _, entry := range entries {
-		fieldsJSON, err := json.Marshal(entry.Fields)
-		if err != nil {
-			return fmt.Errorf("failed to marshal fields: %w", err)
-		}
-
-		_, err = stmt.ExecContext(ctx,
-			entry.Timestamp,
-			entry.Service,
-			entry.Level,
-			entry.Message,
-			fieldsJSON,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert log: %w", err)
-		}
+	if err := upsertCatalog(ctx, tx, entries); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	for i, id := range ids {
+		entries[i].ID = id
+	}
 	return nil
 }
 
 // QueryLogs retrieves logs based on filters
 func (s *PostgresStorage) QueryLogs(ctx context.Context, filter QueryFilter) ([]LogEntry, error) {
-	query := `SELECT id, timestamp, service, level, message, fields, created_at FROM logs WHERE 1=1`
-	args := []interface{}{}
-	argCount := 1
-
-	if filter.Service != "" {
-		query += fmt.Sprintf(" AND service = $%d", argCount)
-		args = append(args, filter.Service)
-		argCount++
+	if err := filter.Validate(); err != nil {
+		return nil, err
 	}
+	query, args := buildSelectQuery(filter)
 
-	if filter.Level != "" {
-		query += fmt.Sprintf(" AND level = $%d", argCount)
-		args = append(args, filter.Level)
-		argCount++
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
 	}
+	defer rows.Close()
 
-	if filter.StartTime != nil {
-		query += fmt.Sprintf(" AND timestamp >= $%d", argCount)
-		args = append(args, filter.StartTime)
-		argCount++
-	}
+	return scanLogEntries(rows)
+}
 
-	if filter.EndTime != nil {
-		query += fmt.Sprintf(" AND timestamp <= $%d", argCount)
-		args = append(args, filter.EndTime)
-		argCount++
+// AggregateLogs counts the logs matching a query in ordered buckets
+func (s *PostgresStorage) AggregateLogs(ctx context.Context, query AggregateQuery) ([]Bucket, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
 	}
+	sqlQuery, args := buildAggregateQuery(query)
 
-	query += " ORDER BY timestamp DESC"
-
-	if filter.Limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argCount)
-		args = append(args, filter.Limit)
-		argCount++
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate logs: %w", err)
 	}
+	defer rows.Close()
+
+	return scanBuckets(rows, query)
+}
 
-	if filter.Offset > 0 {
-		query += fmt.Sprintf(" OFFSET $%d", argCount)
-		args = append(args, filter.Offset)
-		argCount++
+// ListCatalog lists the distinct values of a kind from log_catalog
+func (s *PostgresStorage) ListCatalog(ctx context.Context, query CatalogQuery) ([]CatalogEntry, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
 	}
+	sqlQuery, args := buildCatalogQuery(query)
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
+		return nil, fmt.Errorf("failed to list log catalog: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []LogEntry
-	for rows.Next() {
-		var entry LogEntry
-		var fieldsJSON []byte
-
-		err := rows.Scan(
-			&entry.ID,
-			&entry.Timestamp,
-			&entry.Service,
-			&entry.Level,
-			&entry.Message,
-			&fieldsJSON,
-			&entry.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
+	return scanCatalog(rows)
+}
 
-		if len(fieldsJSON) > 0 {
-			if err := json.Unmarshal(fieldsJSON, &entry.Fields); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal fields: %w", err)
-			}
-		}
+// DeleteOldLogs deletes logs whose timestamp is older than the specified
+// duration. Partitions entirely before the cutoff are detached and dropped;
+// only the partition straddling the cutoff and logs_default are deleted
+// from row by row. Catalog rows of the hours before the cutoff go with them.
+func (s *PostgresStorage) DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	cutoffTime := time.Now().Add(-olderThan)
 
-		entries = append(entries, entry)
+	dropped, err := s.partitions.dropBefore(ctx, cutoffTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drop old partitions: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	deleted, err := s.deleteCataloged(ctx, "DELETE FROM logs WHERE timestamp < $1", []interface{}{cutoffTime}, cutoffTime)
+	if err != nil {
+		return dropped, fmt.Errorf("failed to delete old logs: %w", err)
 	}
 
-	return entries, nil
+	return dropped + deleted, nil
 }
 
-// DeleteOldLogs deletes logs older than the specified duration
-func (s *PostgresStorage) DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
-	cutoffTime := time.Now().Add(-olderThan)
-
-	result, err := s.db.ExecContext(ctx,
-		"DELETE FROM logs WHERE created_at < $1",
-		cutoffTime,
-	)
+// deleteCataloged runs a DELETE over logs and subtracts the deleted logs
+// from log_catalog in one transaction, then prunes the catalog rows before
+// prune (see pruneCatalog). It returns the number of logs deleted.
+func (s *PostgresStorage) deleteCataloged(ctx context.Context, query string, args []interface{}, prune time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete old logs: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	var deleted int64
+	if err := tx.QueryRowContext(ctx, buildCatalogDelete(query), args...).Scan(&deleted); err != nil {
+		return 0, err
+	}
+	if err := pruneCatalog(ctx, tx, prune); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// DeleteLogs deletes logs older than filter.OlderThan within the filter's
+// scope. Unscoped deletes take the partition dropping path of DeleteOldLogs.
+func (s *PostgresStorage) DeleteLogs(ctx context.Context, filter DeleteFilter) (int64, error) {
+	if !filter.Scoped() {
+		return s.DeleteOldLogs(ctx, filter.OlderThan)
 	}
 
-	return rowsAffected, nil
+	query := "DELETE FROM logs WHERE timestamp < $1"
+	args := []interface{}{time.Now().Add(-filter.OlderThan)}
+	conditions := []struct {
+		format string
+		values []string
+	}{
+		{" AND service = ANY($%d)", filter.Services},
+		{" AND level = ANY($%d)", filter.Levels},
+		{" AND NOT (service = ANY($%d))", filter.ExcludeServices},
+		{" AND NOT (level = ANY($%d))", filter.ExcludeLevels},
+	}
+	for _, condition := range conditions {
+		if len(condition.values) == 0 {
+			continue
+		}
+		args = append(args, pq.Array(condition.values))
+		query += fmt.Sprintf(condition.format, len(args))
+	}
+
+	// Other logs of the same hours remain, so only emptied rows are pruned
+	deleted, err := s.deleteCataloged(ctx, query, args, time.Time{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %w", err)
+	}
+	return deleted, nil
 }
 
-// Close closes the database connection
+// Close stops partition maintenance and closes the database connection
 func (s *PostgresStorage) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.wg.Wait()
+		s.stop = nil
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
 	return nil
 }
 
-// HealthCheck verifies the database connection is healthy
+// HealthCheck verifies the database connection is healthy and that the
+// latest partition maintenance run succeeded
 func (s *PostgresStorage) HealthCheck(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	if err := s.db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maintenanceErr != nil {
+		return fmt.Errorf("partition maintenance failed: %w", s.maintenanceErr)
+	}
+	return nil
 }
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// catalogRow is the change one batch of logs makes to a log_catalog row
+type catalogRow struct {
+	kind      CatalogKind
+	value     string
+	bucket    time.Time
+	count     int64
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// catalogRows counts entries per catalog value and bucket, ordered by
+// primary key so that concurrent upserts lock rows in the same order
+func catalogRows(entries []LogEntry) []catalogRow {
+	type key struct {
+		kind   CatalogKind
+		value  string
+		bucket time.Time
+	}
+	rows := make(map[key]*catalogRow)
+	for _, entry := range entries {
+		timestamp := entry.Timestamp.UTC()
+		for _, kind := range []CatalogKind{CatalogServices, CatalogLevels, CatalogFieldKeys} {
+			for _, value := range catalogValues(entry, kind) {
+				k := key{kind: kind, value: value, bucket: catalogBucket(timestamp)}
+				row, ok := rows[k]
+				if !ok {
+					rows[k] = &catalogRow{kind: kind, value: value, bucket: k.bucket, count: 1, firstSeen: timestamp, lastSeen: timestamp}
+					continue
+				}
+				row.count++
+				row.firstSeen = minTime(row.firstSeen, timestamp)
+				row.lastSeen = maxTime(row.lastSeen, timestamp)
+			}
+		}
+	}
+
+	sorted := make([]catalogRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	slices.SortFunc(sorted, func(x, y catalogRow) int {
+		return cmp.Or(cmp.Compare(x.kind, y.kind), cmp.Compare(x.value, y.value), x.bucket.Compare(y.bucket))
+	})
+	return sorted
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// catalogUpsert adds counts to log_catalog, keeping the earliest and
+// latest timestamps seen
+const catalogUpsert = `INSERT INTO log_catalog (kind, value, bucket, count, first_seen, last_seen)
+SELECT * FROM unnest($1::text[], $2::text[], $3::timestamptz[], $4::bigint[], $5::timestamptz[], $6::timestamptz[])
+ON CONFLICT (kind, value, bucket) DO UPDATE SET
+	count = log_catalog.count + excluded.count,
+	first_seen = least(log_catalog.first_seen, excluded.first_seen),
+	last_seen = greatest(log_catalog.last_seen, excluded.last_seen)`
+
+// upsertCatalog adds entries to log_catalog within the transaction that
+// inserts them
+func upsertCatalog(ctx context.Context, tx *sql.Tx, entries []LogEntry) error {
+	rows := catalogRows(entries)
+	kinds := make([]string, len(rows))
+	values := make([]string, len(rows))
+	buckets := make([]string, len(rows))
+	counts := make([]int64, len(rows))
+	firstSeen := make([]string, len(rows))
+	lastSeen := make([]string, len(rows))
+	for i, row := range rows {
+		kinds[i] = string(row.kind)
+		values[i] = row.value
+		buckets[i] = row.bucket.Format(time.RFC3339Nano)
+		counts[i] = row.count
+		firstSeen[i] = row.firstSeen.Format(time.RFC3339Nano)
+		lastSeen[i] = row.lastSeen.Format(time.RFC3339Nano)
+	}
+
+	_, err := tx.ExecContext(ctx, catalogUpsert,
+		pq.Array(kinds), pq.Array(values), pq.Array(buckets),
+		pq.Array(counts), pq.Array(firstSeen), pq.Array(lastSeen),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update log catalog: %w", err)
+	}
+	return nil
+}
+
+// buildCatalogDelete wraps a DELETE over logs so that the same statement
+// subtracts the deleted logs from log_catalog. It selects the number of
+// logs deleted. Rows left at zero are removed by pruneCatalog.
+func buildCatalogDelete(deleteQuery string) string {
+	return `WITH deleted AS (` + deleteQuery + ` RETURNING timestamp, service, level, fields),
+removed AS (
+	SELECT entries.kind, entries.value, date_trunc('hour', deleted.timestamp, 'UTC') AS bucket, count(*) AS count
+	FROM deleted CROSS JOIN LATERAL (
+		VALUES ('service'::text, deleted.service), ('level'::text, deleted.level)
+		UNION ALL
+		SELECT 'field_key'::text, key
+		FROM jsonb_object_keys(CASE WHEN jsonb_typeof(deleted.fields) = 'object' THEN deleted.fields END) AS key
+	) AS entries(kind, value)
+	GROUP BY 1, 2, 3
+), updated AS (
+	UPDATE log_catalog SET count = log_catalog.count - removed.count
+	FROM removed
+	WHERE log_catalog.kind = removed.kind AND log_catalog.value = removed.value AND log_catalog.bucket = removed.bucket
+)
+SELECT count(*) FROM deleted`
+}
+
+// pruneCatalog removes the catalog rows no log counts towards: those
+// emptied by deletes and, when before is set, those of hours entirely
+// before it, whose logs may have been dropped with their partitions
+func pruneCatalog(ctx context.Context, tx *sql.Tx, before time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM log_catalog WHERE count <= 0 OR bucket < $1",
+		catalogBucket(before),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to prune log catalog: %w", err)
+	}
+	return nil
+}
+
+// buildCatalogQuery sums the catalog rows of a query's window. Values
+// sort bytewise under the C collation, as in the other backends.
+func buildCatalogQuery(query CatalogQuery) (string, []interface{}) {
+	q := &logQuery{}
+	q.sql.WriteString("SELECT value, sum(count)::bigint, min(first_seen), max(last_seen) FROM log_catalog WHERE kind = ")
+	q.sql.WriteString(q.param(string(query.Kind)))
+	if query.Start != nil {
+		q.where("bucket >= %s", catalogBucket(*query.Start))
+	}
+	if query.End != nil {
+		q.where("bucket <= %s", catalogBucket(*query.End))
+	}
+	q.sql.WriteString(` GROUP BY value HAVING sum(count) > 0 ORDER BY value COLLATE "C"`)
+	if query.Limit > 0 {
+		q.sql.WriteString(" LIMIT " + q.param(query.Limit))
+	}
+	return q.sql.String(), q.args
+}
+
+// scanCatalog reads every row selected by buildCatalogQuery
+func scanCatalog(rows *sql.Rows) ([]CatalogEntry, error) {
+	entries := []CatalogEntry{}
+	for rows.Next() {
+		var entry CatalogEntry
+		if err := rows.Scan(&entry.Value, &entry.Count, &entry.FirstSeen, &entry.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entry.FirstSeen = entry.FirstSeen.UTC()
+		entry.LastSeen = entry.LastSeen.UTC()
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entries, nil
+}
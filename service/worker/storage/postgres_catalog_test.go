@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogRows(t *testing.T) {
+	hour := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rows := catalogRows([]LogEntry{
+		{Timestamp: hour.Add(40 * time.Minute), Service: "api", Level: "info", Fields: map[string]any{"user": "alice"}},
+		{Timestamp: hour.Add(10 * time.Minute), Service: "api", Level: "error"},
+		{Timestamp: hour.Add(70 * time.Minute), Service: "api", Level: "info", Fields: map[string]any{"user": "bob"}},
+	})
+
+	assert.Equal(t, []catalogRow{
+		{kind: CatalogFieldKeys, value: "user", bucket: hour, count: 1, firstSeen: hour.Add(40 * time.Minute), lastSeen: hour.Add(40 * time.Minute)},
+		{kind: CatalogFieldKeys, value: "user", bucket: hour.Add(time.Hour), count: 1, firstSeen: hour.Add(70 * time.Minute), lastSeen: hour.Add(70 * time.Minute)},
+		{kind: CatalogLevels, value: "error", bucket: hour, count: 1, firstSeen: hour.Add(10 * time.Minute), lastSeen: hour.Add(10 * time.Minute)},
+		{kind: CatalogLevels, value: "info", bucket: hour, count: 1, firstSeen: hour.Add(40 * time.Minute), lastSeen: hour.Add(40 * time.Minute)},
+		{kind: CatalogLevels, value: "info", bucket: hour.Add(time.Hour), count: 1, firstSeen: hour.Add(70 * time.Minute), lastSeen: hour.Add(70 * time.Minute)},
+		{kind: CatalogServices, value: "api", bucket: hour, count: 2, firstSeen: hour.Add(10 * time.Minute), lastSeen: hour.Add(40 * time.Minute)},
+		{kind: CatalogServices, value: "api", bucket: hour.Add(time.Hour), count: 1, firstSeen: hour.Add(70 * time.Minute), lastSeen: hour.Add(70 * time.Minute)},
+	}, rows)
+}
+
+func TestBuildCatalogQuery(t *testing.T) {
+	t.Run("all time", func(t *testing.T) {
+		query, args := buildCatalogQuery(CatalogQuery{Kind: CatalogServices})
+		assert.Equal(t, "SELECT value, sum(count)::bigint, min(first_seen), max(last_seen) FROM log_catalog WHERE kind = $1"+
+			` GROUP BY value HAVING sum(count) > 0 ORDER BY value COLLATE "C"`, query)
+		assert.Equal(t, []interface{}{"service"}, args)
+	})
+
+	t.Run("window and limit", func(t *testing.T) {
+		start := time.Date(2025, 1, 1, 12, 15, 0, 0, time.UTC)
+		end := time.Date(2025, 1, 1, 14, 45, 0, 0, time.UTC)
+		query, args := buildCatalogQuery(CatalogQuery{Kind: CatalogFieldKeys, Start: &start, End: &end, Limit: 50})
+		assert.Equal(t, "SELECT value, sum(count)::bigint, min(first_seen), max(last_seen) FROM log_catalog WHERE kind = $1"+
+			" AND bucket >= $2 AND bucket <= $3"+
+			` GROUP BY value HAVING sum(count) > 0 ORDER BY value COLLATE "C" LIMIT $4`, query)
+		assert.Equal(t, []interface{}{
+			"field_key", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 14, 0, 0, 0, time.UTC), 50,
+		}, args)
+	})
+}
+
+func TestBuildCatalogDelete(t *testing.T) {
+	query := buildCatalogDelete("DELETE FROM logs WHERE timestamp < $1")
+	assert.Contains(t, query, "WITH deleted AS (DELETE FROM logs WHERE timestamp < $1 RETURNING timestamp, service, level, fields)")
+	assert.Contains(t, query, "UPDATE log_catalog SET count = log_catalog.count - removed.count")
+	assert.Contains(t, query, "SELECT count(*) FROM deleted")
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultCopyThreshold is the batch size from which inserts use COPY
+	DefaultCopyThreshold = 100
+
+	// DefaultCopyBatchSize is the number of rows streamed per COPY statement
+	DefaultCopyBatchSize = 5000
+
+	// maxInsertRows keeps multi-row INSERTs well below the 65535 bind
+	// parameter limit of the PostgreSQL protocol
+	maxInsertRows = 1000
+)
+
+// logColumns are the columns written on insert, in row value order
+var logColumns = []string{"timestamp", "service", "level", "message", "fields", "created_at"}
+
+// logRows converts entries into row values matching logColumns. Entries
+// without a CreatedAt are stamped with the current time.
+func logRows(entries []LogEntry) ([][]interface{}, error) {
+	now := time.Now().UTC()
+	rows := make([][]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		createdAt := entry.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+
+		fieldsJSON, err := json.Marshal(entry.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal fields: %w", err)
+		}
+
+		rows = append(rows, []interface{}{
+			entry.Timestamp,
+			entry.Service,
+			entry.Level,
+			entry.Message,
+			// COPY sends values as text; a []byte would be encoded as bytea
+			string(fieldsJSON),
+			createdAt,
+		})
+	}
+	return rows, nil
+}
+
+// copyRows streams rows into logs with COPY FROM STDIN, one COPY
+// statement per batchSize rows, and returns the ids given to them. COPY
+// cannot return ids, so they are drawn from the sequence beforehand.
+func copyRows(ctx context.Context, tx *sql.Tx, rows [][]interface{}, batchSize int) ([]int64, error) {
+	ids, err := reserveIDs(ctx, tx, len(rows))
+	if err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+		if err := copyBatch(ctx, tx, rows[start:end], ids[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// reserveIDs draws n ids from the sequence of logs, in ascending order
+func reserveIDs(ctx context.Context, tx *sql.Tx, n int) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT nextval('logs_id_seq') FROM generate_series(1, $1)", n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve log ids: %w", err)
+	}
+	ids, err := scanIDs(rows, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve log ids: %w", err)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func copyBatch(ctx context.Context, tx *sql.Tx, rows [][]interface{}, ids []int64) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("logs", append([]string{"id"}, logColumns...)...))
+	if err != nil {
+		return fmt.Errorf("failed to start copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, row := range rows {
+		if _, err := stmt.ExecContext(ctx, append([]interface{}{ids[i]}, row...)...); err != nil {
+			return fmt.Errorf("failed to copy log: %w", err)
+		}
+	}
+
+	// An Exec without arguments flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush copy: %w", err)
+	}
+	return nil
+}
+
+// insertRows writes rows with multi-row INSERT statements and returns the
+// ids given to them
+func insertRows(ctx context.Context, tx *sql.Tx, rows [][]interface{}) ([]int64, error) {
+	ids := make([]int64, 0, len(rows))
+	for start := 0; start < len(rows); start += maxInsertRows {
+		end := min(start+maxInsertRows, len(rows))
+		chunk := rows[start:end]
+
+		args := make([]interface{}, 0, len(chunk)*len(logColumns))
+		for _, row := range chunk {
+			args = append(args, row...)
+		}
+
+		inserted, err := tx.QueryContext(ctx, buildInsertQuery(len(chunk)), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert logs: %w", err)
+		}
+		chunkIDs, err := scanIDs(inserted, len(chunk))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert logs: %w", err)
+		}
+		ids = append(ids, chunkIDs...)
+	}
+	return ids, nil
+}
+
+// scanIDs reads and closes a result of n ids
+func scanIDs(rows *sql.Rows, n int) ([]int64, error) {
+	defer rows.Close()
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) != n {
+		return nil, fmt.Errorf("expected %d ids, got %d", n, len(ids))
+	}
+	return ids, nil
+}
+
+// buildInsertQuery returns an INSERT with one placeholder tuple per row,
+// returning the ids of the rows in their order
+func buildInsertQuery(rowCount int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO logs (")
+	b.WriteString(strings.Join(logColumns, ", "))
+	b.WriteString(") VALUES ")
+
+	param := 1
+	for i := 0; i < rowCount; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j := range logColumns {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", param)
+			param++
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(" RETURNING id")
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInsertQuery(t *testing.T) {
+	assert.Equal(t,
+		"INSERT INTO logs (timestamp, service, level, message, fields, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		buildInsertQuery(1),
+	)
+	assert.Equal(t,
+		"INSERT INTO logs (timestamp, service, level, message, fields, created_at) VALUES ($1, $2, $3, $4, $5, $6), ($7, $8, $9, $10, $11, $12) RETURNING id",
+		buildInsertQuery(2),
+	)
+}
+
+func TestLogRows(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	received := ts.Add(time.Second)
+	rows, err := logRows([]LogEntry{
+		{Timestamp: ts, Service: "api", Level: "info", Message: "hello", Fields: map[string]any{"user": "alice"}, CreatedAt: received},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{ts, "api", "info", "hello", `{"user":"alice"}`, received}}, rows)
+
+	rows, err = logRows([]LogEntry{{Timestamp: ts, Service: "api", Level: "info", Message: "hello"}})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), rows[0][5].(time.Time), time.Minute)
+}
+
+func TestPostgresStorage_InsertPaths(t *testing.T) {
+	store := openBenchmarkStorage(t)
+	ctx := context.Background()
+
+	for _, size := range []int{3, DefaultCopyThreshold, maxInsertRows + 1} {
+		t.Run(fmt.Sprintf("%d rows", size), func(t *testing.T) {
+			_, err := store.db.Exec("TRUNCATE logs")
+			require.NoError(t, err)
+
+			inserted := benchmarkEntries(size)
+			require.NoError(t, store.InsertLogs(ctx, inserted))
+
+			entries, err := store.QueryLogs(ctx, QueryFilter{Service: "bench"})
+			require.NoError(t, err)
+			require.Len(t, entries, size)
+			assert.Equal(t, "bench-0", entries[0].Fields["request"])
+			assert.Equal(t, entries[0].ID, inserted[0].ID, "inserted entries carry their ids")
+		})
+	}
+}
+
+func openBenchmarkStorage(tb testing.TB) *PostgresStorage {
+	tb.Helper()
+	connString := os.Getenv("ECHORIS_TEST_DATABASE_URL")
+	if connString == "" {
+		tb.Skip("ECHORIS_TEST_DATABASE_URL not set, skipping PostgreSQL tests")
+	}
+
+	store, err := NewPostgresStorage(connString)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { store.Close() })
+	return store
+}
+
+func benchmarkEntries(n int) []LogEntry {
+	now := time.Now()
+	entries := make([]LogEntry, n)
+	for i := range entries {
+		entries[i] = LogEntry{
+			Timestamp: now.Add(-time.Duration(i) * time.Millisecond),
+			Service:   "bench",
+			Level:     "info",
+			Message:   fmt.Sprintf("benchmark message %d", i),
+			Fields:    map[string]any{"request": fmt.Sprintf("bench-%d", i), "region": "eu"},
+		}
+	}
+	return entries
+}
+
+// insertRowsPrepared is the original one-INSERT-per-entry loop, kept as
+// the baseline for the benchmarks
+func insertRowsPrepared(ctx context.Context, tx *sql.Tx, rows [][]interface{}) error {
+	stmt, err := tx.PrepareContext(ctx, buildInsertQuery(1))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func BenchmarkInsertLogs(b *testing.B) {
+	store := openBenchmarkStorage(b)
+	ctx := context.Background()
+
+	strategies := []struct {
+		name   string
+		insert func(ctx context.Context, tx *sql.Tx, rows [][]interface{}) error
+	}{
+		{"prepared-loop", insertRowsPrepared},
+		{"multi-row", func(ctx context.Context, tx *sql.Tx, rows [][]interface{}) error {
+			_, err := insertRows(ctx, tx, rows)
+			return err
+		}},
+		{"copy", func(ctx context.Context, tx *sql.Tx, rows [][]interface{}) error {
+			_, err := copyRows(ctx, tx, rows, DefaultCopyBatchSize)
+			return err
+		}},
+	}
+
+	for _, size := range []int{10, 100, 1000, 10000} {
+		rows, err := logRows(benchmarkEntries(size))
+		require.NoError(b, err)
+
+		for _, strategy := range strategies {
+			b.Run(fmt.Sprintf("%s/%d", strategy.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					tx, err := store.db.BeginTx(ctx, nil)
+					require.NoError(b, err)
+					require.NoError(b, strategy.insert(ctx, tx, rows))
+					require.NoError(b, tx.Commit())
+				}
+				b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/querylang"
+)
+
+// selectColumns are the columns read back for every log entry, in scan order
+const selectColumns = "id, timestamp, service, level, message, fields, created_at"
+
+// logQuery accumulates the SQL text and bind arguments of a logs query
+type logQuery struct {
+	sql  strings.Builder
+	args []interface{}
+}
+
+// param binds value and returns its placeholder
+func (q *logQuery) param(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where appends a condition; %s in format is replaced by the placeholder
+// bound to value
+func (q *logQuery) where(format string, value interface{}) {
+	q.sql.WriteString(" AND ")
+	fmt.Fprintf(&q.sql, format, q.param(value))
+}
+
+// buildSelectQuery translates a filter into a SELECT over logs
+func buildSelectQuery(filter QueryFilter) (string, []interface{}) {
+	q := &logQuery{}
+	q.sql.WriteString("SELECT " + selectColumns + " FROM logs WHERE 1=1")
+	tsquery := q.conditions(filter)
+
+	// The plain timestamp bound lets the planner prune partitions, which
+	// it cannot do from the row comparison alone
+	direction, bound, past := "DESC", "<=", "<"
+	if filter.Ascending {
+		direction, bound, past = "ASC", ">=", ">"
+	}
+	if filter.After != nil {
+		ts := q.param(filter.After.Timestamp)
+		fmt.Fprintf(&q.sql, " AND timestamp %s %s AND (timestamp, id) %s (%s, %s)", bound, ts, past, ts, q.param(filter.After.ID))
+	}
+
+	order := fmt.Sprintf("timestamp %s, id %s", direction, direction)
+	if tsquery != "" && filter.Rank {
+		order = fmt.Sprintf("ts_rank(search, %s) DESC, %s", tsquery, order)
+	}
+	q.sql.WriteString(" ORDER BY " + order)
+
+	if filter.Limit > 0 {
+		q.sql.WriteString(" LIMIT " + q.param(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		q.sql.WriteString(" OFFSET " + q.param(filter.Offset))
+	}
+
+	return q.sql.String(), q.args
+}
+
+// truncUnits are the bucket widths date_trunc can compute
+var truncUnits = map[time.Duration]string{
+	time.Second:    "second",
+	time.Minute:    "minute",
+	time.Hour:      "hour",
+	24 * time.Hour: "day",
+}
+
+// buildAggregateQuery translates an aggregation into a GROUP BY over logs.
+// Buckets are truncated in UTC, and other widths are binned from the Unix
+// epoch without date_bin, which needs PostgreSQL 14, so they line up with
+// the other backends. Group values sort
+// bytewise under the C collation.
+func buildAggregateQuery(query AggregateQuery) (string, []interface{}) {
+	q := &logQuery{}
+
+	var columns []string
+	if unit, ok := truncUnits[query.Interval]; ok {
+		columns = append(columns, fmt.Sprintf("date_trunc('%s', timestamp, 'UTC')", unit))
+	} else if query.Interval > 0 {
+		width := q.param(int64(query.Interval / time.Second))
+		columns = append(columns, fmt.Sprintf("to_timestamp(floor(extract(epoch FROM timestamp) / %[1]s::bigint) * %[1]s::bigint)", width))
+	}
+	for _, dimension := range query.GroupBy {
+		column := dimension
+		if key, ok := strings.CutPrefix(dimension, GroupByFieldPrefix); ok {
+			column = "fields->>" + q.param(key)
+		}
+		columns = append(columns, column+` COLLATE "C"`)
+	}
+
+	q.sql.WriteString("SELECT " + strings.Join(append(columns, "count(*)"), ", ") + " FROM logs WHERE 1=1")
+	q.conditions(query.selection())
+
+	if len(columns) > 0 {
+		positions := make([]string, len(columns))
+		for i := range columns {
+			positions[i] = strconv.Itoa(i + 1)
+		}
+		q.sql.WriteString(" GROUP BY " + strings.Join(positions, ", "))
+		q.sql.WriteString(" ORDER BY " + strings.Join(positions, " NULLS FIRST, ") + " NULLS FIRST")
+	}
+	if query.Limit > 0 {
+		q.sql.WriteString(" LIMIT " + q.param(query.Limit))
+	}
+	return q.sql.String(), q.args
+}
+
+// conditions appends the conditions of filter that select logs, leaving
+// out its cursor, and returns the bound text search query for ranking
+func (q *logQuery) conditions(filter QueryFilter) string {
+	if filter.Service != "" {
+		q.where("service = %s", filter.Service)
+	}
+	if filter.Level != "" {
+		q.where("level = %s", filter.Level)
+	}
+
+	// Bounds on the partition key let the planner skip partitions
+	// outside the requested time range
+	if filter.StartTime != nil {
+		q.where("timestamp >= %s", filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		q.where("timestamp <= %s", filter.EndTime)
+	}
+
+	for _, field := range filter.Fields {
+		q.sql.WriteString(" AND " + q.fieldCondition(field))
+	}
+	if filter.Expr != nil {
+		q.sql.WriteString(" AND " + q.exprCondition(filter.Expr))
+	}
+
+	if filter.Text == "" {
+		return ""
+	}
+	tsquery := fmt.Sprintf("websearch_to_tsquery('%s', %s)", searchConfig, q.param(filter.Text))
+	q.sql.WriteString(" AND search @@ " + tsquery)
+	return tsquery
+}
+
+// fieldCondition compiles a field filter to the containment (@>) and key
+// existence (?) operators served by the GIN index on fields. Negations are
+// wrapped in COALESCE so rows without fields still match them. Numeric
+// comparisons cannot use the index and only apply to number values.
+func (q *logQuery) fieldCondition(f FieldFilter) string {
+	switch f.Op {
+	case FieldEquals:
+		return q.equals(f.Key, f.Values)
+	case FieldNotEquals:
+		return fmt.Sprintf("NOT COALESCE(%s, false)", q.equals(f.Key, f.Values))
+	case FieldExists:
+		return "fields ? " + q.param(f.Key)
+	case FieldNotExists:
+		return fmt.Sprintf("NOT COALESCE(fields ? %s, false)", q.param(f.Key))
+	case FieldPrefix:
+		key := q.param(f.Key)
+		return fmt.Sprintf("fields ? %s AND jsonb_typeof(fields->%s) = 'string' AND fields->>%s LIKE %s",
+			key, key, key, q.param(escapeLike(f.Values[0])+"%"))
+	case FieldIn:
+		return q.equals(f.Key, f.Values)
+	case FieldGreater, FieldGreaterOrEqual, FieldLess, FieldLessOrEqual:
+		// CASE keeps strings from reaching the numeric cast
+		key := q.param(f.Key)
+		bound, _ := parseNumber(f.Values[0])
+		return fmt.Sprintf("CASE WHEN jsonb_typeof(fields->%s) = 'number' THEN (fields->>%s)::numeric %s %s END",
+			key, key, comparisonOperators[f.Op], q.param(bound))
+	}
+	return "FALSE"
+}
+
+var comparisonOperators = map[FieldOp]string{
+	FieldGreater:        ">",
+	FieldGreaterOrEqual: ">=",
+	FieldLess:           "<",
+	FieldLessOrEqual:    "<=",
+}
+
+// exprColumns are the columns tested by terms on keys other than fields
+var exprColumns = map[string]string{
+	querylang.KeyService: "service",
+	querylang.KeyLevel:   "level",
+	querylang.KeyMessage: "message",
+}
+
+// exprCondition compiles a query language expression into a condition,
+// with every value bound as a parameter. Conditions on fields can be NULL,
+// so negations are wrapped in COALESCE to match matchesExpr.
+func (q *logQuery) exprCondition(expr querylang.Expr) string {
+	switch e := expr.(type) {
+	case *querylang.And:
+		return "(" + q.exprCondition(e.Left) + " AND " + q.exprCondition(e.Right) + ")"
+	case *querylang.Or:
+		return "(" + q.exprCondition(e.Left) + " OR " + q.exprCondition(e.Right) + ")"
+	case *querylang.Not:
+		return fmt.Sprintf("NOT COALESCE(%s, false)", q.exprCondition(e.Expr))
+	case *querylang.Term:
+		return q.termCondition(e)
+	}
+	return "FALSE"
+}
+
+func (q *logQuery) termCondition(term *querylang.Term) string {
+	if filter, ok := fieldTerm(term); ok {
+		return "(" + q.fieldCondition(filter) + ")"
+	}
+	column, ok := exprColumns[term.Key]
+	if !ok {
+		return "FALSE"
+	}
+	switch term.Op {
+	case querylang.OpEquals:
+		return column + " = " + q.param(term.Value)
+	case querylang.OpPrefix:
+		return column + " LIKE " + q.param(escapeLike(term.Value)+"%")
+	case querylang.OpMatches:
+		return fmt.Sprintf("search @@ websearch_to_tsquery('%s', %s)", searchConfig, q.param(term.Value))
+	}
+	return "FALSE"
+}
+
+// equals matches key against any of values. It uses one containment per
+// typed reading of each value, rather than @> ANY(...), so each can use
+// the index and the results are combined with a bitmap OR.
+func (q *logQuery) equals(key string, values []string) string {
+	var conditions []string
+	for _, value := range values {
+		for _, typed := range textValues(value) {
+			conditions = append(conditions, "fields @> "+q.containment(key, typed))
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// containment binds the JSONB object {key: value}
+func (q *logQuery) containment(key string, value any) string {
+	object, _ := json.Marshal(map[string]any{key: value})
+	return q.param(string(object)) + "::jsonb"
+}
+
+// escapeLike escapes the LIKE wildcards in a literal
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// scanLogEntries reads every row selected with selectColumns
+func scanLogEntries(rows *sql.Rows) ([]LogEntry, error) {
+	var entries []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		var fieldsJSON []byte
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Timestamp,
+			&entry.Service,
+			&entry.Level,
+			&entry.Message,
+			&fieldsJSON,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if len(fieldsJSON) > 0 {
+			if err := json.Unmarshal(fieldsJSON, &entry.Fields); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal fields: %w", err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// scanBuckets reads every row selected by buildAggregateQuery
+func scanBuckets(rows *sql.Rows, query AggregateQuery) ([]Bucket, error) {
+	buckets := []Bucket{}
+	for rows.Next() {
+		bucket := Bucket{Group: make(map[string]string, len(query.GroupBy))}
+		values := make([]sql.NullString, len(query.GroupBy))
+
+		var targets []interface{}
+		if query.Interval > 0 {
+			targets = append(targets, &bucket.Start)
+		}
+		for i := range values {
+			targets = append(targets, &values[i])
+		}
+		targets = append(targets, &bucket.Count)
+
+		if err := rows.Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if bucket.Count == 0 {
+			// An ungrouped count returns a row even when nothing matched
+			continue
+		}
+		bucket.Start = bucket.Start.UTC()
+		for i, value := range values {
+			if value.Valid {
+				bucket.Group[query.GroupBy[i]] = value.String
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return buckets, nil
+}
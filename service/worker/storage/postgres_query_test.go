@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/querylang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSelectQuery(t *testing.T) {
+	t.Run("no filters", func(t *testing.T) {
+		query, args := buildSelectQuery(QueryFilter{})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1 ORDER BY timestamp DESC, id DESC", query)
+		assert.Empty(t, args)
+	})
+
+	t.Run("all filters", func(t *testing.T) {
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		end := start.Add(time.Hour)
+		query, args := buildSelectQuery(QueryFilter{
+			Service:   "api",
+			Level:     "error",
+			StartTime: &start,
+			EndTime:   &end,
+			Text:      "connection refused",
+			Limit:     10,
+			Offset:    20,
+		})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1"+
+			" AND service = $1 AND level = $2 AND timestamp >= $3 AND timestamp <= $4"+
+			" AND search @@ websearch_to_tsquery('english', $5)"+
+			" ORDER BY timestamp DESC, id DESC LIMIT $6 OFFSET $7", query)
+		assert.Equal(t, []interface{}{"api", "error", &start, &end, "connection refused", 10, 20}, args)
+	})
+
+	t.Run("ranked text search", func(t *testing.T) {
+		query, args := buildSelectQuery(QueryFilter{Text: "timeout", Rank: true})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1"+
+			" AND search @@ websearch_to_tsquery('english', $1)"+
+			" ORDER BY ts_rank(search, websearch_to_tsquery('english', $1)) DESC, timestamp DESC, id DESC", query)
+		assert.Equal(t, []interface{}{"timeout"}, args)
+	})
+
+	t.Run("rank without text keeps time order", func(t *testing.T) {
+		query, _ := buildSelectQuery(QueryFilter{Rank: true})
+		assert.Contains(t, query, "ORDER BY timestamp DESC, id DESC")
+	})
+
+	t.Run("field filters", func(t *testing.T) {
+		query, args := buildSelectQuery(QueryFilter{Fields: []FieldFilter{
+			{Key: "user", Op: FieldEquals, Values: []string{"alice"}},
+			{Key: "region", Op: FieldNotEquals, Values: []string{"eu"}},
+			{Key: "trace", Op: FieldExists},
+			{Key: "span", Op: FieldNotExists},
+			{Key: "path", Op: FieldPrefix, Values: []string{"/v0_%"}},
+			{Key: "status", Op: FieldIn, Values: []string{"500", "503"}},
+			{Key: "latency_ms", Op: FieldGreater, Values: []string{"250.5"}},
+		}})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1"+
+			" AND fields @> $1::jsonb"+
+			" AND NOT COALESCE(fields @> $2::jsonb, false)"+
+			" AND fields ? $3"+
+			" AND NOT COALESCE(fields ? $4, false)"+
+			" AND fields ? $5 AND jsonb_typeof(fields->$5) = 'string' AND fields->>$5 LIKE $6"+
+			" AND (fields @> $7::jsonb OR fields @> $8::jsonb OR fields @> $9::jsonb OR fields @> $10::jsonb)"+
+			" AND CASE WHEN jsonb_typeof(fields->$11) = 'number' THEN (fields->>$11)::numeric > $12 END"+
+			" ORDER BY timestamp DESC, id DESC", query)
+		assert.Equal(t, []interface{}{
+			`{"user":"alice"}`, `{"region":"eu"}`, "trace", "span", "path", `/v0\_\%%`,
+			`{"status":"500"}`, `{"status":500}`, `{"status":"503"}`, `{"status":503}`, "latency_ms", 250.5,
+		}, args)
+	})
+
+	t.Run("query language", func(t *testing.T) {
+		expr, err := querylang.Parse(`service:api* AND level:(error OR warn) AND NOT fields.user:alice AND message~"timed out" AND fields.latency_ms>500`)
+		require.NoError(t, err)
+		query, args := buildSelectQuery(QueryFilter{Level: "error", Expr: expr})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1 AND level = $1"+
+			" AND ((((service LIKE $2 AND (level = $3 OR level = $4))"+
+			" AND NOT COALESCE((fields @> $5::jsonb), false))"+
+			" AND search @@ websearch_to_tsquery('english', $6))"+
+			" AND (CASE WHEN jsonb_typeof(fields->$7) = 'number' THEN (fields->>$7)::numeric > $8 END))"+
+			" ORDER BY timestamp DESC, id DESC", query)
+		assert.Equal(t, []interface{}{"error", "api%", "error", "warn", `{"user":"alice"}`, "timed out", "latency_ms", 500.0}, args)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		after := Cursor{Timestamp: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ID: 42}
+		query, args := buildSelectQuery(QueryFilter{Service: "api", After: &after, Limit: 10})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1"+
+			" AND service = $1 AND timestamp <= $2 AND (timestamp, id) < ($2, $3)"+
+			" ORDER BY timestamp DESC, id DESC LIMIT $4", query)
+		assert.Equal(t, []interface{}{"api", after.Timestamp, int64(42), 10}, args)
+	})
+
+	t.Run("ascending cursor", func(t *testing.T) {
+		after := Cursor{Timestamp: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ID: 42}
+		query, _ := buildSelectQuery(QueryFilter{After: &after, Ascending: true})
+		assert.Equal(t, "SELECT "+selectColumns+" FROM logs WHERE 1=1"+
+			" AND timestamp >= $1 AND (timestamp, id) > ($1, $2)"+
+			" ORDER BY timestamp ASC, id ASC", query)
+	})
+}
+
+func TestBuildAggregateQuery(t *testing.T) {
+	t.Run("ungrouped count", func(t *testing.T) {
+		query, args := buildAggregateQuery(AggregateQuery{Filter: QueryFilter{Service: "api", Limit: 5, Rank: true}})
+		assert.Equal(t, "SELECT count(*) FROM logs WHERE 1=1 AND service = $1", query)
+		assert.Equal(t, []interface{}{"api"}, args)
+	})
+
+	t.Run("errors per service per minute", func(t *testing.T) {
+		query, args := buildAggregateQuery(AggregateQuery{
+			Filter:   QueryFilter{Level: "error"},
+			GroupBy:  []string{GroupByService},
+			Interval: time.Minute,
+			Limit:    100,
+		})
+		assert.Equal(t, `SELECT date_trunc('minute', timestamp, 'UTC'), service COLLATE "C", count(*) FROM logs WHERE 1=1`+
+			" AND level = $1 GROUP BY 1, 2 ORDER BY 1 NULLS FIRST, 2 NULLS FIRST LIMIT $2", query)
+		assert.Equal(t, []interface{}{"error", 100}, args)
+	})
+
+	t.Run("binned interval and field", func(t *testing.T) {
+		query, args := buildAggregateQuery(AggregateQuery{
+			Filter:   QueryFilter{Fields: []FieldFilter{{Key: "status", Op: FieldExists}}},
+			GroupBy:  []string{GroupByFieldPrefix + "status", GroupByLevel},
+			Interval: 5 * time.Minute,
+		})
+		assert.Equal(t, "SELECT to_timestamp(floor(extract(epoch FROM timestamp) / $1::bigint) * $1::bigint),"+
+			` fields->>$2 COLLATE "C", level COLLATE "C", count(*) FROM logs WHERE 1=1`+
+			" AND fields ? $3 GROUP BY 1, 2, 3 ORDER BY 1 NULLS FIRST, 2 NULLS FIRST, 3 NULLS FIRST", query)
+		assert.Equal(t, []interface{}{int64(300), "status", "status"}, args)
+	})
+}
@@ -0,0 +1,71 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabaseEnv names the connection string of a disposable database;
+// the Postgres suite is skipped when it is unset.
+const testDatabaseEnv = "ECHORIS_TEST_DATABASE_URL"
+
+func testDatabaseURL(t *testing.T) string {
+	t.Helper()
+	connString := os.Getenv(testDatabaseEnv)
+	if connString == "" {
+		t.Skipf("%s not set, skipping PostgreSQL tests", testDatabaseEnv)
+	}
+	return connString
+}
+
+func TestPostgresStorage_Suite(t *testing.T) {
+	connString := testDatabaseURL(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewPostgresStorage(connString)
+		require.NoError(t, err)
+
+		db, err := sql.Open("postgres", connString)
+		require.NoError(t, err)
+		defer db.Close()
+		_, err = db.Exec("TRUNCATE logs")
+		require.NoError(t, err)
+
+		return store
+	})
+}
+
+func TestMigrator_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	connString := testDatabaseURL(t)
+
+	migrator, err := storage.NewPostgresMigrator(connString)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	latest := migrator.Migrations()[len(migrator.Migrations())-1]
+	rolledBack, err := migrator.Down(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest.Version, rolledBack.Version)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, statuses[len(statuses)-1].Applied())
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// searchConfig is the PostgreSQL text search configuration used to build
+// the logs.search column (see migration 0003) and to parse queries
+const searchConfig = "english"
+
+// searchTerms splits a text query into lower-cased terms
+func searchTerms(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
+
+// matchesText reports whether message contains every term of text,
+// ignoring case. Backends without a full-text index use it in place of
+// PostgreSQL's stemmed matching.
+func matchesText(message, text string) bool {
+	message = strings.ToLower(message)
+	for _, term := range searchTerms(text) {
+		if !strings.Contains(message, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// textScore counts the occurrences of the terms of text in message
+func textScore(message, text string) int {
+	message = strings.ToLower(message)
+	score := 0
+	for _, term := range searchTerms(text) {
+		score += strings.Count(message, term)
+	}
+	return score
+}
+
+// sortByRelevance orders entries by descending textScore, keeping the
+// existing order between entries that score the same
+func sortByRelevance(entries []LogEntry, text string) {
+	type scoredEntry struct {
+		entry LogEntry
+		score int
+	}
+
+	scored := make([]scoredEntry, len(entries))
+	for i, entry := range entries {
+		scored[i] = scoredEntry{entry: entry, score: textScore(entry.Message, text)}
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+
+	for i := range scored {
+		entries[i] = scored[i].entry
+	}
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"time"
+
+	"github.com/jgfranco17/echoris/service/querylang"
 )
 
 // LogEntry represents a log entry in the database
@@ -12,8 +16,8 @@ type LogEntry struct {
 	Service   string
 	Level     string
 	Message   string
-	Fields    map[string]string
-	CreatedAt time.Time
+	Fields    map[string]any // Values as decoded by encoding/json
+	CreatedAt time.Time      // When the log was received; set on insert when zero
 }
 
 // QueryFilter represents filters for querying logs
@@ -22,24 +26,149 @@ type QueryFilter struct {
 	Level     string
 	StartTime *time.Time
 	EndTime   *time.Time
+	Fields    []FieldFilter  // Conditions on Fields, all of which must hold
+	Text      string         // Full-text search over messages
+	Expr      querylang.Expr // Parsed query language expression, which must also hold
+	Rank      bool           // Order text matches by relevance instead of time
+	Ascending bool           // Return the oldest logs first instead of the newest
+	After     *Cursor        // Only return logs that sort after this position
 	Limit     int
 	Offset    int
 }
 
+// Validate checks the parts of the filter that can be malformed
+func (f QueryFilter) Validate() error {
+	if f.After != nil && f.Rank && f.Text != "" {
+		return fmt.Errorf("cursors cannot be combined with relevance ranking")
+	}
+	for _, field := range f.Fields {
+		if err := field.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches reports whether entry satisfies every set field of the filter.
+// Text is matched by substring, as in the memory and file backends.
+func (f QueryFilter) Matches(entry LogEntry) bool {
+	if f.Service != "" && entry.Service != f.Service {
+		return false
+	}
+	if f.Level != "" && entry.Level != f.Level {
+		return false
+	}
+	if f.StartTime != nil && entry.Timestamp.Before(*f.StartTime) {
+		return false
+	}
+	if f.EndTime != nil && entry.Timestamp.After(*f.EndTime) {
+		return false
+	}
+	if !f.pastCursor(entry.Timestamp, entry.ID) {
+		return false
+	}
+	if f.Text != "" && !matchesText(entry.Message, f.Text) {
+		return false
+	}
+	if f.Expr != nil && !matchesExpr(entry, f.Expr) {
+		return false
+	}
+	return matchesFields(entry.Fields, f.Fields)
+}
+
+// Cursor is a position in the order of logs, which sorts by timestamp and
+// then by id
+type Cursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+// CursorAt returns the position of entry
+func CursorAt(entry LogEntry) Cursor {
+	return Cursor{Timestamp: entry.Timestamp, ID: entry.ID}
+}
+
+// Compare returns -1, 0 or +1 depending on whether a log with the given
+// timestamp and id sorts before, at or after the cursor in oldest-first order
+func (c Cursor) Compare(timestamp time.Time, id int64) int {
+	if order := timestamp.Compare(c.Timestamp); order != 0 {
+		return order
+	}
+	return cmp.Compare(id, c.ID)
+}
+
+// pastCursor reports whether a log sorts after filter.After in the
+// filter's order, or true when the filter has no cursor
+func (f QueryFilter) pastCursor(timestamp time.Time, id int64) bool {
+	if f.After == nil {
+		return true
+	}
+	if f.Ascending {
+		return f.After.Compare(timestamp, id) > 0
+	}
+	return f.After.Compare(timestamp, id) < 0
+}
+
+// DeleteFilter selects logs for deletion by age and, optionally, by
+// service and level. Empty lists place no constraint.
+type DeleteFilter struct {
+	OlderThan       time.Duration
+	Services        []string // Only delete logs from these services
+	Levels          []string // Only delete logs with these levels
+	ExcludeServices []string // Never delete logs from these services
+	ExcludeLevels   []string // Never delete logs with these levels
+}
+
+// Scoped reports whether the filter constrains services or levels
+func (f DeleteFilter) Scoped() bool {
+	return len(f.Services) > 0 || len(f.Levels) > 0 || len(f.ExcludeServices) > 0 || len(f.ExcludeLevels) > 0
+}
+
+// Matches reports whether a log with the given service and level falls
+// within the filter's scope, regardless of its age
+func (f DeleteFilter) Matches(service, level string) bool {
+	if len(f.Services) > 0 && !contains(f.Services, service) {
+		return false
+	}
+	if len(f.Levels) > 0 && !contains(f.Levels, level) {
+		return false
+	}
+	return !contains(f.ExcludeServices, service) && !contains(f.ExcludeLevels, level)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Storage defines the interface for log storage operations
 type Storage interface {
 	// InsertLog inserts a single log entry
 	InsertLog(ctx context.Context, entry LogEntry) error
 
-	// InsertLogs inserts multiple log entries in a batch
+	// InsertLogs inserts multiple log entries in a batch, setting the id
+	// each was given
 	InsertLogs(ctx context.Context, entries []LogEntry) error
 
 	// QueryLogs retrieves logs based on filters
 	QueryLogs(ctx context.Context, filter QueryFilter) ([]LogEntry, error)
 
-	// DeleteOldLogs deletes logs older than the specified duration
+	// AggregateLogs counts the logs matching a query in ordered buckets
+	AggregateLogs(ctx context.Context, query AggregateQuery) ([]Bucket, error)
+
+	// ListCatalog lists the distinct values of a kind with their counts
+	ListCatalog(ctx context.Context, query CatalogQuery) ([]CatalogEntry, error)
+
+	// DeleteOldLogs deletes logs whose timestamp is older than the specified duration
 	DeleteOldLogs(ctx context.Context, olderThan time.Duration) (int64, error)
 
+	// DeleteLogs deletes logs older than filter.OlderThan within the filter's scope
+	DeleteLogs(ctx context.Context, filter DeleteFilter) (int64, error)
+
 	// Close closes the storage connection
 	Close() error
 
@@ -0,0 +1,633 @@
+// Package storagetest provides a behavioral test suite that every
+// storage.Storage backend is expected to pass.
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/querylang"
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory creates an empty, ready to use storage backend for a single test.
+// The suite closes the returned storage when the test finishes.
+type Factory func(t *testing.T) storage.Storage
+
+// BaseTime is the timestamp of the oldest entry inserted by SeedEntries
+var BaseTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// SeedEntries returns a fixed set of entries spread one minute apart
+func SeedEntries() []storage.LogEntry {
+	return []storage.LogEntry{
+		{Timestamp: BaseTime, Service: "api", Level: "info", Message: "first"},
+		{Timestamp: BaseTime.Add(1 * time.Minute), Service: "api", Level: "error", Message: "second"},
+		{Timestamp: BaseTime.Add(2 * time.Minute), Service: "worker", Level: "info", Message: "third"},
+		{Timestamp: BaseTime.Add(3 * time.Minute), Service: "api", Level: "info", Message: "fourth", Fields: map[string]any{"user": "alice"}},
+	}
+}
+
+// Messages extracts the message of each entry, preserving order
+func Messages(entries []storage.LogEntry) []string {
+	result := make([]string, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e.Message)
+	}
+	return result
+}
+
+// Run executes the full behavioral suite against the backend built by newStorage
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("QueryLogs", func(t *testing.T) { testQueryLogs(t, newStorage) })
+	t.Run("TextSearch", func(t *testing.T) { testTextSearch(t, newStorage) })
+	t.Run("FieldFilters", func(t *testing.T) { testFieldFilters(t, newStorage) })
+	t.Run("TypedFields", func(t *testing.T) { testTypedFields(t, newStorage) })
+	t.Run("QueryLanguage", func(t *testing.T) { testQueryLanguage(t, newStorage) })
+	t.Run("CursorPagination", func(t *testing.T) { testCursorPagination(t, newStorage) })
+	t.Run("AggregateLogs", func(t *testing.T) { testAggregateLogs(t, newStorage) })
+	t.Run("ListCatalog", func(t *testing.T) { testListCatalog(t, newStorage) })
+	t.Run("DeleteOldLogs", func(t *testing.T) { testDeleteOldLogs(t, newStorage) })
+	t.Run("DeleteLogs", func(t *testing.T) { testDeleteLogs(t, newStorage) })
+	t.Run("HealthCheck", func(t *testing.T) { testHealthCheck(t, newStorage) })
+}
+
+func open(t *testing.T, newStorage Factory) storage.Storage {
+	t.Helper()
+	store := newStorage(t)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func seeded(t *testing.T, newStorage Factory) storage.Storage {
+	t.Helper()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(context.Background(), SeedEntries()))
+	return store
+}
+
+func testQueryLogs(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+
+	t.Run("newest first with ids assigned", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fourth", "third", "second", "first"}, Messages(entries))
+		assert.Greater(t, entries[0].ID, entries[3].ID)
+		assert.Equal(t, "alice", entries[0].Fields["user"])
+		assert.True(t, entries[0].Timestamp.Equal(BaseTime.Add(3*time.Minute)))
+		assert.False(t, entries[0].CreatedAt.IsZero())
+	})
+
+	t.Run("sets the assigned ids on inserted entries", func(t *testing.T) {
+		store := open(t, newStorage)
+		inserted := SeedEntries()
+		require.NoError(t, store.InsertLogs(ctx, inserted))
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Ascending: true})
+		require.NoError(t, err)
+		require.Len(t, entries, len(inserted))
+		for i := range inserted {
+			assert.NotZero(t, inserted[i].ID)
+			assert.Equal(t, entries[i].ID, inserted[i].ID)
+		}
+	})
+
+	t.Run("single insert", func(t *testing.T) {
+		store := open(t, newStorage)
+		require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: BaseTime, Service: "api", Level: "info", Message: "only"}))
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"only"}, Messages(entries))
+	})
+
+	t.Run("keeps a given created at", func(t *testing.T) {
+		store := open(t, newStorage)
+		received := BaseTime.Add(time.Hour)
+		require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+			{Timestamp: BaseTime, Service: "api", Level: "info", Message: "received", CreatedAt: received},
+		}))
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.True(t, entries[0].CreatedAt.Equal(received), "created at %s", entries[0].CreatedAt)
+	})
+
+	t.Run("service and level", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Service: "api", Level: "info"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fourth", "first"}, Messages(entries))
+	})
+
+	t.Run("time range is inclusive", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		start := BaseTime.Add(1 * time.Minute)
+		end := BaseTime.Add(2 * time.Minute)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{StartTime: &start, EndTime: &end})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"third", "second"}, Messages(entries))
+	})
+
+	t.Run("oldest first", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Ascending: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first", "second", "third", "fourth"}, Messages(entries))
+
+		start := BaseTime.Add(1 * time.Minute)
+		entries, err = store.QueryLogs(ctx, storage.QueryFilter{Ascending: true, StartTime: &start, Service: "api", Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"second"}, Messages(entries))
+	})
+
+	t.Run("limit and offset", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Limit: 2, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"third", "second"}, Messages(entries))
+
+		entries, err = store.QueryLogs(ctx, storage.QueryFilter{Offset: 10})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		store := seeded(t, newStorage)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Service: "missing"})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func testTextSearch(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime, Service: "db", Level: "error", Message: "database connection refused"},
+		{Timestamp: BaseTime.Add(1 * time.Minute), Service: "api", Level: "info", Message: "user logged in"},
+		{Timestamp: BaseTime.Add(2 * time.Minute), Service: "db", Level: "warn", Message: "connection pool exhausted, connection retry"},
+		{Timestamp: BaseTime.Add(3 * time.Minute), Service: "api", Level: "error", Message: "Connection reset by peer"},
+	}))
+
+	query := func(filter storage.QueryFilter) []string {
+		t.Helper()
+		entries, err := store.QueryLogs(ctx, filter)
+		require.NoError(t, err)
+		return Messages(entries)
+	}
+
+	t.Run("matches ignoring case, newest first", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"Connection reset by peer",
+			"connection pool exhausted, connection retry",
+			"database connection refused",
+		}, query(storage.QueryFilter{Text: "CONNECTION"}))
+	})
+
+	t.Run("requires every term", func(t *testing.T) {
+		assert.Equal(t, []string{"database connection refused"}, query(storage.QueryFilter{Text: "connection refused"}))
+	})
+
+	t.Run("combines with other filters", func(t *testing.T) {
+		assert.Equal(t, []string{"Connection reset by peer"}, query(storage.QueryFilter{Text: "connection", Service: "api"}))
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		assert.Empty(t, query(storage.QueryFilter{Text: "timeout"}))
+	})
+
+	t.Run("ranked by relevance", func(t *testing.T) {
+		ranked := query(storage.QueryFilter{Text: "connection", Rank: true})
+		require.Len(t, ranked, 3)
+		assert.Equal(t, "connection pool exhausted, connection retry", ranked[0])
+
+		assert.Len(t, query(storage.QueryFilter{Text: "connection", Rank: true, Limit: 1, Offset: 2}), 1)
+	})
+}
+
+func testFieldFilters(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime, Service: "api", Level: "info", Message: "alice-eu", Fields: map[string]any{"user": "alice", "region": "eu"}},
+		{Timestamp: BaseTime.Add(1 * time.Minute), Service: "api", Level: "info", Message: "bob-us", Fields: map[string]any{"user": "bob", "region": "us"}},
+		{Timestamp: BaseTime.Add(2 * time.Minute), Service: "api", Level: "info", Message: "albert", Fields: map[string]any{"user": "albert"}},
+		{Timestamp: BaseTime.Add(3 * time.Minute), Service: "api", Level: "info", Message: "anonymous"},
+	}))
+
+	cases := []struct {
+		name     string
+		filters  []storage.FieldFilter
+		expected []string
+	}{
+		{"equals", []storage.FieldFilter{{Key: "user", Op: storage.FieldEquals, Values: []string{"alice"}}}, []string{"alice-eu"}},
+		{"not equals includes missing keys", []storage.FieldFilter{{Key: "region", Op: storage.FieldNotEquals, Values: []string{"eu"}}}, []string{"anonymous", "albert", "bob-us"}},
+		{"exists", []storage.FieldFilter{{Key: "region", Op: storage.FieldExists}}, []string{"bob-us", "alice-eu"}},
+		{"not exists", []storage.FieldFilter{{Key: "region", Op: storage.FieldNotExists}}, []string{"anonymous", "albert"}},
+		{"prefix", []storage.FieldFilter{{Key: "user", Op: storage.FieldPrefix, Values: []string{"al"}}}, []string{"albert", "alice-eu"}},
+		{"prefix is literal", []storage.FieldFilter{{Key: "user", Op: storage.FieldPrefix, Values: []string{"a_"}}}, []string{}},
+		{"in", []storage.FieldFilter{{Key: "user", Op: storage.FieldIn, Values: []string{"bob", "albert", "carol"}}}, []string{"albert", "bob-us"}},
+		{"all filters must hold", []storage.FieldFilter{
+			{Key: "user", Op: storage.FieldPrefix, Values: []string{"al"}},
+			{Key: "region", Op: storage.FieldEquals, Values: []string{"eu"}},
+		}, []string{"alice-eu"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := store.QueryLogs(ctx, storage.QueryFilter{Fields: tc.filters})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, Messages(entries))
+		})
+	}
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := store.QueryLogs(ctx, storage.QueryFilter{Fields: []storage.FieldFilter{{Key: "user", Op: storage.FieldEquals}}})
+		assert.Error(t, err)
+	})
+}
+
+func testTypedFields(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime, Service: "api", Level: "info", Message: "fast", Fields: map[string]any{
+			"latency_ms": 120.0, "status": 200.0, "cached": true, "request": map[string]any{"method": "GET", "tags": []any{"a", "b"}},
+		}},
+		{Timestamp: BaseTime.Add(1 * time.Minute), Service: "api", Level: "info", Message: "slow", Fields: map[string]any{
+			"latency_ms": 750.5, "status": 503.0, "cached": false,
+		}},
+		{Timestamp: BaseTime.Add(2 * time.Minute), Service: "api", Level: "info", Message: "text", Fields: map[string]any{
+			"latency_ms": "900", "status": "200", "note": nil,
+		}},
+	}))
+
+	t.Run("keeps value types", func(t *testing.T) {
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Ascending: true})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, map[string]any{
+			"latency_ms": 120.0, "status": 200.0, "cached": true, "request": map[string]any{"method": "GET", "tags": []any{"a", "b"}},
+		}, entries[0].Fields)
+		assert.Equal(t, map[string]any{"latency_ms": "900", "status": "200", "note": nil}, entries[2].Fields)
+	})
+
+	cases := []struct {
+		name     string
+		filter   storage.FieldFilter
+		expected []string
+	}{
+		{"greater", storage.FieldFilter{Key: "latency_ms", Op: storage.FieldGreater, Values: []string{"500"}}, []string{"slow"}},
+		{"greater or equal", storage.FieldFilter{Key: "latency_ms", Op: storage.FieldGreaterOrEqual, Values: []string{"120"}}, []string{"slow", "fast"}},
+		{"less", storage.FieldFilter{Key: "latency_ms", Op: storage.FieldLess, Values: []string{"750.5"}}, []string{"fast"}},
+		{"less or equal", storage.FieldFilter{Key: "latency_ms", Op: storage.FieldLessOrEqual, Values: []string{"1e3"}}, []string{"slow", "fast"}},
+		{"equals matches numbers and strings", storage.FieldFilter{Key: "status", Op: storage.FieldEquals, Values: []string{"200"}}, []string{"text", "fast"}},
+		{"equals matches booleans", storage.FieldFilter{Key: "cached", Op: storage.FieldEquals, Values: []string{"true"}}, []string{"fast"}},
+		{"in matches numbers", storage.FieldFilter{Key: "status", Op: storage.FieldIn, Values: []string{"500", "503"}}, []string{"slow"}},
+		{"not equals", storage.FieldFilter{Key: "cached", Op: storage.FieldNotEquals, Values: []string{"false"}}, []string{"text", "fast"}},
+		{"prefix only matches strings", storage.FieldFilter{Key: "status", Op: storage.FieldPrefix, Values: []string{"2"}}, []string{"text"}},
+		{"null values exist", storage.FieldFilter{Key: "note", Op: storage.FieldExists}, []string{"text"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := store.QueryLogs(ctx, storage.QueryFilter{Fields: []storage.FieldFilter{tc.filter}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, Messages(entries))
+		})
+	}
+
+	t.Run("comparison needs a number", func(t *testing.T) {
+		_, err := store.QueryLogs(ctx, storage.QueryFilter{Fields: []storage.FieldFilter{{Key: "latency_ms", Op: storage.FieldGreater, Values: []string{"fast"}}}})
+		assert.Error(t, err)
+	})
+}
+
+func testQueryLanguage(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime, Service: "api", Level: "error", Message: "request timeout", Fields: map[string]any{"user": "alice", "latency_ms": 3000.0}},
+		{Timestamp: BaseTime.Add(1 * time.Minute), Service: "api", Level: "warn", Message: "slow request", Fields: map[string]any{"user": "bob", "latency_ms": 800.0}},
+		{Timestamp: BaseTime.Add(2 * time.Minute), Service: "api-gateway", Level: "info", Message: "request served", Fields: map[string]any{"user": "alice", "latency_ms": "fast"}},
+		{Timestamp: BaseTime.Add(3 * time.Minute), Service: "db", Level: "error", Message: "connection timeout"},
+	}))
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{`service:api AND level:(error OR warn) AND fields.user:"alice" AND message~"timeout"`, []string{"request timeout"}},
+		{`level:(error OR warn)`, []string{"connection timeout", "slow request", "request timeout"}},
+		{`service:api*`, []string{"request served", "slow request", "request timeout"}},
+		{`message~timeout OR fields.latency_ms>=800`, []string{"connection timeout", "slow request", "request timeout"}},
+		{`fields.latency_ms<1000`, []string{"slow request"}},
+		{`NOT fields.latency_ms<1000`, []string{"connection timeout", "request served", "request timeout"}},
+		{`NOT fields.user:alice`, []string{"connection timeout", "slow request"}},
+		{`NOT fields.user:*`, []string{"connection timeout"}},
+		{`fields.user:al* service:api-gateway`, []string{"request served"}},
+		{`NOT (service:api OR service:db)`, []string{"request served"}},
+		{`message:"slow request"`, []string{"slow request"}},
+		{`message:slow*`, []string{"slow request"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := querylang.Parse(tc.query)
+			require.NoError(t, err)
+			entries, err := store.QueryLogs(ctx, storage.QueryFilter{Expr: expr})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, Messages(entries))
+		})
+	}
+
+	t.Run("combined with other filters", func(t *testing.T) {
+		expr, err := querylang.Parse(`level:error`)
+		require.NoError(t, err)
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{Service: "db", Expr: expr})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"connection timeout"}, Messages(entries))
+	})
+}
+
+func testAggregateLogs(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := seeded(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime.Add(90 * time.Second), Service: "api", Level: "error", Message: "fifth", Fields: map[string]any{"status": 503.0}},
+		{Timestamp: BaseTime.Add(-time.Hour), Service: "api", Level: "error", Message: "old", Fields: map[string]any{"status": 500.0}},
+	}))
+
+	count := func(start time.Time, group map[string]string, n int64) storage.Bucket {
+		if group == nil {
+			group = map[string]string{}
+		}
+		return storage.Bucket{Start: start, Group: group, Count: n}
+	}
+
+	cases := []struct {
+		name     string
+		query    storage.AggregateQuery
+		expected []storage.Bucket
+	}{
+		{"total", storage.AggregateQuery{}, []storage.Bucket{count(time.Time{}, nil, 6)}},
+		{"by service", storage.AggregateQuery{GroupBy: []string{storage.GroupByService}}, []storage.Bucket{
+			count(time.Time{}, map[string]string{"service": "api"}, 5),
+			count(time.Time{}, map[string]string{"service": "worker"}, 1),
+		}},
+		{"errors per service per minute", storage.AggregateQuery{
+			Filter:   storage.QueryFilter{Level: "error"},
+			GroupBy:  []string{storage.GroupByService},
+			Interval: time.Minute,
+		}, []storage.Bucket{
+			count(BaseTime.Add(-time.Hour), map[string]string{"service": "api"}, 1),
+			count(BaseTime.Add(time.Minute), map[string]string{"service": "api"}, 2),
+		}},
+		{"uneven interval is aligned to the epoch", storage.AggregateQuery{
+			Filter:   storage.QueryFilter{StartTime: &BaseTime},
+			Interval: 7 * time.Minute,
+		}, []storage.Bucket{
+			count(time.Date(2025, 1, 1, 11, 59, 0, 0, time.UTC), nil, 5),
+		}},
+		{"by field with missing values first", storage.AggregateQuery{
+			GroupBy: []string{storage.GroupByLevel, storage.GroupByFieldPrefix + "status"},
+		}, []storage.Bucket{
+			count(time.Time{}, map[string]string{"level": "error"}, 1),
+			count(time.Time{}, map[string]string{"level": "error", "fields.status": "500"}, 1),
+			count(time.Time{}, map[string]string{"level": "error", "fields.status": "503"}, 1),
+			count(time.Time{}, map[string]string{"level": "info"}, 3),
+		}},
+		{"limit", storage.AggregateQuery{GroupBy: []string{storage.GroupByService}, Limit: 1}, []storage.Bucket{
+			count(time.Time{}, map[string]string{"service": "api"}, 5),
+		}},
+		{"no matches", storage.AggregateQuery{Filter: storage.QueryFilter{Service: "nobody"}}, []storage.Bucket{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			buckets, err := store.AggregateLogs(ctx, tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, buckets)
+		})
+	}
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for _, query := range []storage.AggregateQuery{
+			{GroupBy: []string{"message"}},
+			{GroupBy: []string{"fields."}},
+			{GroupBy: []string{"service", "service"}},
+			{Interval: 1500 * time.Millisecond},
+			{Filter: storage.QueryFilter{Fields: []storage.FieldFilter{{Key: "status", Op: storage.FieldGreater}}}},
+		} {
+			_, err := store.AggregateLogs(ctx, query)
+			assert.Error(t, err, query)
+		}
+	})
+}
+
+func testCursorPagination(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := seeded(t, newStorage)
+
+	// Logs sharing a timestamp are ordered by id
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime.Add(time.Minute), Service: "api", Level: "info", Message: "tie-a"},
+		{Timestamp: BaseTime.Add(time.Minute), Service: "api", Level: "info", Message: "tie-b"},
+	}))
+
+	all, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"fourth", "third", "tie-b", "tie-a", "second", "first"}, Messages(all))
+
+	paginate := func(ascending bool) []string {
+		var paged []string
+		var after *storage.Cursor
+		for pages := 0; pages < 10; pages++ {
+			entries, err := store.QueryLogs(ctx, storage.QueryFilter{After: after, Ascending: ascending, Limit: 2})
+			require.NoError(t, err)
+			if len(entries) == 0 {
+				break
+			}
+			paged = append(paged, Messages(entries)...)
+			cursor := storage.CursorAt(entries[len(entries)-1])
+			after = &cursor
+		}
+		return paged
+	}
+	assert.Equal(t, Messages(all), paginate(false))
+	assert.Equal(t, []string{"first", "second", "tie-a", "tie-b", "third", "fourth"}, paginate(true))
+
+	t.Run("combines with other filters", func(t *testing.T) {
+		cursor := storage.CursorAt(all[2])
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{After: &cursor, Level: "info"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tie-a", "first"}, Messages(entries))
+
+		entries, err = store.QueryLogs(ctx, storage.QueryFilter{After: &cursor, Level: "info", Ascending: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"third", "fourth"}, Messages(entries))
+	})
+
+	t.Run("rejects relevance ranking", func(t *testing.T) {
+		cursor := storage.CursorAt(all[0])
+		_, err := store.QueryLogs(ctx, storage.QueryFilter{After: &cursor, Text: "first", Rank: true})
+		assert.Error(t, err)
+	})
+}
+
+func testListCatalog(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: BaseTime.Add(-2 * time.Hour), Service: "api", Level: "info", Message: "a", Fields: map[string]any{"user": "alice"}},
+		{Timestamp: BaseTime, Service: "api", Level: "error", Message: "b", Fields: map[string]any{"user": "bob", "region": "eu"}},
+		{Timestamp: BaseTime.Add(30 * time.Minute), Service: "db", Level: "info", Message: "c"},
+		{Timestamp: BaseTime.Add(90 * time.Minute), Service: "api", Level: "warn", Message: "d", Fields: map[string]any{"trace": "t-1"}},
+	}))
+
+	entry := func(value string, count int64, first, last time.Time) storage.CatalogEntry {
+		return storage.CatalogEntry{Value: value, Count: count, FirstSeen: first, LastSeen: last}
+	}
+	windowStart, windowEnd := BaseTime.Add(10*time.Minute), BaseTime.Add(20*time.Minute)
+	cases := []struct {
+		name     string
+		query    storage.CatalogQuery
+		expected []storage.CatalogEntry
+	}{
+		{"services", storage.CatalogQuery{Kind: storage.CatalogServices}, []storage.CatalogEntry{
+			entry("api", 3, BaseTime.Add(-2*time.Hour), BaseTime.Add(90*time.Minute)),
+			entry("db", 1, BaseTime.Add(30*time.Minute), BaseTime.Add(30*time.Minute)),
+		}},
+		{"levels", storage.CatalogQuery{Kind: storage.CatalogLevels}, []storage.CatalogEntry{
+			entry("error", 1, BaseTime, BaseTime),
+			entry("info", 2, BaseTime.Add(-2*time.Hour), BaseTime.Add(30*time.Minute)),
+			entry("warn", 1, BaseTime.Add(90*time.Minute), BaseTime.Add(90*time.Minute)),
+		}},
+		{"field keys", storage.CatalogQuery{Kind: storage.CatalogFieldKeys}, []storage.CatalogEntry{
+			entry("region", 1, BaseTime, BaseTime),
+			entry("trace", 1, BaseTime.Add(90*time.Minute), BaseTime.Add(90*time.Minute)),
+			entry("user", 2, BaseTime.Add(-2*time.Hour), BaseTime),
+		}},
+		{"window is widened to whole hours", storage.CatalogQuery{Kind: storage.CatalogServices, Start: &windowStart, End: &windowEnd}, []storage.CatalogEntry{
+			entry("api", 1, BaseTime, BaseTime),
+			entry("db", 1, BaseTime.Add(30*time.Minute), BaseTime.Add(30*time.Minute)),
+		}},
+		{"limit", storage.CatalogQuery{Kind: storage.CatalogLevels, Limit: 1}, []storage.CatalogEntry{
+			entry("error", 1, BaseTime, BaseTime),
+		}},
+		{"empty window", storage.CatalogQuery{Kind: storage.CatalogServices, End: ptr(BaseTime.Add(-3 * time.Hour))}, []storage.CatalogEntry{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := store.ListCatalog(ctx, tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, entries)
+		})
+	}
+
+	t.Run("invalid kind", func(t *testing.T) {
+		_, err := store.ListCatalog(ctx, storage.CatalogQuery{Kind: "message"})
+		assert.Error(t, err)
+	})
+
+	t.Run("follows deletes", func(t *testing.T) {
+		store := open(t, newStorage)
+		now := time.Now().Truncate(time.Second)
+		require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+			{Timestamp: now, Service: "api", Level: "debug", Message: "noise", Fields: map[string]any{"span": "s-1"}},
+			{Timestamp: now, Service: "api", Level: "info", Message: "signal"},
+		}))
+		time.Sleep(500 * time.Millisecond)
+
+		_, err := store.DeleteLogs(ctx, storage.DeleteFilter{OlderThan: 250 * time.Millisecond, Levels: []string{"debug"}})
+		require.NoError(t, err)
+
+		levels, err := store.ListCatalog(ctx, storage.CatalogQuery{Kind: storage.CatalogLevels})
+		require.NoError(t, err)
+		assert.Equal(t, []storage.CatalogEntry{entry("info", 1, now.UTC(), now.UTC())}, levels)
+
+		keys, err := store.ListCatalog(ctx, storage.CatalogQuery{Kind: storage.CatalogFieldKeys})
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testDeleteOldLogs(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "old"}))
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, store.InsertLog(ctx, storage.LogEntry{Timestamp: time.Now(), Service: "api", Level: "info", Message: "new"}))
+
+	deleted, err := store.DeleteOldLogs(ctx, 250*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, Messages(entries))
+
+	t.Run("by timestamp rather than insertion time", func(t *testing.T) {
+		store := open(t, newStorage)
+		now := time.Now()
+		require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+			{Timestamp: now.Add(-2 * time.Hour), CreatedAt: now, Service: "api", Level: "info", Message: "late"},
+			{Timestamp: now, CreatedAt: now, Service: "api", Level: "info", Message: "recent"},
+		}))
+
+		deleted, err := store.DeleteOldLogs(ctx, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"recent"}, Messages(entries))
+	})
+}
+
+func testDeleteLogs(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	store := open(t, newStorage)
+
+	now := time.Now()
+	require.NoError(t, store.InsertLogs(ctx, []storage.LogEntry{
+		{Timestamp: now, Service: "api", Level: "info", Message: "api-info"},
+		{Timestamp: now, Service: "api", Level: "error", Message: "api-error"},
+		{Timestamp: now, Service: "worker", Level: "info", Message: "worker-info"},
+		{Timestamp: now, Service: "worker", Level: "debug", Message: "worker-debug"},
+	}))
+	time.Sleep(500 * time.Millisecond)
+
+	deleted, err := store.DeleteLogs(ctx, storage.DeleteFilter{
+		OlderThan:       250 * time.Millisecond,
+		Levels:          []string{"info"},
+		ExcludeServices: []string{"worker"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = store.DeleteLogs(ctx, storage.DeleteFilter{
+		OlderThan:     250 * time.Millisecond,
+		Services:      []string{"worker"},
+		ExcludeLevels: []string{"info"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = store.DeleteLogs(ctx, storage.DeleteFilter{OlderThan: time.Hour, Levels: []string{"error"}})
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	entries, err := store.QueryLogs(ctx, storage.QueryFilter{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"api-error", "worker-info"}, Messages(entries))
+}
+
+func testHealthCheck(t *testing.T, newStorage Factory) {
+	store := open(t, newStorage)
+	assert.NoError(t, store.HealthCheck(context.Background()))
+}
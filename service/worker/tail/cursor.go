@@ -0,0 +1,48 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor the hub did not issue
+	ErrInvalidCursor = errors.New("invalid tail cursor")
+
+	// ErrCursorExpired is returned for a cursor issued by an earlier run of
+	// the worker, or one so old that logs after it are no longer kept
+	ErrCursorExpired = errors.New("tail cursor has expired")
+)
+
+// newEpoch identifies a run of the hub, so that cursors issued before a
+// restart are recognised as expired rather than misread
+func newEpoch() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func formatCursor(epoch string, seq uint64) string {
+	return fmt.Sprintf("%s-%d", epoch, seq)
+}
+
+// parseCursor returns the position of cursor, which must have been issued
+// by this hub with every later log still kept; the caller must hold h.mu
+func (h *Hub) parseCursor(cursor string) (uint64, error) {
+	epoch, position, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+	seq, err := strconv.ParseUint(position, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	if epoch != h.epoch || seq > h.seq {
+		return 0, ErrCursorExpired
+	}
+	if h.seq-seq > uint64(len(h.history)) {
+		return 0, ErrCursorExpired
+	}
+	return seq, nil
+}
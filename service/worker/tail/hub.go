@@ -0,0 +1,248 @@
+package tail
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+)
+
+const (
+	// DefaultBuffer is the number of logs a subscriber may fall behind by
+	// before it is dropped
+	DefaultBuffer = 256
+
+	// DefaultHistory is the number of recently published logs kept for
+	// subscribers resuming from a cursor
+	DefaultHistory = 4096
+)
+
+var (
+	// ErrSlowConsumer ends a subscription whose buffer overflowed
+	ErrSlowConsumer = errors.New("subscriber fell behind and was dropped")
+
+	// ErrHubClosed ends the subscriptions of a closed hub
+	ErrHubClosed = errors.New("tail hub is closed")
+)
+
+// Event is a published log and its position in the hub's sequence
+type Event struct {
+	Seq   uint64
+	Entry storage.LogEntry
+}
+
+// Hub fans stored logs out to live subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is dropped rather than holding up
+// inserts or other subscribers. The most recent logs are kept so that a
+// subscriber can resume from a cursor without missing any.
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	buffer  int
+	closed  bool
+	epoch   string
+	seq     uint64
+	history []Event
+	keep    int
+}
+
+// NewHub creates a hub whose subscribers buffer up to buffer logs and
+// which keeps the last history logs for resuming subscribers
+func NewHub(buffer, history int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	if history < 0 {
+		history = 0
+	}
+	return &Hub{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+		epoch:  newEpoch(),
+		keep:   history,
+	}
+}
+
+// Subscribe registers a subscriber for published logs matching filter.
+// With a non-empty cursor it also returns the kept logs published after
+// the cursor that match filter; ErrCursorExpired means some of those logs
+// are no longer kept.
+func (h *Hub) Subscribe(filter storage.QueryFilter, cursor string) (*Subscription, []Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, ErrHubClosed
+	}
+
+	var backlog []Event
+	if cursor != "" {
+		after, err := h.parseCursor(cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		backlog = h.since(after, filter)
+	}
+
+	sub := &Subscription{
+		hub:       h,
+		filter:    filter,
+		start:     h.seq,
+		entries:   make(chan Event, h.buffer),
+		done:      make(chan struct{}),
+		delivered: make(map[int64]bool),
+	}
+	h.subs[sub] = struct{}{}
+	Subscribers.Inc()
+	return sub, backlog, nil
+}
+
+// Publish assigns entries the next positions in the sequence and delivers
+// them to every subscriber whose filter they match
+func (h *Hub) Publish(entries []storage.LogEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	published := make([]Event, len(entries))
+	for i, entry := range entries {
+		h.seq++
+		published[i] = Event{Seq: h.seq, Entry: entry}
+	}
+	h.remember(published)
+
+	for sub := range h.subs {
+		if !sub.deliver(published) {
+			h.remove(sub, ErrSlowConsumer)
+			DroppedSubscribers.Inc()
+		}
+	}
+}
+
+// Cursor returns the cursor that resumes after position seq
+func (h *Hub) Cursor(seq uint64) string {
+	return formatCursor(h.epoch, seq)
+}
+
+// Close ends every subscription and rejects new ones
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.remove(sub, ErrHubClosed)
+	}
+}
+
+// Len returns the number of active subscribers
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// remember appends events to the history, discarding the oldest beyond
+// the kept size; the caller must hold h.mu
+func (h *Hub) remember(events []Event) {
+	if h.keep == 0 {
+		return
+	}
+	h.history = append(h.history, events...)
+	if excess := len(h.history) - h.keep; excess > 0 {
+		h.history = h.history[excess:]
+	}
+}
+
+// since returns the kept events after position after that match filter;
+// the caller must hold h.mu
+func (h *Hub) since(after uint64, filter storage.QueryFilter) []Event {
+	var events []Event
+	for _, event := range h.history {
+		if event.Seq > after && filter.Matches(event.Entry) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// remove ends sub with err; the caller must hold h.mu
+func (h *Hub) remove(sub *Subscription, err error) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	sub.err = err
+	close(sub.done)
+	Subscribers.Dec()
+}
+
+// Subscription receives the logs published to a hub that match its filter
+type Subscription struct {
+	hub       *Hub
+	filter    storage.QueryFilter
+	start     uint64
+	entries   chan Event
+	done      chan struct{}
+	err       error
+	delivered map[int64]bool // Storage ids delivered, until Delivered is called
+}
+
+// Start returns the position the subscription started after; logs
+// published later are delivered to it
+func (s *Subscription) Start() uint64 {
+	return s.start
+}
+
+// Delivered returns the storage ids of the logs delivered so far and stops
+// recording them. Logs without an id are not recorded.
+func (s *Subscription) Delivered() map[int64]bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delivered := s.delivered
+	s.delivered = nil
+	return delivered
+}
+
+// Entries returns the channel of matching logs
+func (s *Subscription) Entries() <-chan Event {
+	return s.entries
+}
+
+// Done is closed when the subscription ends
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the subscription ended, or nil while it is active or
+// after it was closed by its owner
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// deliver queues the matching events without blocking and reports
+// whether they all fit in the buffer
+func (s *Subscription) deliver(events []Event) bool {
+	for _, event := range events {
+		if !s.filter.Matches(event.Entry) {
+			continue
+		}
+		select {
+		case s.entries <- event:
+		default:
+			return false
+		}
+		if s.delivered != nil && event.Entry.ID != 0 {
+			s.delivered[event.Entry.ID] = true
+		}
+	}
+	return true
+}
+
+// Close unregisters the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s, nil)
+}
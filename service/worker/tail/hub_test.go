@@ -0,0 +1,155 @@
+package tail_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jgfranco17/echoris/service/worker/storage"
+	"github.com/jgfranco17/echoris/service/worker/tail"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(service, message string) storage.LogEntry {
+	return storage.LogEntry{Timestamp: time.Now(), Service: service, Level: "info", Message: message}
+}
+
+func receive(t *testing.T, sub *tail.Subscription) storage.LogEntry {
+	t.Helper()
+	select {
+	case e := <-sub.Entries():
+		return e.Entry
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a log")
+	}
+	return storage.LogEntry{}
+}
+
+func TestHub_PublishMatchesFilters(t *testing.T) {
+	hub := tail.NewHub(8, 0)
+	defer hub.Close()
+
+	all, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+	api, _, err := hub.Subscribe(storage.QueryFilter{Service: "api"}, "")
+	require.NoError(t, err)
+
+	hub.Publish([]storage.LogEntry{entry("api", "first"), entry("db", "second")})
+
+	assert.Equal(t, "first", receive(t, all).Message)
+	assert.Equal(t, "second", receive(t, all).Message)
+	assert.Equal(t, "first", receive(t, api).Message)
+	assert.Empty(t, api.Entries())
+}
+
+func TestHub_DropsSlowConsumers(t *testing.T) {
+	hub := tail.NewHub(2, 0)
+	defer hub.Close()
+
+	slow, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+	fast, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+	dropped := testutil.ToFloat64(tail.DroppedSubscribers)
+
+	hub.Publish([]storage.LogEntry{entry("api", "1"), entry("api", "2")})
+	receive(t, fast)
+	receive(t, fast)
+	hub.Publish([]storage.LogEntry{entry("api", "3")})
+
+	<-slow.Done()
+	assert.ErrorIs(t, slow.Err(), tail.ErrSlowConsumer)
+	assert.Equal(t, dropped+1, testutil.ToFloat64(tail.DroppedSubscribers))
+
+	assert.Equal(t, "3", receive(t, fast).Message)
+	assert.NoError(t, fast.Err())
+	assert.Equal(t, 1, hub.Len())
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := tail.NewHub(2, 0)
+	sub, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+
+	hub.Close()
+
+	<-sub.Done()
+	assert.ErrorIs(t, sub.Err(), tail.ErrHubClosed)
+	_, _, err = hub.Subscribe(storage.QueryFilter{}, "")
+	assert.ErrorIs(t, err, tail.ErrHubClosed)
+	sub.Close()
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := tail.NewHub(2, 0)
+	defer hub.Close()
+
+	sub, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+	sub.Close()
+	sub.Close()
+
+	<-sub.Done()
+	assert.NoError(t, sub.Err())
+	assert.Equal(t, 0, hub.Len())
+	hub.Publish([]storage.LogEntry{entry("api", "ignored")})
+}
+
+func TestSubscription_Delivered(t *testing.T) {
+	hub := tail.NewHub(8, 0)
+	defer hub.Close()
+
+	sub, _, err := hub.Subscribe(storage.QueryFilter{Service: "api"}, "")
+	require.NoError(t, err)
+	first, other, unsaved := entry("api", "first"), entry("db", "other"), entry("api", "unsaved")
+	first.ID, other.ID = 1, 2
+	hub.Publish([]storage.LogEntry{first, other, unsaved})
+
+	assert.Equal(t, map[int64]bool{1: true}, sub.Delivered(), "only matching logs with an id are recorded")
+	second := entry("api", "second")
+	second.ID = 3
+	hub.Publish([]storage.LogEntry{second})
+	assert.Nil(t, sub.Delivered(), "recording stops at the first call")
+}
+
+func TestHub_ResumeFromCursor(t *testing.T) {
+	hub := tail.NewHub(8, 3)
+	defer hub.Close()
+
+	sub, _, err := hub.Subscribe(storage.QueryFilter{}, "")
+	require.NoError(t, err)
+	hub.Publish([]storage.LogEntry{entry("api", "1"), entry("db", "2"), entry("api", "3")})
+	first := <-sub.Entries()
+	sub.Close()
+
+	t.Run("returns the kept logs after the cursor", func(t *testing.T) {
+		resumed, backlog, err := hub.Subscribe(storage.QueryFilter{Service: "api"}, hub.Cursor(first.Seq))
+		require.NoError(t, err)
+		defer resumed.Close()
+
+		require.Len(t, backlog, 1)
+		assert.Equal(t, "3", backlog[0].Entry.Message)
+		assert.Equal(t, uint64(3), resumed.Start())
+	})
+
+	t.Run("rejects cursors past the kept history", func(t *testing.T) {
+		hub.Publish([]storage.LogEntry{entry("api", "4"), entry("api", "5")})
+		_, _, err := hub.Subscribe(storage.QueryFilter{}, hub.Cursor(first.Seq))
+		assert.ErrorIs(t, err, tail.ErrCursorExpired)
+	})
+
+	t.Run("rejects cursors from another hub", func(t *testing.T) {
+		other := tail.NewHub(8, 3)
+		defer other.Close()
+		_, _, err := hub.Subscribe(storage.QueryFilter{}, other.Cursor(0))
+		assert.ErrorIs(t, err, tail.ErrCursorExpired)
+	})
+
+	t.Run("rejects malformed cursors", func(t *testing.T) {
+		for _, cursor := range []string{"garbage", "abc-x", "abc--1"} {
+			_, _, err := hub.Subscribe(storage.QueryFilter{}, cursor)
+			assert.ErrorIs(t, err, tail.ErrInvalidCursor, cursor)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package tail
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	Subscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "echoris_tail_subscribers",
+			Help: "Number of open live tail subscriptions",
+		},
+	)
+	DroppedSubscribers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "echoris_tail_dropped_subscribers_total",
+			Help: "Number of live tail subscriptions dropped for falling behind",
+		},
+	)
+)
+
+// Collectors returns the live tail metrics for registration
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{Subscribers, DroppedSubscribers}
+}